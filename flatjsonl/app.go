@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"runtime/pprof"
 
 	"github.com/bool64/dev/version"
@@ -57,7 +58,11 @@ func run() error {
 		defer pprof.StopCPUProfile()
 	}
 
-	inputs := f.Inputs()
+	inputs, err := f.ExpandInputs()
+	if err != nil {
+		return err
+	}
+
 	if len(inputs) == 0 {
 		flag.Usage()
 
@@ -83,6 +88,10 @@ func run() error {
 		startHTTPStatusServer(httpStatus, proc)
 	}
 
+	if f.Follow {
+		stopOnInterrupt(proc)
+	}
+
 	if err := proc.Process(); err != nil {
 		return err
 	}
@@ -104,3 +113,17 @@ func run() error {
 
 	return nil
 }
+
+// stopOnInterrupt stops following inputs on first SIGINT so that writers are closed cleanly,
+// second SIGINT terminates the process.
+func stopOnInterrupt(proc *Processor) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+
+	go func() {
+		<-sig
+		signal.Stop(sig)
+		proc.Log("interrupted, finishing output")
+		proc.Stop()
+	}()
+}
@@ -0,0 +1,200 @@
+package flatjsonl
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// archive iterates regular file members of tar or zip archive.
+type archive struct {
+	name     string
+	patterns []string
+
+	tr *tar.Reader
+
+	zr *zip.Reader
+	zi int
+
+	cur io.Closer
+	dr  io.Reader
+}
+
+// isTar checks magic of POSIX and GNU tar header.
+func isTar(br *bufio.Reader) bool {
+	head, _ := br.Peek(262) //nolint:errcheck // Short stream is not a tar.
+
+	return len(head) == 262 && bytes.Equal(head[257:262], []byte("ustar"))
+}
+
+// isZip checks magic of local file header.
+func isZip(br *bufio.Reader) bool {
+	head, _ := br.Peek(4) //nolint:errcheck // Short stream is not a zip.
+
+	return bytes.Equal(head, []byte("PK\x03\x04"))
+}
+
+func newTarArchive(name string, r io.Reader, patterns []string) *archive {
+	return &archive{
+		name:     name,
+		patterns: patterns,
+		tr:       tar.NewReader(r),
+	}
+}
+
+func newZipArchive(name string, r io.ReaderAt, size int64, patterns []string) (*archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %w", err)
+	}
+
+	return &archive{
+		name:     name,
+		patterns: patterns,
+		zr:       zr,
+	}, nil
+}
+
+// next returns label and decompressed contents of the next matching member, io.EOF is returned when done.
+func (a *archive) next() (string, io.Reader, error) {
+	if err := a.closeMember(); err != nil {
+		return "", nil, err
+	}
+
+	for {
+		name, r, err := a.nextMember()
+		if err != nil {
+			return "", nil, err
+		}
+
+		if r == nil || !a.match(name) {
+			continue
+		}
+
+		br := bufio.NewReader(r)
+
+		cmp := sniffCompression(br)
+		if cmp == "" {
+			return a.label(name), br, nil
+		}
+
+		dr, err := decompress(br, cmp)
+		if err != nil {
+			return "", nil, fmt.Errorf("archive member %s: %w", name, err)
+		}
+
+		a.dr = dr
+
+		return a.label(name), dr, nil
+	}
+}
+
+func (a *archive) nextMember() (string, io.Reader, error) {
+	if a.tr != nil {
+		h, err := a.tr.Next()
+		if err != nil {
+			return "", nil, err
+		}
+
+		if h.Typeflag != tar.TypeReg {
+			return h.Name, nil, nil
+		}
+
+		return h.Name, a.tr, nil
+	}
+
+	if a.zi >= len(a.zr.File) {
+		return "", nil, io.EOF
+	}
+
+	f := a.zr.File[a.zi]
+	a.zi++
+
+	if f.FileInfo().IsDir() || !a.match(f.Name) {
+		return f.Name, nil, nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open zip member %s: %w", f.Name, err)
+	}
+
+	a.cur = rc
+
+	return f.Name, rc, nil
+}
+
+func (a *archive) closeMember() error {
+	switch c := a.dr.(type) {
+	case io.Closer:
+		if err := c.Close(); err != nil {
+			return err
+		}
+	case interface{ Close() }:
+		c.Close()
+	}
+
+	a.dr = nil
+
+	if a.cur != nil {
+		err := a.cur.Close()
+		a.cur = nil
+
+		return err
+	}
+
+	return nil
+}
+
+func (a *archive) match(name string) bool {
+	if len(a.patterns) == 0 {
+		return true
+	}
+
+	name = strings.TrimPrefix(name, "./")
+
+	for _, p := range a.patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+
+		if ok, _ := path.Match(p, path.Base(name)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *archive) label(member string) string {
+	member = strings.TrimPrefix(member, "./")
+
+	if a.name == "" {
+		return member
+	}
+
+	return a.name + "/" + member
+}
+
+// countingReaderAt counts bytes read with io.ReaderAt.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	bytes int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	atomic.AddInt64(&c.bytes, int64(n))
+
+	return n, err
+}
+
+func (c *countingReaderAt) Bytes() int64 {
+	return atomic.LoadInt64(&c.bytes)
+}
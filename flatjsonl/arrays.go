@@ -0,0 +1,277 @@
+package flatjsonl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vearutop/fastjson"
+)
+
+// arrayStrategy is the name of array summary column.
+type arrayStrategy string
+
+// Summaries of arrays.
+const (
+	arrayJoin  = arrayStrategy("JOIN")
+	arrayLen   = arrayStrategy("LEN")
+	arrayFirst = arrayStrategy("FIRST")
+	arrayLast  = arrayStrategy("LAST")
+	arrayList  = arrayStrategy("LIST")
+)
+
+// Enum describes the type.
+func (arrayStrategy) Enum() []any {
+	return []any{
+		arrayJoin,
+		arrayLen,
+		arrayFirst,
+		arrayLast,
+		arrayList,
+	}
+}
+
+// arraySummary defines columns that replace indexed columns of array elements.
+type arraySummary struct {
+	join  bool
+	len   bool
+	first bool
+	last  bool
+	list  bool
+
+	delimiter []byte
+}
+
+// parseArrays prepares summaries of arrays from config.
+func parseArrays(cfg Config) (map[string]arraySummary, error) {
+	if len(cfg.Arrays) == 0 {
+		return nil, nil
+	}
+
+	delimiter := ","
+	if cfg.ArrayDelimiter != "" {
+		delimiter = cfg.ArrayDelimiter
+	}
+
+	arrays := make(map[string]arraySummary, len(cfg.Arrays))
+
+	for k, strategies := range cfg.Arrays {
+		s := arraySummary{delimiter: []byte(delimiter)}
+
+		for _, st := range strings.Split(strategies, ",") {
+			switch arrayStrategy(strings.ToUpper(strings.TrimSpace(st))) {
+			case arrayJoin:
+				s.join = true
+			case arrayLen:
+				s.len = true
+			case arrayFirst:
+				s.first = true
+			case arrayLast:
+				s.last = true
+			case arrayList:
+				s.list = true
+			default:
+				return nil, fmt.Errorf("unknown array strategy %q for %s", st, k)
+			}
+		}
+
+		if s.join && s.list {
+			return nil, fmt.Errorf("array strategies JOIN and LIST can not be used together for %s", k)
+		}
+
+		if k == cfg.Explode {
+			return nil, fmt.Errorf("exploded array %s can not have array strategies", k)
+		}
+
+		arrays[k] = s
+	}
+
+	return arrays, nil
+}
+
+// walkArraySummary passes summary values of array instead of elements.
+//
+// JOIN and LIST values are passed with the key of array, LEN, FIRST and LAST are passed with ._len, ._first and
+// ._last child keys.
+func (fv *FastWalker) walkArraySummary(seq int64, flatPath []byte, pl int, path []string, a []*fastjson.Value, s arraySummary) {
+	if s.list && fv.FnList != nil {
+		fv.FnList(seq, flatPath, pl, path, listValues(a))
+	}
+
+	if s.join {
+		fv.FnString(seq, flatPath, pl, path, joinArray(a, s.delimiter))
+	}
+
+	pl = len(flatPath)
+
+	if s.len {
+		flatPath := append(flatPath, "._len"...)
+
+		fv.FnNumber(seq, flatPath, pl, fv.childPath(path, "_len"), float64(len(a)), strconv.AppendInt(nil, int64(len(a)), 10))
+	}
+
+	if s.first && len(a) > 0 {
+		fv.WalkFastJSON(seq, append(flatPath, "._first"...), pl, fv.childPath(path, "_first"), a[0])
+	}
+
+	if s.last && len(a) > 0 {
+		fv.WalkFastJSON(seq, append(flatPath, "._last"...), pl, fv.childPath(path, "_last"), a[len(a)-1])
+	}
+}
+
+func (fv *FastWalker) childPath(path []string, k string) []string {
+	if !fv.WantPath {
+		return nil
+	}
+
+	return append(path, k)
+}
+
+// joinArray joins scalar elements with delimiter, objects and arrays are joined as JSON literals,
+// null elements are skipped.
+//
+// Elements that contain delimiter or double quote are enclosed in double quotes with inner double quotes
+// doubled (as in CSV), so that joined value can be split back.
+func joinArray(a []*fastjson.Value, delimiter []byte) []byte {
+	var res []byte
+
+	n := 0
+
+	for _, v := range a {
+		if v.Type() == fastjson.TypeNull {
+			continue
+		}
+
+		if n > 0 {
+			res = append(res, delimiter...)
+		}
+
+		n++
+
+		if v.Type() == fastjson.TypeString {
+			res = appendJoinElement(res, v.GetStringBytes(), delimiter)
+		} else {
+			res = appendJoinElement(res, v.MarshalTo(nil), delimiter)
+		}
+	}
+
+	return res
+}
+
+func appendJoinElement(res, e, delimiter []byte) []byte {
+	if !bytes.Contains(e, []byte{'"'}) && (len(delimiter) == 0 || !bytes.Contains(e, delimiter)) {
+		return append(res, e...)
+	}
+
+	res = append(res, '"')
+	res = append(res, bytes.ReplaceAll(e, []byte{'"'}, []byte{'"', '"'})...)
+
+	return append(res, '"')
+}
+
+// listValues converts array elements to values, objects and arrays are kept as JSON literals.
+func listValues(a []*fastjson.Value) []Value {
+	values := make([]Value, 0, len(a))
+
+	for _, v := range a {
+		switch v.Type() {
+		case fastjson.TypeString:
+			values = append(values, Value{Type: TypeString, String: string(v.GetStringBytes())})
+		case fastjson.TypeNumber:
+			values = append(values, newNumberValue(v.GetFloat64(), string(v.MarshalTo(nil))))
+		case fastjson.TypeTrue, fastjson.TypeFalse:
+			values = append(values, Value{Type: TypeBool, Bool: v.GetBool()})
+		case fastjson.TypeNull:
+			values = append(values, Value{Type: TypeNull})
+		default:
+			values = append(values, Value{Type: TypeJSON, String: string(v.MarshalTo(nil))})
+		}
+	}
+
+	return values
+}
+
+// elemType returns common type of list elements.
+func elemType(values []Value) Type {
+	t := TypeAbsent
+
+	for _, v := range values {
+		switch {
+		case v.Type == TypeNull:
+			continue
+		case v.Type == TypeFloat:
+			t = t.Update(numberType(v.Number, []byte(v.RawNumber)))
+		default:
+			t = t.Update(v.Type)
+		}
+	}
+
+	return t
+}
+
+// formatList formats list as JSON array.
+func formatList(values []Value) string {
+	b := make([]byte, 0, 2+8*len(values))
+	b = append(b, '[')
+
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		switch v.Type { //nolint:exhaustive
+		case TypeString:
+			s, err := json.Marshal(v.String)
+			if err != nil {
+				panic(fmt.Sprintf("BUG: failed to marshal string: %v", err))
+			}
+
+			b = append(b, s...)
+		case TypeNull, TypeAbsent:
+			b = append(b, "null"...)
+		default:
+			b = append(b, v.Format()...)
+		}
+	}
+
+	b = append(b, ']')
+
+	return string(b)
+}
+
+// pgArray formats list as PostgreSQL array literal.
+func pgArray(values []Value) string {
+	b := make([]byte, 0, 2+8*len(values))
+	b = append(b, '{')
+
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		switch v.Type { //nolint:exhaustive
+		case TypeNull, TypeAbsent:
+			b = append(b, "NULL"...)
+		case TypeFloat, TypeBool:
+			b = append(b, v.Format()...)
+		default:
+			b = append(b, '"')
+
+			for _, c := range []byte(v.Format()) {
+				if c == '"' || c == '\\' {
+					b = append(b, '\\')
+				}
+
+				b = append(b, c)
+			}
+
+			b = append(b, '"')
+		}
+	}
+
+	b = append(b, '}')
+
+	return string(b)
+}
@@ -0,0 +1,381 @@
+package flatjsonl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkpoint is a state of processing that is saved with -checkpoint to continue interrupted run with -resume.
+type checkpoint struct {
+	Inputs []checkpointInput `json:"inputs"`
+
+	// Schema, line counts of inputs and sample seed are results of keys scanning.
+	Schema     *schema `json:"schema,omitempty"`
+	InputLines []int64 `json:"inputLines,omitempty"`
+	TotalLines int     `json:"totalLines,omitempty"`
+	SampleSeed uint64  `json:"sampleSeed"`
+
+	// Sequence is the last line that is committed to output.
+	// LastRow is the last row number in output, it differs from Sequence if rows are filtered.
+	Sequence int64 `json:"sequence"`
+	LastRow  int64 `json:"lastRow"`
+
+	// Outputs are sizes of output files with committed rows, nil if output is not started.
+	Outputs   map[string]int64 `json:"outputs"`
+	Completed bool             `json:"completed,omitempty"`
+}
+
+type checkpointInput struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+
+	// Lines is a number of lines of input that took sequence numbers, Read is true if input was read completely.
+	// Committed is a number of lines of input that are committed to output.
+	Lines     int64 `json:"lines,omitempty"`
+	Read      bool  `json:"read,omitempty"`
+	Committed int64 `json:"committed,omitempty"`
+}
+
+func checkCheckpoint(f Flags) error {
+	if f.Checkpoint == "" {
+		if f.Resume {
+			return errors.New("resume requires -checkpoint")
+		}
+
+		return nil
+	}
+
+	switch {
+	case f.Unordered:
+		return errors.New("checkpoint requires ordered rows, -unordered is not supported")
+	case f.SampleSize > 0:
+		return errors.New("checkpoint is not supported with -sample-size")
+	case f.Parquet != "" || f.DuckDB != "" || (f.SQLite != "" && f.SQLiteCLI):
+		return errors.New("checkpoint requires appendable outputs: CSV, RAW, SQLite or PG dump")
+	}
+
+	for _, fn := range []string{f.CSV, f.Raw, f.PGDump} {
+		if strings.HasSuffix(fn, ".gz") || strings.HasSuffix(fn, ".zst") {
+			return fmt.Errorf("checkpoint is not supported for compressed output %s", fn)
+		}
+	}
+
+	return nil
+}
+
+// checkpointer saves checkpoints of processing, methods are safe to call on nil instance.
+type checkpointer struct {
+	fn string
+	p  *Processor
+
+	// resumed is a checkpoint of interrupted run, it is nil if processing starts from the beginning.
+	resumed *checkpoint
+
+	mu sync.Mutex
+	cp checkpoint
+
+	// bases are sequence numbers preceding lines of inputs, -1 for inputs that are not started.
+	bases []int64
+	// writing is true during output pass of reading.
+	writing bool
+
+	due int64
+}
+
+// newCheckpointer prepares checkpoint for inputs and loads the previous one if resume is enabled.
+func newCheckpointer(p *Processor) (*checkpointer, error) {
+	c := &checkpointer{
+		fn: p.f.Checkpoint,
+		p:  p,
+	}
+
+	for _, in := range p.inputs {
+		ci := checkpointInput{Name: in.FileName}
+
+		switch r := in.Reader.(type) {
+		case *RangeReader:
+			ci.Size = r.Size()
+		default:
+			if in.FileName == "" {
+				return nil, errors.New("checkpoint requires input files, STDIN is not supported")
+			}
+
+			fi, err := os.Stat(in.FileName)
+			if err != nil {
+				return nil, fmt.Errorf("stat %s: %w", in.FileName, err)
+			}
+
+			ci.Size = fi.Size()
+		}
+
+		c.cp.Inputs = append(c.cp.Inputs, ci)
+		c.bases = append(c.bases, -1)
+	}
+
+	if !p.f.Resume {
+		return c, nil
+	}
+
+	b, err := os.ReadFile(c.fn)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+
+	var cp checkpoint
+
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, fmt.Errorf("decode checkpoint %s: %w", c.fn, err)
+	}
+
+	if err := c.checkInputs(cp.Inputs); err != nil {
+		return nil, err
+	}
+
+	c.resumed = &cp
+
+	return c, nil
+}
+
+// checkInputs fails if inputs are not the same as in checkpoint, the last input can grow in follow mode.
+func (c *checkpointer) checkInputs(inputs []checkpointInput) error {
+	if len(inputs) != len(c.cp.Inputs) {
+		return fmt.Errorf("checkpoint has %d inputs, %d given", len(inputs), len(c.cp.Inputs))
+	}
+
+	for i, in := range inputs {
+		cur := c.cp.Inputs[i]
+
+		grown := c.p.f.Follow && i == len(inputs)-1 && cur.Size > in.Size
+
+		if in.Name != cur.Name || (in.Size != cur.Size && !grown) {
+			return fmt.Errorf("input %s has changed since checkpoint", cur.Name)
+		}
+	}
+
+	return nil
+}
+
+// outputResumed checks if output of interrupted run is continued.
+func (c *checkpointer) outputResumed() bool {
+	return c != nil && c.resumed != nil && c.resumed.Outputs != nil
+}
+
+// resumeOutput returns sizes of output files and the last row number to continue output, ok is false if output
+// is not resumed.
+func (c *checkpointer) resumeOutput() (pos map[string]int64, lastRow int64, ok bool) {
+	if !c.outputResumed() {
+		return nil, 0, false
+	}
+
+	return c.resumed.Outputs, c.resumed.LastRow, true
+}
+
+// scanned saves checkpoint after keys are prepared.
+func (c *checkpointer) scanned() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resumed != nil {
+		inputs := c.cp.Inputs
+		c.cp = *c.resumed
+		c.cp.Inputs = inputs
+
+		return nil
+	}
+
+	p := c.p
+
+	if p.scansKeys() {
+		s := p.schema()
+		c.cp.Schema = &s
+	}
+
+	c.cp.InputLines = p.inputLines
+	c.cp.TotalLines = p.totalLines
+	c.cp.SampleSeed = p.sampleSeed
+
+	return c.save()
+}
+
+// startWriting resets tracking of inputs for output pass.
+func (c *checkpointer) startWriting() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writing = true
+
+	for i := range c.bases {
+		c.bases[i] = -1
+	}
+}
+
+// inputStarted records a sequence number that precedes lines of input.
+func (c *checkpointer) inputStarted(i int, base int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.writing {
+		return
+	}
+
+	c.bases[i] = base
+	c.cp.Inputs[i].Read = false
+}
+
+// inputFinished records a number of lines of completely read input.
+func (c *checkpointer) inputFinished(i int, lines int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.writing {
+		return
+	}
+
+	c.cp.Inputs[i].Lines = lines
+	c.cp.Inputs[i].Read = true
+}
+
+// committedInput returns a number of lines of input if all of them were committed before interruption.
+func (c *checkpointer) committedInput(i int) (int64, bool) {
+	if c == nil || !c.writing || !c.outputResumed() {
+		return 0, false
+	}
+
+	in := c.resumed.Inputs[i]
+
+	return in.Lines, in.Read && in.Committed == in.Lines
+}
+
+// watch requests checkpoints with interval until done is closed.
+func (c *checkpointer) watch(interval time.Duration, done <-chan struct{}) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			atomic.StoreInt64(&c.due, 1)
+		}
+	}
+}
+
+// isDue checks if checkpoint was requested since the last commit.
+func (c *checkpointer) isDue() bool {
+	return c != nil && atomic.CompareAndSwapInt64(&c.due, 1, 0)
+}
+
+// commit flushes output and saves checkpoint with lines up to seq, lastRow is the last row number in output.
+//
+// Output must not receive rows after seq during commit.
+func (c *checkpointer) commit(seq, lastRow int64, completed bool) error {
+	if c == nil {
+		return nil
+	}
+
+	pos, err := c.p.w.commit()
+	if err != nil {
+		return fmt.Errorf("commit output: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cp.Sequence = seq
+	c.cp.LastRow = lastRow
+	c.cp.Outputs = pos
+	c.cp.Completed = completed
+
+	for i, base := range c.bases {
+		in := &c.cp.Inputs[i]
+		in.Committed = 0
+
+		if base < 0 || seq <= base {
+			continue
+		}
+
+		in.Committed = seq - base
+
+		if in.Read && in.Committed > in.Lines {
+			in.Committed = in.Lines
+		}
+	}
+
+	return c.save()
+}
+
+// save writes checkpoint file.
+func (c *checkpointer) save() error {
+	b, err := json.MarshalIndent(c.cp, "", " ")
+	if err != nil {
+		return fmt.Errorf("marshal checkpoint: %w", err)
+	}
+
+	if err := writeFileSync(c.fn, b); err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// writeFileSync writes data to a synced temporary file and renames it, so that previous file is kept on failure.
+func writeFileSync(fn string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(fn), filepath.Base(fn)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), fn)
+}
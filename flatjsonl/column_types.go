@@ -0,0 +1,441 @@
+package flatjsonl
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// typeErrorPolicy is the name of handling of values that fail to convert to explicit column type.
+type typeErrorPolicy string
+
+// Handling of conversion errors.
+const (
+	typeErrorNull   = typeErrorPolicy("NULL")
+	typeErrorRaw    = typeErrorPolicy("RAW")
+	typeErrorReject = typeErrorPolicy("REJECT")
+)
+
+// Enum describes the type.
+func (typeErrorPolicy) Enum() []any {
+	return []any{
+		typeErrorNull,
+		typeErrorRaw,
+		typeErrorReject,
+	}
+}
+
+// Default precision and scale of decimal columns.
+const (
+	defaultDecimalPrecision = 38
+	defaultDecimalScale     = 9
+)
+
+// columnType is an explicit type of column from config, precision and scale are only set for decimal.
+type columnType struct {
+	t         Type
+	precision int
+	scale     int
+}
+
+var decimalTypeRegex = regexp.MustCompile(`^decimal\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)$`)
+
+// parseColumnType parses int, float, bool, string, json, timestamp, uuid, decimal or decimal(precision, scale).
+func parseColumnType(s string) (columnType, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	switch Type(s) { //nolint:exhaustive
+	case TypeInt, TypeFloat, TypeBool, TypeString, TypeJSON, TypeTimestamp, TypeUUID:
+		return columnType{t: Type(s)}, nil
+	case TypeDecimal:
+		return columnType{t: TypeDecimal, precision: defaultDecimalPrecision, scale: defaultDecimalScale}, nil
+	}
+
+	m := decimalTypeRegex.FindStringSubmatch(s)
+	if m == nil {
+		return columnType{}, fmt.Errorf("unknown column type %q", s)
+	}
+
+	ct := columnType{t: TypeDecimal}
+	ct.precision, _ = strconv.Atoi(m[1]) //nolint:errcheck // Digits are checked by regexp.
+
+	if m[2] != "" {
+		ct.scale, _ = strconv.Atoi(m[2]) //nolint:errcheck
+	}
+
+	if ct.precision < 1 || ct.precision > 38 || ct.scale > ct.precision {
+		return columnType{}, fmt.Errorf("invalid decimal precision and scale %q, precision must be 1 to 38 and scale must not exceed precision", s)
+	}
+
+	return ct, nil
+}
+
+// columnTypes are explicit types of columns that override inferred types.
+type columnTypes struct {
+	keys  map[string]columnType
+	regex map[*regexp.Regexp]columnType
+	// onError is empty if not configured.
+	onError typeErrorPolicy
+}
+
+func parseColumnTypes(cfg Config) (*columnTypes, error) {
+	if len(cfg.ColumnTypes) == 0 && len(cfg.ColumnTypesRegex) == 0 && cfg.ColumnTypesOnError == "" {
+		return nil, nil
+	}
+
+	ct := &columnTypes{
+		keys:    make(map[string]columnType, len(cfg.ColumnTypes)),
+		regex:   make(map[*regexp.Regexp]columnType, len(cfg.ColumnTypesRegex)),
+		onError: typeErrorPolicy(strings.ToUpper(string(cfg.ColumnTypesOnError))),
+	}
+
+	switch ct.onError {
+	case "", typeErrorNull, typeErrorRaw, typeErrorReject:
+	default:
+		return nil, fmt.Errorf("unknown conversion error policy %q", cfg.ColumnTypesOnError)
+	}
+
+	for k, s := range cfg.ColumnTypes {
+		t, err := parseColumnType(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+
+		ct.keys[k] = t
+	}
+
+	for reg, s := range cfg.ColumnTypesRegex {
+		t, err := parseColumnType(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", reg, err)
+		}
+
+		r, err := regex(reg)
+		if err != nil {
+			return nil, err
+		}
+
+		ct.regex[r] = t
+	}
+
+	return ct, nil
+}
+
+// applyColumnTypes overrides inferred types of keys that match columnTypes by original key, replaced column name,
+// or columnTypesRegex by original key.
+func (p *Processor) applyColumnTypes() {
+	if p.columnTypes == nil {
+		return
+	}
+
+	keys := make(map[string]columnType, len(p.columnTypes.keys))
+	for k, t := range p.columnTypes.keys {
+		keys[p.ck(k)] = t
+	}
+
+	for i, k := range p.keys {
+		t, ok := keys[p.ck(k.original)]
+
+		if !ok {
+			t, ok = p.columnTypes.keys[k.replaced]
+		}
+
+		if !ok {
+			for r, rt := range p.columnTypes.regex {
+				if r.MatchString(k.original) {
+					t, ok = rt, true
+
+					break
+				}
+			}
+		}
+
+		if !ok {
+			continue
+		}
+
+		k.t = t.t
+		k.typed = true
+		k.inferred = false
+		k.epochMillis = false
+		k.precision = t.precision
+		k.scale = t.scale
+
+		p.keys[i] = k
+	}
+}
+
+// convertsValues is true if values are converted to explicit or inferred types of columns.
+func (p *Processor) convertsValues() bool {
+	return p.columnTypes != nil || p.f.InferTypes
+}
+
+// convertValue converts value to explicit type of column, null values are not converted.
+//
+// Timestamps are parsed from layout of parseTime key, RFC3339 or date-time strings, numbers are seconds since epoch
+// (or milliseconds for inferred key).
+func convertValue(v Value, k flKey, layout string, loc *time.Location) (Value, error) {
+	if v.Type == TypeNull || v.Type == TypeAbsent {
+		return v, nil
+	}
+
+	switch k.t { //nolint:exhaustive
+	case TypeInt:
+		return toIntValue(v)
+	case TypeFloat:
+		n, ok := toNumber(v)
+		if !ok || math.IsNaN(n) || math.IsInf(n, 0) {
+			return v, fmt.Errorf("%s is not a number", describeValue(v))
+		}
+
+		if v.Type == TypeFloat {
+			return v, nil
+		}
+
+		return Value{Type: TypeFloat, Number: n, RawNumber: strings.TrimSpace(v.String)}, nil
+	case TypeBool:
+		return toBoolValue(v)
+	case TypeDecimal:
+		return toDecimalValue(v, k.precision, k.scale)
+	case TypeTimestamp:
+		var (
+			t  time.Time
+			ok bool
+		)
+
+		if k.epochMillis {
+			if ms, err := strconv.ParseInt(strings.TrimSpace(v.Format()), 10, 64); err == nil {
+				t, ok = time.UnixMilli(ms).In(time.UTC), true
+			}
+		}
+
+		if !ok {
+			t, ok = valueTime(v, layout, loc)
+		}
+
+		if !ok {
+			return v, fmt.Errorf("%s is not a timestamp", describeValue(v))
+		}
+
+		if loc != nil {
+			t = t.In(loc)
+		}
+
+		return Value{Type: TypeTimestamp, String: t.Format(layout), Time: t}, nil
+	case TypeUUID:
+		if v.Type != TypeString || !isUUID([]byte(v.String)) {
+			return v, fmt.Errorf("%s is not a UUID", describeValue(v))
+		}
+
+		return v, nil
+	case TypeJSON:
+		switch v.Type { //nolint:exhaustive
+		case TypeJSON:
+			return v, nil
+		case TypeString:
+			b, err := json.Marshal(v.String)
+			if err != nil {
+				return v, err
+			}
+
+			return Value{Type: TypeJSON, String: string(b)}, nil
+		default:
+			return Value{Type: TypeJSON, String: v.Format()}, nil
+		}
+	default:
+		if v.Type == TypeString {
+			return v, nil
+		}
+
+		return Value{Type: TypeString, String: v.Format()}, nil
+	}
+}
+
+func toIntValue(v Value) (Value, error) {
+	if v.IsInt {
+		return v, nil
+	}
+
+	var n float64
+
+	switch v.Type { //nolint:exhaustive
+	case TypeFloat:
+		n = v.Number
+	case TypeString:
+		s := strings.TrimSpace(v.String)
+
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return intValue(i), nil
+		}
+
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return v, fmt.Errorf("%s is not a number", describeValue(v))
+		}
+
+		n = f
+	default:
+		return v, fmt.Errorf("%s is not a number", describeValue(v))
+	}
+
+	if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+		return v, fmt.Errorf("%s is not an integer", describeValue(v))
+	}
+
+	return intValue(int64(n)), nil
+}
+
+func toBoolValue(v Value) (Value, error) {
+	switch v.Type { //nolint:exhaustive
+	case TypeBool:
+		return v, nil
+	case TypeFloat:
+		switch v.Number {
+		case 0:
+			return boolValue(false), nil
+		case 1:
+			return boolValue(true), nil
+		}
+	case TypeString:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v.String)); err == nil {
+			return boolValue(b), nil
+		}
+	}
+
+	return v, fmt.Errorf("%s is not a bool", describeValue(v))
+}
+
+// toDecimalValue converts value to a number with exact decimal text rounded to scale,
+// text is kept as is for decimal without precision.
+func toDecimalValue(v Value, precision, scale int) (Value, error) {
+	var s string
+
+	switch v.Type { //nolint:exhaustive
+	case TypeFloat:
+		s = v.Format()
+		if v.RawNumber == "" {
+			s = strconv.FormatFloat(v.Number, 'f', -1, 64)
+		}
+	case TypeString:
+		s = strings.TrimSpace(v.String)
+	default:
+		return v, fmt.Errorf("%s is not a decimal", describeValue(v))
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.Contains(s, "/") {
+		return v, fmt.Errorf("%s is not a decimal", describeValue(v))
+	}
+
+	if precision == 0 {
+		f, _ := r.Float64()
+
+		return Value{Type: TypeFloat, Number: f, RawNumber: s}, nil
+	}
+
+	d := r.FloatString(scale)
+
+	if digits := strings.TrimLeft(strings.Split(strings.TrimPrefix(d, "-"), ".")[0], "0"); len(digits) > precision-scale {
+		return v, fmt.Errorf("%s exceeds decimal(%d,%d)", describeValue(v), precision, scale)
+	}
+
+	if scale > 0 {
+		d = strings.TrimRight(strings.TrimRight(d, "0"), ".")
+	}
+
+	f, _ := r.Float64()
+
+	return Value{Type: TypeFloat, Number: f, RawNumber: d}, nil
+}
+
+// valueTime converts value to time, numbers and numeric strings are seconds since epoch, strings are parsed
+// with layout, RFC3339 or date-time layouts.
+func valueTime(v Value, layout string, loc *time.Location) (time.Time, bool) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	switch v.Type { //nolint:exhaustive
+	case TypeTimestamp:
+		return v.Time, true
+	case TypeFloat:
+		sec, frac := math.Modf(v.Number)
+
+		return time.Unix(int64(sec), int64(frac*1e9)).In(loc), true
+	case TypeString:
+	default:
+		return time.Time{}, false
+	}
+
+	layouts := []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", time.DateOnly}
+	if layout != "" {
+		layouts = append([]string{layout}, layouts...)
+	}
+
+	for _, l := range layouts {
+		if t, err := time.ParseInLocation(l, v.String, loc); err == nil {
+			return t, true
+		}
+	}
+
+	if n, ok := toNumber(v); ok && !math.IsNaN(n) && !math.IsInf(n, 0) {
+		return valueTime(numberValue(n), layout, loc)
+	}
+
+	return time.Time{}, false
+}
+
+// convert converts value of column to explicit or inferred type according to conversion error policy,
+// it returns an error if line must be rejected.
+func (wi *writeIterator) convert(i int, v Value) (Value, error) {
+	k := wi.p.keys[i]
+
+	// Empty strings are ignored by type inference.
+	if k.inferred && v.Type == TypeString && v.String == "" {
+		return Value{Type: TypeNull}, nil
+	}
+
+	cv, err := convertValue(v, k, wi.outTimeFmt, wi.outputTZ)
+	if err == nil {
+		return cv, nil
+	}
+
+	onError := typeErrorNull
+
+	// Inferred type may not fit values of lines that were not scanned, such values are kept as is by default.
+	if k.inferred {
+		onError = typeErrorRaw
+	}
+
+	if wi.p.columnTypes != nil && wi.p.columnTypes.onError != "" {
+		onError = wi.p.columnTypes.onError
+	}
+
+	if onError == typeErrorReject {
+		return Value{}, fmt.Errorf("%s: %w", k.replaced, err)
+	}
+
+	atomic.AddInt64(&wi.conversionErrors, 1)
+
+	if onError == typeErrorRaw {
+		v.Unconverted = true
+
+		return v, nil
+	}
+
+	return Value{Type: TypeNull}, nil
+}
+
+func describeValue(v Value) string {
+	if v.Type == TypeString {
+		return strconv.Quote(v.String)
+	}
+
+	return v.Format()
+}
@@ -0,0 +1,91 @@
+package flatjsonl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseColumnType(t *testing.T) {
+	for s, exp := range map[string]columnType{
+		"int":             {t: TypeInt},
+		" Timestamp ":     {t: TypeTimestamp},
+		"json":            {t: TypeJSON},
+		"decimal":         {t: TypeDecimal, precision: 38, scale: 9},
+		"decimal(10)":     {t: TypeDecimal, precision: 10},
+		"DECIMAL(12, 4)":  {t: TypeDecimal, precision: 12, scale: 4},
+		"decimal(38,38)":  {t: TypeDecimal, precision: 38, scale: 38},
+		"decimal(40,2)":   {},
+		"decimal(4,5)":    {},
+		"decimal(0)":      {},
+		"varchar":         {},
+		"list":            {},
+		"decimal(1,2,3)":  {},
+		"decimal(-1,2)":   {},
+		"decimal(10, 2) ": {t: TypeDecimal, precision: 10, scale: 2},
+	} {
+		t.Run(s, func(t *testing.T) {
+			ct, err := parseColumnType(s)
+			if exp.t == "" {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, exp, ct)
+		})
+	}
+}
+
+func Test_convertValue(t *testing.T) {
+	str := func(s string) Value { return Value{Type: TypeString, String: s} }
+	num := func(raw string, n float64) Value { return Value{Type: TypeFloat, Number: n, RawNumber: raw} }
+
+	for _, tc := range []struct {
+		name   string
+		v      Value
+		k      flKey
+		exp    string
+		expErr string
+	}{
+		{name: "int from string", v: str(" 42 "), k: flKey{t: TypeInt}, exp: "42"},
+		{name: "int from float string", v: str("4.0e1"), k: flKey{t: TypeInt}, exp: "40"},
+		{name: "int from fraction", v: num("1.5", 1.5), k: flKey{t: TypeInt}, expErr: "1.5 is not an integer"},
+		{name: "int from text", v: str("abc"), k: flKey{t: TypeInt}, expErr: `"abc" is not a number`},
+		{name: "int from bool", v: boolValue(true), k: flKey{t: TypeInt}, expErr: "true is not a number"},
+		{name: "float from string", v: str("1.25"), k: flKey{t: TypeFloat}, exp: "1.25"},
+		{name: "float from bool", v: boolValue(true), k: flKey{t: TypeFloat}, expErr: "true is not a number"},
+		{name: "float from NaN", v: str("NaN"), k: flKey{t: TypeFloat}, expErr: `"NaN" is not a number`},
+		{name: "bool from string", v: str("TRUE"), k: flKey{t: TypeBool}, exp: "true"},
+		{name: "bool from number", v: num("0", 0), k: flKey{t: TypeBool}, exp: "false"},
+		{name: "bool from other number", v: num("2", 2), k: flKey{t: TypeBool}, expErr: "2 is not a bool"},
+		{name: "string from number", v: num("1e3", 1000), k: flKey{t: TypeString}, exp: "1e3"},
+		{name: "json from string", v: str(`a"b`), k: flKey{t: TypeJSON}, exp: `"a\"b"`},
+		{name: "json from number", v: num("12", 12), k: flKey{t: TypeJSON}, exp: "12"},
+		{name: "decimal", v: num("12.345", 12.345), k: flKey{t: TypeDecimal, precision: 10, scale: 2}, exp: "12.35"},
+		{name: "decimal trailing zeros", v: str("12.5000"), k: flKey{t: TypeDecimal, precision: 12, scale: 9}, exp: "12.5"},
+		{name: "decimal exponent", v: str("1e-3"), k: flKey{t: TypeDecimal, precision: 5, scale: 3}, exp: "0.001"},
+		{name: "decimal overflow", v: str("1234"), k: flKey{t: TypeDecimal, precision: 5, scale: 2}, expErr: `"1234" exceeds decimal(5,2)`},
+		{name: "decimal from fraction", v: str("1/3"), k: flKey{t: TypeDecimal, precision: 5, scale: 2}, expErr: `"1/3" is not a decimal`},
+		{name: "timestamp from string", v: str("2024-01-02 03:04:05"), k: flKey{t: TypeTimestamp}, exp: "2024-01-02T03:04:05Z"},
+		{name: "timestamp from RFC3339", v: str("2024-01-02T05:04:05+02:00"), k: flKey{t: TypeTimestamp}, exp: "2024-01-02T03:04:05Z"},
+		{name: "timestamp from epoch", v: num("1704164645", 1704164645), k: flKey{t: TypeTimestamp}, exp: "2024-01-02T03:04:05Z"},
+		{name: "timestamp from text", v: str("yesterday"), k: flKey{t: TypeTimestamp}, expErr: `"yesterday" is not a timestamp`},
+		{name: "null is kept", v: Value{Type: TypeNull}, k: flKey{t: TypeInt}, exp: "NULL"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := convertValue(tc.v, tc.k, time.RFC3339, time.UTC)
+			if tc.expErr != "" {
+				assert.EqualError(t, err, tc.expErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.exp, v.Format())
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package flatjsonl
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	gzip "github.com/klauspost/pgzip"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// Names of supported input compression codecs.
+const (
+	CompressionGzip  = "gzip"
+	CompressionZstd  = "zst"
+	CompressionBzip2 = "bzip2"
+	CompressionXz    = "xz"
+	CompressionLz4   = "lz4"
+
+	// CompressionNone declares uncompressed input, detection of compression and archives is skipped.
+	CompressionNone = "none"
+)
+
+var compressionMagic = []struct {
+	codec string
+	magic []byte
+}{
+	{codec: CompressionGzip, magic: []byte{0x1f, 0x8b}},
+	{codec: CompressionZstd, magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{codec: CompressionBzip2, magic: []byte("BZh")},
+	{codec: CompressionXz, magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{codec: CompressionLz4, magic: []byte{0x04, 0x22, 0x4d, 0x18}},
+}
+
+// sniffCompression detects compression codec by magic bytes at the beginning of stream.
+func sniffCompression(br *bufio.Reader) string {
+	head, _ := br.Peek(6) //nolint:errcheck // Short or failing stream is handled by subsequent reads.
+
+	for _, m := range compressionMagic {
+		if bytes.HasPrefix(head, m.magic) {
+			return m.codec
+		}
+	}
+
+	return ""
+}
+
+// decompress wraps reader with a decompressor of a named codec.
+func decompress(r io.Reader, codec string) (io.Reader, error) {
+	switch codec {
+	case "", CompressionNone:
+		return r, nil
+	case CompressionGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init gzip reader: %w", err)
+		}
+
+		return gr, nil
+	case CompressionZstd, "zstd":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init zstd reader: %w", err)
+		}
+
+		return zr, nil
+	case CompressionBzip2, "bz2":
+		return bzip2.NewReader(r), nil
+	case CompressionXz:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init xz reader: %w", err)
+		}
+
+		return xr, nil
+	case CompressionLz4:
+		return lz4.NewReader(r), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", codec)
+	}
+}
@@ -0,0 +1,1049 @@
+package flatjsonl
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// computedColumn is a column with values of expression evaluated on flattened values of a line.
+//
+// Expression refers to keys by original name (e.g. .duration) or by replaced column name (e.g. duration).
+// Supported are arithmetic (+, -, *, /, %), comparisons, regex matches and boolean logic, and functions:
+// lower, upper, trim, len, concat, substr, replace, coalesce, if, regex_extract, abs, round, floor, ceil,
+// unix, unix_ms, format_time, exists. Row filter is an expression of the same grammar.
+//
+// Example: if(.status >= 500, "error", lower(.level)).
+type computedColumn struct {
+	name string
+	src  string
+	expr computedExpr
+	keys []*filterKey
+
+	// idx is an index of column in lineBuf.values.
+	idx int
+}
+
+type computedExpr interface {
+	eval(l *lineBuf) Value
+	typ(p *Processor) Type
+}
+
+// exprFuncs are functions of computed expressions with min and max number of arguments, -1 for unlimited.
+var exprFuncs = map[string][2]int{
+	"lower":         {1, 1},
+	"upper":         {1, 1},
+	"trim":          {1, 1},
+	"len":           {1, 1},
+	"concat":        {1, -1},
+	"substr":        {2, 3},
+	"replace":       {3, 3},
+	"coalesce":      {1, -1},
+	"if":            {2, 3},
+	"regex_extract": {2, 3},
+	"abs":           {1, 1},
+	"round":         {1, 2},
+	"floor":         {1, 1},
+	"ceil":          {1, 1},
+	"unix":          {1, 1},
+	"unix_ms":       {1, 1},
+	"format_time":   {2, 2},
+	"exists":        {1, 1},
+}
+
+// parseComputedColumns compiles expressions of computed columns, columns are ordered by name.
+func parseComputedColumns(columns map[string]string) ([]*computedColumn, error) {
+	if len(columns) == 0 {
+		return nil, nil
+	}
+
+	res := make([]*computedColumn, 0, len(columns))
+
+	for name, src := range columns {
+		if name == "" || strings.HasPrefix(name, ".") {
+			return nil, fmt.Errorf("invalid column name %q, name must not start with dot", name)
+		}
+
+		c, err := parseComputedColumn(name, src)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		res = append(res, c)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].name < res[j].name
+	})
+
+	return res, nil
+}
+
+func parseComputedColumn(name, src string) (*computedColumn, error) {
+	e, keys, err := parseExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return &computedColumn{name: name, src: src, expr: e, keys: keys, idx: -1}, nil
+}
+
+// parseExpr compiles expression of computed column or row filter, keys refer to values that are bound later.
+func parseExpr(src string) (computedExpr, []*filterKey, error) {
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ep := exprParser{tokens: tokens}
+
+	e, err := ep.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if t := ep.peek(); t.kind != tokEOF {
+		return nil, nil, fmt.Errorf("unexpected %q at %d", t.s, t.pos)
+	}
+
+	return e, ep.keys, nil
+}
+
+// bind resolves keys of expression and column index, computed columns can not refer to other computed columns.
+func (c *computedColumn) bind(p *Processor, slots *exprSlots, outTimeFmt string, loc *time.Location) error {
+	n := 0
+
+	for i, k := range p.keys {
+		if k.replaced == c.name {
+			c.idx = i
+			n++
+		}
+	}
+
+	if n != 1 {
+		return fmt.Errorf("computed column %s: column name is not unique", c.name)
+	}
+
+	for _, k := range c.keys {
+		if err := p.bindExprKey(k, slots, outTimeFmt, loc); err != nil {
+			return fmt.Errorf("computed column %s: %w", c.name, err)
+		}
+
+		if k.idx >= 0 && p.keys[k.idx].computed != nil {
+			return fmt.Errorf("computed column %s: can not refer to computed column %s", c.name, k.name)
+		}
+	}
+
+	walkExpr(c.expr, func(e computedExpr) {
+		if f, ok := e.(*exprCall); ok {
+			f.loc = loc
+		}
+	})
+
+	if err := bindTimeLits(c.expr, loc); err != nil {
+		return fmt.Errorf("computed column %s: %w", c.name, err)
+	}
+
+	return nil
+}
+
+// computedKey returns key of computed column.
+func (p *Processor) computedKey(c *computedColumn) flKey {
+	return flKey{
+		path:      []string{c.name},
+		original:  c.name,
+		canonical: p.ck(c.name),
+		computed:  c,
+	}
+}
+
+// exprKeyType returns type of key that is referred by expression.
+func (p *Processor) exprKeyType(name string) Type {
+	original := name
+
+	var t Type
+
+	if strings.HasPrefix(name, ".") {
+		t = p.canonicalKeys[p.ck(name)].t
+	} else {
+		for _, k := range p.keys {
+			if k.replaced == name {
+				if k.typed || k.inferred {
+					return k.t
+				}
+
+				t = k.t
+				original = k.original
+
+				break
+			}
+		}
+	}
+
+	if tf, ok := p.cfg.ParseTime[original]; ok && tf != "RAW" {
+		return TypeString
+	}
+
+	return t
+}
+
+// evalComputed sets values of computed columns of a line.
+func (wi *writeIterator) evalComputed(l *lineBuf) {
+	for _, c := range wi.computed {
+		v := c.expr.eval(l)
+		if v.Type == TypeNull {
+			v = Value{}
+		}
+
+		if wi.p.keys[c.idx].typed {
+			cv, err := wi.convert(c.idx, v)
+			if err != nil && l.rejectErr == nil {
+				l.rejectErr = err
+			}
+
+			v = cv
+		}
+
+		l.values[c.idx] = v
+	}
+}
+
+func walkExpr(e computedExpr, fn func(e computedExpr)) {
+	fn(e)
+
+	switch x := e.(type) {
+	case *exprUnary:
+		walkExpr(x.x, fn)
+	case *exprBinary:
+		walkExpr(x.a, fn)
+		walkExpr(x.b, fn)
+	case *exprMatch:
+		walkExpr(x.x, fn)
+	case *exprCall:
+		for _, a := range x.args {
+			walkExpr(a, fn)
+		}
+	}
+}
+
+type exprLit struct {
+	v Value
+}
+
+func (e *exprLit) eval(_ *lineBuf) Value { return e.v }
+
+func (e *exprLit) typ(_ *Processor) Type {
+	if e.v.Type == TypeFloat && e.v.Number == math.Trunc(e.v.Number) {
+		return TypeInt
+	}
+
+	return e.v.Type
+}
+
+type exprKey struct {
+	k *filterKey
+}
+
+func (e *exprKey) eval(l *lineBuf) Value { return e.k.value(l) }
+
+func (e *exprKey) typ(p *Processor) Type { return p.exprKeyType(e.k.name) }
+
+type exprUnary struct {
+	op string
+	x  computedExpr
+}
+
+func (e *exprUnary) eval(l *lineBuf) Value {
+	v := e.x.eval(l)
+
+	if e.op == "!" {
+		return boolValue(!truthy(v))
+	}
+
+	n, ok := toNumber(v)
+	if !ok {
+		return Value{Type: TypeNull}
+	}
+
+	return numberValue(-n)
+}
+
+func (e *exprUnary) typ(p *Processor) Type {
+	if e.op == "!" {
+		return TypeBool
+	}
+
+	return numericType(e.x.typ(p), TypeInt)
+}
+
+type exprBinary struct {
+	op   string
+	a, b computedExpr
+
+	// tm is set by bindTimeLits for comparison of parseTime key with time literal.
+	tm *timeCmp
+}
+
+// timeCmp compares value of parseTime key with time literal.
+type timeCmp struct {
+	k   *filterKey
+	lit time.Time
+
+	// swapped is set if key is on the right side.
+	swapped bool
+}
+
+// compare parses key value with time format of key, it returns false if value is not a time.
+func (t *timeCmp) compare(a, b Value) (int, bool) {
+	v := a
+	if t.swapped {
+		v = b
+	}
+
+	if v.Type != TypeString {
+		return 0, false
+	}
+
+	tm, err := time.ParseInLocation(t.k.timeFmt, v.String, t.k.loc)
+	if err != nil {
+		return 0, false
+	}
+
+	if t.swapped {
+		return t.lit.Compare(tm), true
+	}
+
+	return tm.Compare(t.lit), true
+}
+
+func (e *exprBinary) eval(l *lineBuf) Value {
+	switch e.op {
+	case "&&":
+		return boolValue(truthy(e.a.eval(l)) && truthy(e.b.eval(l)))
+	case "||":
+		return boolValue(truthy(e.a.eval(l)) || truthy(e.b.eval(l)))
+	}
+
+	a, b := e.a.eval(l), e.b.eval(l)
+
+	switch e.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		c, ok := 0, false
+
+		if e.tm != nil {
+			c, ok = e.tm.compare(a, b)
+		} else {
+			c, ok = compareValues(a, b)
+		}
+
+		if !ok {
+			return boolValue(e.op == "!=")
+		}
+
+		return boolValue(compared(e.op, c))
+	}
+
+	x, ok := toNumber(a)
+	if !ok {
+		return Value{Type: TypeNull}
+	}
+
+	y, ok := toNumber(b)
+	if !ok {
+		return Value{Type: TypeNull}
+	}
+
+	switch e.op {
+	case "+":
+		return numberValue(x + y)
+	case "-":
+		return numberValue(x - y)
+	case "*":
+		return numberValue(x * y)
+	case "/":
+		if y == 0 {
+			return Value{Type: TypeNull}
+		}
+
+		return numberValue(x / y)
+	default: // %
+		if y == 0 {
+			return Value{Type: TypeNull}
+		}
+
+		return numberValue(math.Mod(x, y))
+	}
+}
+
+func (e *exprBinary) typ(p *Processor) Type {
+	switch e.op {
+	case "&&", "||", "==", "!=", "<", "<=", ">", ">=":
+		return TypeBool
+	case "/":
+		return TypeFloat
+	}
+
+	return numericType(e.a.typ(p), e.b.typ(p))
+}
+
+type exprMatch struct {
+	x   computedExpr
+	re  *regexp.Regexp
+	not bool
+}
+
+func (e *exprMatch) eval(l *lineBuf) Value {
+	v := e.x.eval(l)
+	if v.Type == TypeAbsent || v.Type == TypeNull {
+		return boolValue(e.not)
+	}
+
+	return boolValue(e.re.MatchString(v.Format()) != e.not)
+}
+
+func (e *exprMatch) typ(_ *Processor) Type { return TypeBool }
+
+type exprCall struct {
+	name string
+	args []computedExpr
+
+	// re is a compiled pattern of regex_extract, layout is a layout of format_time.
+	re     *regexp.Regexp
+	layout string
+	loc    *time.Location
+}
+
+func (e *exprCall) eval(l *lineBuf) Value {
+	args := make([]Value, len(e.args))
+
+	for i, a := range e.args {
+		args[i] = a.eval(l)
+	}
+
+	switch e.name {
+	case "exists":
+		return boolValue(args[0].Type != TypeAbsent)
+	case "coalesce":
+		for _, v := range args {
+			if !isNull(v) {
+				return v
+			}
+		}
+
+		return Value{Type: TypeNull}
+	case "if":
+		if truthy(args[0]) {
+			return args[1]
+		}
+
+		if len(args) > 2 {
+			return args[2]
+		}
+
+		return Value{Type: TypeNull}
+	case "concat":
+		var sb strings.Builder
+
+		for _, v := range args {
+			if !isNull(v) {
+				sb.WriteString(v.Format())
+			}
+		}
+
+		return Value{Type: TypeString, String: sb.String()}
+	}
+
+	if isNull(args[0]) {
+		return Value{Type: TypeNull}
+	}
+
+	switch e.name {
+	case "lower":
+		return Value{Type: TypeString, String: strings.ToLower(args[0].Format())}
+	case "upper":
+		return Value{Type: TypeString, String: strings.ToUpper(args[0].Format())}
+	case "trim":
+		return Value{Type: TypeString, String: strings.TrimSpace(args[0].Format())}
+	case "len":
+		return numberValue(float64(utf8.RuneCountInString(args[0].Format())))
+	case "substr":
+		return e.substr(args)
+	case "replace":
+		return Value{Type: TypeString, String: strings.ReplaceAll(args[0].Format(), args[1].Format(), args[2].Format())}
+	case "regex_extract":
+		return e.regexExtract(args)
+	case "unix", "unix_ms":
+		t, ok := e.toTime(args[0])
+		if !ok {
+			return Value{Type: TypeNull}
+		}
+
+		if e.name == "unix" {
+			return numberValue(float64(t.Unix()))
+		}
+
+		return numberValue(float64(t.UnixMilli()))
+	case "format_time":
+		t, ok := e.toTime(args[0])
+		if !ok {
+			return Value{Type: TypeNull}
+		}
+
+		return Value{Type: TypeString, String: t.Format(e.layout)}
+	}
+
+	n, ok := toNumber(args[0])
+	if !ok {
+		return Value{Type: TypeNull}
+	}
+
+	switch e.name {
+	case "abs":
+		return numberValue(math.Abs(n))
+	case "floor":
+		return numberValue(math.Floor(n))
+	case "ceil":
+		return numberValue(math.Ceil(n))
+	default: // round
+		if len(args) == 1 {
+			return numberValue(math.Round(n))
+		}
+
+		d, ok := toNumber(args[1])
+		if !ok {
+			return Value{Type: TypeNull}
+		}
+
+		m := math.Pow(10, math.Trunc(d))
+
+		return numberValue(math.Round(n*m) / m)
+	}
+}
+
+func (e *exprCall) substr(args []Value) Value {
+	r := []rune(args[0].Format())
+
+	start, ok := toNumber(args[1])
+	if !ok {
+		return Value{Type: TypeNull}
+	}
+
+	from := min(max(int(start), 0), len(r))
+	to := len(r)
+
+	if len(args) > 2 {
+		n, ok := toNumber(args[2])
+		if !ok {
+			return Value{Type: TypeNull}
+		}
+
+		to = min(from+max(int(n), 0), len(r))
+	}
+
+	return Value{Type: TypeString, String: string(r[from:to])}
+}
+
+func (e *exprCall) regexExtract(args []Value) Value {
+	group := 0
+
+	if len(args) > 2 {
+		n, ok := toNumber(args[2])
+		if !ok {
+			return Value{Type: TypeNull}
+		}
+
+		group = int(n)
+	}
+
+	m := e.re.FindStringSubmatch(args[0].Format())
+	if m == nil || group < 0 || group >= len(m) {
+		return Value{Type: TypeNull}
+	}
+
+	return Value{Type: TypeString, String: m[group]}
+}
+
+// toTime converts value to time, value of parseTime key is parsed with output time format.
+func (e *exprCall) toTime(v Value) (time.Time, bool) {
+	layout := ""
+
+	if k, ok := e.args[0].(*exprKey); ok {
+		layout = k.k.timeFmt
+	}
+
+	return valueTime(v, layout, e.loc)
+}
+
+func (e *exprCall) typ(p *Processor) Type {
+	switch e.name {
+	case "len", "unix", "unix_ms", "floor", "ceil":
+		return TypeInt
+	case "exists":
+		return TypeBool
+	case "abs":
+		return numericType(e.args[0].typ(p), TypeInt)
+	case "round":
+		if len(e.args) == 1 {
+			return TypeInt
+		}
+
+		return TypeFloat
+	case "coalesce", "if":
+		args := e.args
+		if e.name == "if" {
+			args = args[1:]
+		}
+
+		t := TypeAbsent
+
+		for _, a := range args {
+			if at := a.typ(p); at != TypeAbsent {
+				t = t.Update(at)
+			}
+		}
+
+		return t
+	default:
+		return TypeString
+	}
+}
+
+// numericType returns int if both operands are integer, or float otherwise.
+func numericType(a, b Type) Type {
+	if a == TypeInt && b == TypeInt {
+		return TypeInt
+	}
+
+	return TypeFloat
+}
+
+func isNull(v Value) bool {
+	return v.Type == TypeAbsent || v.Type == TypeNull
+}
+
+func truthy(v Value) bool {
+	switch v.Type { //nolint:exhaustive
+	case TypeBool:
+		return v.Bool
+	case TypeFloat:
+		return v.Number != 0
+	case TypeString:
+		return v.String != ""
+	case TypeAbsent, TypeNull:
+		return false
+	default:
+		return true
+	}
+}
+
+func toNumber(v Value) (float64, bool) {
+	switch v.Type { //nolint:exhaustive
+	case TypeFloat:
+		return v.Number, true
+	case TypeString:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.String), 64)
+
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func numberValue(n float64) Value {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return Value{Type: TypeNull}
+	}
+
+	if n == math.Trunc(n) && math.Abs(n) <= 1<<53 {
+		return intValue(int64(n))
+	}
+
+	return Value{Type: TypeFloat, Number: n, RawNumber: strconv.FormatFloat(n, 'f', -1, 64)}
+}
+
+func intValue(i int64) Value {
+	return Value{Type: TypeFloat, Number: float64(i), RawNumber: strconv.FormatInt(i, 10), Int: i, IsInt: true}
+}
+
+func boolValue(b bool) Value {
+	return Value{Type: TypeBool, Bool: b}
+}
+
+// compareValues compares two values, it returns false if they are not comparable.
+// Null values are only equal to null, numbers are compared with numeric strings as numbers,
+// integers are compared exactly.
+func compareValues(a, b Value) (int, bool) {
+	if isNull(a) || isNull(b) {
+		return 0, isNull(a) && isNull(b)
+	}
+
+	if a.Type == TypeBool || b.Type == TypeBool {
+		if a.Type != b.Type {
+			return 0, false
+		}
+
+		switch {
+		case a.Bool == b.Bool:
+			return 0, true
+		case b.Bool:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+
+	if a.Type == TypeTimestamp && b.Type == TypeTimestamp {
+		return a.Time.Compare(b.Time), true
+	}
+
+	if a.Type == TypeFloat || b.Type == TypeFloat {
+		x, ok := numberOf(a)
+		if !ok {
+			return 0, false
+		}
+
+		y, ok := numberOf(b)
+		if !ok {
+			return 0, false
+		}
+
+		if x.IsInt && y.IsInt {
+			return cmp.Compare(x.Int, y.Int), true
+		}
+
+		return compareNumbers(x.Number, y.Number), true
+	}
+
+	return strings.Compare(a.Format(), b.Format()), true
+}
+
+// numberOf returns number value, numeric string is parsed with exact integer.
+func numberOf(v Value) (Value, bool) {
+	switch v.Type { //nolint:exhaustive
+	case TypeFloat:
+		return v, true
+	case TypeString:
+		s := strings.TrimSpace(v.String)
+
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Value{}, false
+		}
+
+		return newNumberValue(n, s), true
+	default:
+		return Value{}, false
+	}
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compared checks result of comparison for operator.
+func compared(op string, c int) bool {
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default: // >=
+		return c >= 0
+	}
+}
+
+// bindTimeLits parses string literals that are compared with parseTime keys.
+func bindTimeLits(e computedExpr, loc *time.Location) error {
+	var errs []error
+
+	walkExpr(e, func(e computedExpr) {
+		b, ok := e.(*exprBinary)
+		if !ok {
+			return
+		}
+
+		b.tm = nil
+
+		switch b.op {
+		case "==", "!=", "<", "<=", ">", ">=":
+		default:
+			return
+		}
+
+		k, ok := b.a.(*exprKey)
+		s, isLit := stringLit(b.b)
+		swapped := false
+
+		if !ok || !isLit {
+			k, ok = b.b.(*exprKey)
+			s, isLit = stringLit(b.a)
+			swapped = true
+		}
+
+		if !ok || !isLit || k.k.timeFmt == "" {
+			return
+		}
+
+		for _, layout := range []string{k.k.timeFmt, time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", time.DateOnly} {
+			if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+				b.tm = &timeCmp{k: k.k, lit: t, swapped: swapped}
+
+				return
+			}
+		}
+
+		errs = append(errs, fmt.Errorf("failed to parse time %q for %s", s, k.k.name))
+	})
+
+	return errors.Join(errs...)
+}
+
+// exprParser parses expression of computed column or row filter.
+type exprParser struct {
+	tokens []filterToken
+	pos    int
+	keys   []*filterKey
+}
+
+func (ep *exprParser) peek() filterToken {
+	return ep.tokens[ep.pos]
+}
+
+func (ep *exprParser) next() filterToken {
+	t := ep.tokens[ep.pos]
+
+	if t.kind != tokEOF {
+		ep.pos++
+	}
+
+	return t
+}
+
+func (ep *exprParser) unexpected(t filterToken) error {
+	if t.kind == tokEOF {
+		return errors.New("unexpected end of expression")
+	}
+
+	return fmt.Errorf("unexpected %q at %d", t.s, t.pos)
+}
+
+func (ep *exprParser) key(name string) *filterKey {
+	k := &filterKey{name: name, idx: -1}
+	ep.keys = append(ep.keys, k)
+
+	return k
+}
+
+func (ep *exprParser) parseOr() (computedExpr, error) {
+	return ep.parseBinary(0)
+}
+
+// exprLevels are binary operators by increasing precedence.
+var exprLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">=", "=~", "!~"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (ep *exprParser) parseBinary(level int) (computedExpr, error) {
+	if level == len(exprLevels) {
+		return ep.parseUnary()
+	}
+
+	e, err := ep.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := ep.peek()
+		if t.kind != tokOp || !slices.Contains(exprLevels[level], t.s) {
+			return e, nil
+		}
+
+		ep.next()
+
+		if t.s == "=~" || t.s == "!~" {
+			rt := ep.next()
+			if rt.kind != tokString {
+				return nil, fmt.Errorf("regex match requires string at %d", t.pos)
+			}
+
+			re, err := regexp.Compile(rt.s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex at %d: %w", rt.pos, err)
+			}
+
+			e = &exprMatch{x: e, re: re, not: t.s == "!~"}
+
+			continue
+		}
+
+		b, err := ep.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		e = &exprBinary{op: t.s, a: e, b: b}
+	}
+}
+
+func (ep *exprParser) parseUnary() (computedExpr, error) {
+	t := ep.peek()
+
+	if t.kind == tokOp && (t.s == "!" || t.s == "-") {
+		ep.next()
+
+		x, err := ep.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &exprUnary{op: t.s, x: x}, nil
+	}
+
+	return ep.parsePrimary()
+}
+
+func (ep *exprParser) parsePrimary() (computedExpr, error) {
+	t := ep.next()
+
+	switch t.kind {
+	case tokLParen:
+		e, err := ep.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if t := ep.next(); t.kind != tokRParen {
+			return nil, ep.unexpected(t)
+		}
+
+		return e, nil
+	case tokString:
+		return &exprLit{v: Value{Type: TypeString, String: t.s}}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", t.s, t.pos)
+		}
+
+		if i, err := strconv.ParseInt(t.s, 10, 64); err == nil {
+			return &exprLit{v: intValue(i)}, nil
+		}
+
+		return &exprLit{v: numberValue(n)}, nil
+	case tokKey:
+		return &exprKey{k: ep.key(t.s)}, nil
+	case tokIdent:
+		if ep.peek().kind == tokLParen {
+			return ep.parseCall(t)
+		}
+
+		switch t.s {
+		case "true", "false":
+			return &exprLit{v: boolValue(t.s == "true")}, nil
+		case "null":
+			return &exprLit{v: Value{Type: TypeNull}}, nil
+		}
+
+		return &exprKey{k: ep.key(t.s)}, nil
+	}
+
+	return nil, ep.unexpected(t)
+}
+
+func (ep *exprParser) parseCall(name filterToken) (computedExpr, error) {
+	arity, ok := exprFuncs[name.s]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at %d", name.s, name.pos)
+	}
+
+	ep.next()
+
+	c := &exprCall{name: name.s}
+
+	if ep.peek().kind != tokRParen {
+		for {
+			a, err := ep.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			c.args = append(c.args, a)
+
+			if ep.peek().kind != tokComma {
+				break
+			}
+
+			ep.next()
+		}
+	}
+
+	if t := ep.next(); t.kind != tokRParen {
+		return nil, ep.unexpected(t)
+	}
+
+	if len(c.args) < arity[0] || (arity[1] >= 0 && len(c.args) > arity[1]) {
+		return nil, fmt.Errorf("wrong number of arguments for %s at %d", name.s, name.pos)
+	}
+
+	switch c.name {
+	case "regex_extract":
+		s, ok := stringLit(c.args[1])
+		if !ok {
+			return nil, fmt.Errorf("regex_extract requires string pattern at %d", name.pos)
+		}
+
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex at %d: %w", name.pos, err)
+		}
+
+		c.re = re
+	case "format_time":
+		s, ok := stringLit(c.args[1])
+		if !ok {
+			return nil, fmt.Errorf("format_time requires string layout at %d", name.pos)
+		}
+
+		c.layout = s
+	case "exists":
+		if _, ok := c.args[0].(*exprKey); !ok {
+			return nil, fmt.Errorf("exists requires key at %d", name.pos)
+		}
+	}
+
+	return c, nil
+}
+
+func stringLit(e computedExpr) (string, bool) {
+	if l, ok := e.(*exprLit); ok && l.v.Type == TypeString {
+		return l.v.String, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,91 @@
+package flatjsonl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseComputedColumn(t *testing.T) {
+	for expr, expErr := range map[string]string{
+		`.duration * 1000`:                            "",
+		`.a - -1.5e3 + (.b*2) % 3 / .c`:               "",
+		`lower(.req.host)`:                            "",
+		`if(.status >= 500 && !.done, "error", "ok")`: "",
+		`coalesce(.a, .b, 0)`:                         "",
+		`regex_extract(.path, '^/(\w+)', 1)`:          "",
+		`format_time(.ts, "2006-01-02") != null`:      "",
+		`.msg =~ 'timeout'`:                           "",
+		`.a *`:                                        "unexpected end of expression",
+		`(.a + 1`:                                     "unexpected end of expression",
+		`.a + 1)`:                                     `unexpected ")" at 6`,
+		`foo(.a)`:                                     "unknown function foo at 0",
+		`lower(.a, .b)`:                               "wrong number of arguments for lower at 0",
+		`coalesce()`:                                  "wrong number of arguments for coalesce at 0",
+		`regex_extract(.a, .b)`:                       "regex_extract requires string pattern at 0",
+		`regex_extract(.a, '(')`:                      "invalid regex at 0: error parsing regexp: missing closing ): `(`",
+		`format_time(.a, 1)`:                          "format_time requires string layout at 0",
+		`.a =~ 1`:                                     "regex match requires string at 3",
+		`.a ; 1`:                                      `unexpected ";" at 3`,
+		`lower(.a .b)`:                                `unexpected ".b" at 9`,
+		`1 2`:                                         `unexpected "2" at 2`,
+		`"abc`:                                        "unterminated string at 0",
+		`.a + ,`:                                      `unexpected "," at 5`,
+		`concat(.a, "x", lower(.b), upper("y"), len(.c))`: "",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := parseComputedColumn("c", expr)
+			if expErr == "" {
+				require.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, expErr)
+			}
+		})
+	}
+}
+
+func Test_computedExpr_eval(t *testing.T) {
+	for expr, exp := range map[string]string{
+		`1 + 2 * 3`:                                   "7",
+		`(1 + 2) * 3`:                                 "9",
+		`7 % 4 - -1`:                                  "4",
+		`1 / 0`:                                       "ABSENT",
+		`"10" * 2`:                                    "20",
+		`"abc" * 2`:                                   "ABSENT",
+		`upper(trim("  ab "))`:                        "AB",
+		`len("héllo")`:                                "5",
+		`substr("flatjsonl", 4, 4)`:                   "json",
+		`substr("flatjsonl", 4)`:                      "jsonl",
+		`replace("a-b-c", "-", "_")`:                  "a_b_c",
+		`concat("a", null, 1, true)`:                  "a1true",
+		`coalesce(null, "x")`:                         "x",
+		`if(1 > 2, "a", "b")`:                         "b",
+		`if(false, "a")`:                              "ABSENT",
+		`regex_extract("id=42;", 'id=(\d+)', 1)`:      "42",
+		`regex_extract("none", 'id=(\d+)')`:           "ABSENT",
+		`round(2.345, 2)`:                             "2.35",
+		`round(-2.5)`:                                 "-3",
+		`floor(2.7) + ceil(2.1) + abs(-1)`:            "6",
+		`unix("2024-01-02T03:04:05Z")`:                "1704164645",
+		`unix_ms("2024-01-02 03:04:05")`:              "1704164645000",
+		`format_time(1704164645, "2006-01-02 15:04")`: "2024-01-02 03:04",
+		`"b" > "a" && !("a" == "b") || false`:         "true",
+		`"10" == 10`:                                  "true",
+		`null == null`:                                "true",
+		`"abc" =~ '^a'`:                               "true",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			c, err := parseComputedColumn("c", expr)
+			require.NoError(t, err)
+
+			l := &lineBuf{values: make([]Value, 1)}
+			c.idx = 0
+
+			wi := &writeIterator{p: &Processor{keys: []flKey{{}}}, computed: []*computedColumn{c}}
+			wi.evalComputed(l)
+
+			assert.Equal(t, exp, l.values[0].Format())
+		})
+	}
+}
@@ -3,6 +3,7 @@ package flatjsonl
 // Config describes processing options.
 type Config struct {
 	MatchLinePrefix    string             `json:"matchLinePrefix" yaml:"matchLinePrefix"`
+	MatchLinePrefixes  []string           `json:"matchLinePrefixes" yaml:"matchLinePrefixes" description:"List of alternative line prefix regular expressions, first matching is used."`
 	IncludeKeys        []string           `json:"includeKeys" yaml:"includeKeys"`
 	IncludeKeysRegex   []string           `json:"includeKeysRegex" yaml:"includeKeysRegex"`
 	ExcludeKeys        []string           `json:"excludeKeys" yaml:"excludeKeys" description:"List of keys remove from columns."`
@@ -14,8 +15,16 @@ type Config struct {
 	OutputTimezone     string             `json:"outputTZ" yaml:"outputTZ" example:"UTC"`
 	ConcatDelimiter    *string            `json:"concatDelimiter" yaml:"concatDelimiter" example:"," description:"In case multiple keys are replaced into one, their values would be concatenated."`
 	Transpose          map[string]string  `json:"transpose" yaml:"transpose" description:"Map of key prefixes to transposed table names."`
+	Arrays             map[string]string  `json:"arrays" yaml:"arrays" example:"{\".tags\":\"LEN,JOIN\"}" description:"Map of array keys to comma-separated summaries instead of indexed columns of elements: 'JOIN', 'LEN', 'FIRST', 'LAST' or 'LIST'."`
+	ArrayDelimiter     string             `json:"arrayDelimiter" yaml:"arrayDelimiter" example:"|" description:"Delimiter of elements for JOIN array summary, default ','."`
+	ColumnTypes        map[string]string  `json:"columnTypes" yaml:"columnTypes" example:"{\".id\":\"int\"}" description:"Map of keys or column names to explicit column types: 'int', 'float', 'bool', 'string', 'json', 'timestamp', 'decimal' or 'decimal(precision,scale)'."`
+	ColumnTypesRegex   map[string]string  `json:"columnTypesRegex" yaml:"columnTypesRegex" description:"Map of key regex to explicit column types."`
+	ColumnTypesOnError typeErrorPolicy    `json:"columnTypesOnError" yaml:"columnTypesOnError" description:"Handling of values that fail to convert to explicit column type: 'NULL' (default), 'RAW' to keep original value, or 'REJECT' to skip the row."`
+	ComputedColumns    map[string]string  `json:"computedColumns" yaml:"computedColumns" example:"{\"latency_ms\":\".duration * 1000\"}" description:"Map of new column names to expressions over flattened keys."`
+	Explode            string             `json:"explode" yaml:"explode" example:".items" description:"Key of array to unnest into multiple rows of the main table, one row per element with element index in <key>._index column."`
 	ExtractValuesRegex map[string]extract `json:"extractValuesRegex" yaml:"extractValuesRegex" description:"Map of key regex to extraction format, values can be 'URL', 'JSON', 'GEOIP', 'NETIP' or comma-separated list of formats."`
 	KeepJSON           []string           `json:"keepJSON" yaml:"keepJSON" description:"List of keys to keep as JSON literals."`
 	KeepJSONRegex      []string           `json:"keepJSONRegex" yaml:"keepJSONRegex" description:"List of key patterns to keep as JSON literals."`
 	AllowCardinality   []string           `json:"allowCardinality" yaml:"allowCardinality" description:"List of keys to allow high cardinality of child keys."`
+	Where              string             `json:"where" yaml:"where" example:".level == \"error\" && .status >= 500" description:"Row filter expression, rows that do not match are not written."`
 }
@@ -13,20 +13,28 @@ type CSVWriter struct {
 
 	transposed map[string]*CSVWriter
 
+	// om defines how existing files are continued.
+	om outputMode
+
 	*fileWriter
 	b *baseWriter
 }
 
 // NewCSVWriter creates an instance of CSVWriter.
 func NewCSVWriter(fn string, nullValue string) (*CSVWriter, error) {
+	return newCSVWriter(fn, nullValue, outputMode{})
+}
+
+func newCSVWriter(fn string, nullValue string, om outputMode) (*CSVWriter, error) {
 	var err error
 
 	c := &CSVWriter{
 		fn:        fn,
 		nullValue: nullValue,
+		om:        om,
 	}
 
-	c.fileWriter, err = newFileWriter(fn)
+	c.fileWriter, err = outputFileWriter(fn, om)
 	if err != nil {
 		return nil, err
 	}
@@ -41,8 +49,10 @@ func NewCSVWriter(fn string, nullValue string) (*CSVWriter, error) {
 func (c *CSVWriter) SetupKeys(keys []flKey) (err error) {
 	c.b.setupKeys(keys)
 
-	if err := c.writeHead(); err != nil {
-		return err
+	if !c.appended {
+		if err := c.writeHead(); err != nil {
+			return err
+		}
 	}
 
 	c.transposed = map[string]*CSVWriter{}
@@ -55,7 +65,7 @@ func (c *CSVWriter) SetupKeys(keys []flKey) (err error) {
 			fn = c.fn
 		}
 
-		ctw, err := NewCSVWriter(fn, c.nullValue)
+		ctw, err := newCSVWriter(fn, c.nullValue, c.om)
 		if err != nil {
 			return fmt.Errorf("failed to init transposed CSV writer for %s: %w", dst, err)
 		}
@@ -63,6 +73,10 @@ func (c *CSVWriter) SetupKeys(keys []flKey) (err error) {
 		ctw.b = tw
 		c.transposed[dst] = ctw
 
+		if ctw.appended {
+			continue
+		}
+
 		if err := ctw.writeHead(); err != nil {
 			return fmt.Errorf("failed to write transposed head for %s: %w", dst, err)
 		}
@@ -157,6 +171,14 @@ func (c *CSVWriter) receiveRow(seq int64, values []Value) (transposedRows [][]st
 				row = make([]string, len(c.b.trimmedKeys))
 				row[0] = Format(float64(seq)) // Add sequence.
 				row[1] = transposeKey         // Add array idx/object property.
+
+				for _, l := range c.b.lineage {
+					row[l.dst] = c.nullValue
+					if lv := values[l.src]; lv.Type != TypeNull && lv.Type != TypeAbsent {
+						row[l.dst] = lv.Format()
+					}
+				}
+
 				transposedRowsIdx[transposeKey] = row
 				transposedRows = append(transposedRows, row)
 			}
@@ -172,6 +194,44 @@ func (c *CSVWriter) receiveRow(seq int64, values []Value) (transposedRows [][]st
 	return transposedRows
 }
 
+// Flush writes buffered rows to file.
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+
+	if err := c.w.Error(); err != nil {
+		return fmt.Errorf("failed to flush CSV: %w", err)
+	}
+
+	if err := c.flush(); err != nil {
+		return fmt.Errorf("failed to flush CSV file: %w", err)
+	}
+
+	for _, tw := range c.transposed {
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *CSVWriter) positions(pos map[string]int64) error {
+	p, err := filePosition(c.fn, c.f)
+	if err != nil {
+		return err
+	}
+
+	pos[c.fn] = p
+
+	for _, tw := range c.transposed {
+		if err := tw.positions(pos); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Close flushes rows and closes file.
 func (c *CSVWriter) Close() error {
 	c.w.Flush()
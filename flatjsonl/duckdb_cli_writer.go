@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
@@ -14,11 +15,19 @@ type DuckDBCLIWriter struct {
 	mainCSV *CSVWriter
 	cmd     *exec.Cmd
 	w       io.WriteCloser
+
+	fn        string
+	tableName string
+	cliPath   string
+	r         io.Reader
 }
 
 // NewDuckDBCLIWriter creates DuckDB CLI writer.
 func NewDuckDBCLIWriter(fn string, tableName string, nullValue string) (*DuckDBCLIWriter, error) {
-	dw := &DuckDBCLIWriter{}
+	dw := &DuckDBCLIWriter{
+		fn:        fn,
+		tableName: tableName,
+	}
 
 	c := &CSVWriter{
 		fn:        NopFile,
@@ -27,9 +36,10 @@ func NewDuckDBCLIWriter(fn string, tableName string, nullValue string) (*DuckDBC
 
 	r, w := io.Pipe()
 	dw.w = w
+	dw.r = r
 
 	c.w = csv.NewWriter(w)
-	c.b = &baseWriter{}
+	c.b = &baseWriter{typedColumns: true}
 
 	dw.mainCSV = c
 
@@ -38,22 +48,24 @@ func NewDuckDBCLIWriter(fn string, tableName string, nullValue string) (*DuckDBC
 		return nil, errors.New("duckdb CLI is not available in PATH")
 	}
 
-	query := duckDBReadCSVQuery(tableName, c.nullValue)
-
-	dw.cmd = exec.Command(cliPath, fn, "-c", query)
-	dw.cmd.Stdin = r
-	dw.cmd.Stdout = os.Stdout
-	dw.cmd.Stderr = os.Stderr
-
-	if err := dw.cmd.Start(); err != nil {
-		return nil, err
-	}
+	dw.cliPath = cliPath
 
 	return dw, nil
 }
 
-// SetupKeys inits writer with list of known keys.
+// SetupKeys inits writer with list of known keys and starts import.
 func (w *DuckDBCLIWriter) SetupKeys(keys []flKey) error {
+	query := duckDBReadCSVQuery(w.tableName, w.mainCSV.nullValue, keys)
+
+	w.cmd = exec.Command(w.cliPath, w.fn, "-c", query)
+	w.cmd.Stdin = w.r
+	w.cmd.Stdout = os.Stdout
+	w.cmd.Stderr = os.Stderr
+
+	if err := w.cmd.Start(); err != nil {
+		return err
+	}
+
 	return w.mainCSV.SetupKeys(keys)
 }
 
@@ -72,6 +84,10 @@ func (w *DuckDBCLIWriter) Close() error {
 		return err
 	}
 
+	if w.cmd == nil {
+		return nil
+	}
+
 	if err := w.cmd.Wait(); err != nil {
 		return err
 	}
@@ -87,7 +103,7 @@ func quoteDuckDBString(s string) string {
 	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
 }
 
-func duckDBReadCSVQuery(tableName string, nullValue string) string {
+func duckDBReadCSVQuery(tableName string, nullValue string, keys []flKey) string {
 	query := "CREATE TABLE " + quoteDuckDBIdent(tableName) + //nolint: unqueryvet
 		" AS SELECT * FROM read_csv('/dev/stdin', header=true, auto_detect=true"
 
@@ -95,7 +111,66 @@ func duckDBReadCSVQuery(tableName string, nullValue string) string {
 		query += ", nullstr=" + quoteDuckDBString(nullValue)
 	}
 
+	// Lists and decimals are not detected in CSV, so their types are defined explicitly, as well as types from
+	// columnTypes.
+	var types []string
+
+	for _, k := range keys {
+		if k.transposeDst != "" {
+			continue
+		}
+
+		switch {
+		case k.t == TypeList:
+			types = append(types, quoteDuckDBString(k.replaced)+": "+quoteDuckDBString(duckDBListType(k.elemType)))
+		case k.typed || k.inferred || k.t == TypeDecimal:
+			types = append(types, quoteDuckDBString(k.replaced)+": "+quoteDuckDBString(duckDBColumnType(k)))
+		}
+	}
+
+	if len(types) > 0 {
+		query += ", types={" + strings.Join(types, ", ") + "}"
+	}
+
 	query += ")"
 
 	return query
 }
+
+// duckDBColumnType returns type of column with explicit or inferred type.
+func duckDBColumnType(k flKey) string {
+	switch k.t { //nolint:exhaustive
+	case TypeInt:
+		return "BIGINT"
+	case TypeFloat:
+		return "DOUBLE"
+	case TypeBool:
+		return "BOOLEAN"
+	case TypeTimestamp:
+		return "TIMESTAMP"
+	case TypeUUID:
+		return "UUID"
+	case TypeDecimal:
+		if k.precision == 0 {
+			return "DECIMAL(" + strconv.Itoa(defaultDecimalPrecision) + "," + strconv.Itoa(k.scale) + ")"
+		}
+
+		return "DECIMAL(" + strconv.Itoa(k.precision) + "," + strconv.Itoa(k.scale) + ")"
+	default:
+		return "VARCHAR"
+	}
+}
+
+// duckDBListType returns list type for elements of list.
+func duckDBListType(elemType Type) string {
+	switch elemType { //nolint:exhaustive
+	case TypeInt:
+		return "BIGINT[]"
+	case TypeFloat:
+		return "DOUBLE[]"
+	case TypeBool:
+		return "BOOLEAN[]"
+	default:
+		return "VARCHAR[]"
+	}
+}
@@ -9,16 +9,36 @@ import (
 func TestDuckDBReadCSVQuery(t *testing.T) {
 	assert.Equal(t,
 		`CREATE TABLE "flatjsonl" AS SELECT * FROM read_csv('/dev/stdin', header=true, auto_detect=true)`,
-		duckDBReadCSVQuery("flatjsonl", ""),
+		duckDBReadCSVQuery("flatjsonl", "", nil),
 	)
 
 	assert.Equal(t,
 		`CREATE TABLE "flatjsonl" AS SELECT * FROM read_csv('/dev/stdin', header=true, auto_detect=true, nullstr='\N')`,
-		duckDBReadCSVQuery("flatjsonl", `\N`),
+		duckDBReadCSVQuery("flatjsonl", `\N`, nil),
 	)
 
 	assert.Equal(t,
 		`CREATE TABLE "quoted""name" AS SELECT * FROM read_csv('/dev/stdin', header=true, auto_detect=true, nullstr='it''s null')`,
-		duckDBReadCSVQuery(`quoted"name`, "it's null"),
+		duckDBReadCSVQuery(`quoted"name`, "it's null", nil),
+	)
+
+	assert.Equal(t,
+		`CREATE TABLE "flatjsonl" AS SELECT * FROM read_csv('/dev/stdin', header=true, auto_detect=true, types={'tags': 'VARCHAR[]', 'ids': 'BIGINT[]'})`,
+		duckDBReadCSVQuery("flatjsonl", "", []flKey{
+			{replaced: "id", t: TypeInt},
+			{replaced: "tags", t: TypeList, elemType: TypeString},
+			{replaced: "ids", t: TypeList, elemType: TypeInt},
+		}),
+	)
+
+	assert.Equal(t,
+		`CREATE TABLE "flatjsonl" AS SELECT * FROM read_csv('/dev/stdin', header=true, auto_detect=true, types={'id': 'BIGINT', 'amount': 'DECIMAL(10,2)', 'at': 'TIMESTAMP'})`,
+		duckDBReadCSVQuery("flatjsonl", "", []flKey{
+			{replaced: "id", t: TypeInt, typed: true},
+			{replaced: "amount", t: TypeDecimal, typed: true, precision: 10, scale: 2},
+			{replaced: "at", t: TypeTimestamp, typed: true},
+			{replaced: "name", t: TypeString},
+			{replaced: "v", t: TypeInt, typed: true, transposeDst: "t"},
+		}),
 	)
 }
@@ -0,0 +1,307 @@
+package flatjsonl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// rowFilter is a compiled row filter expression, it is evaluated on flattened values of a line.
+//
+// Filter is an expression of computed columns, line passes if result is true, non-zero number or non-empty string.
+// Expression refers to keys by original name (e.g. .status) or by replaced column name (e.g. status).
+// Supported operators are ==, !=, <, <=, >, >=, =~ (regex match), !~ (regex mismatch), &&, ||, ! and parentheses,
+// exists(.key) checks if key is present in line. Strings are double-quoted with escapes or single-quoted raw.
+// Values of parseTime keys are compared as time with string literals.
+//
+// Example: .level == "error" && (.status >= 500 || exists(.panic)) && .time >= "2024-01-01".
+type rowFilter struct {
+	expr computedExpr
+	keys []*filterKey
+}
+
+// filterKey refers to a value in lineBuf.
+type filterKey struct {
+	name string
+
+	// idx is an index in lineBuf.values, or -1 if value is in lineBuf.exprValues at slot.
+	idx  int
+	slot int
+
+	// timeFmt is set for parseTime keys.
+	timeFmt string
+	loc     *time.Location
+}
+
+func (k *filterKey) value(l *lineBuf) Value {
+	if k.idx >= 0 {
+		return l.values[k.idx]
+	}
+
+	return l.exprValues[k.slot]
+}
+
+// match checks if line passes filter.
+func (f *rowFilter) match(l *lineBuf) bool {
+	return truthy(f.expr.eval(l))
+}
+
+// exprSlots keeps values of keys that are used in expressions, but are not in output, in lineBuf.exprValues.
+type exprSlots struct {
+	// extra maps hashes of keys to slots.
+	extra map[uint64]int
+	byCK  map[string]int
+	n     int
+}
+
+func newExprSlots() *exprSlots {
+	return &exprSlots{
+		extra: map[uint64]int{},
+		byCK:  map[string]int{},
+	}
+}
+
+// slot returns index of exprValues for a key that is not in output.
+func (s *exprSlots) slot(pk uint64) (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+
+	i, ok := s.extra[pk]
+
+	return i, ok
+}
+
+// bind resolves keys of expression to indexes of values.
+//
+// Keys that are not included in output are collected separately, so rows can be filtered by any key.
+func (f *rowFilter) bind(p *Processor, slots *exprSlots, outTimeFmt string, loc *time.Location) error {
+	for _, k := range f.keys {
+		if err := p.bindExprKey(k, slots, outTimeFmt, loc); err != nil {
+			return fmt.Errorf("where: %w", err)
+		}
+	}
+
+	if err := bindTimeLits(f.expr, loc); err != nil {
+		return fmt.Errorf("where: %w", err)
+	}
+
+	return nil
+}
+
+// bindExprKey resolves key to index of values, or to a slot of values if key is not in output.
+func (p *Processor) bindExprKey(k *filterKey, slots *exprSlots, outTimeFmt string, loc *time.Location) error {
+	k.idx = -1
+	k.timeFmt = ""
+
+	original := k.name
+
+	if strings.HasPrefix(k.name, ".") {
+		ck := p.ck(k.name)
+
+		for ik, i := range p.includeKeys {
+			if p.ck(ik) == ck {
+				k.idx = i
+
+				break
+			}
+		}
+	} else {
+		for i, pk := range p.keys {
+			if pk.replaced == k.name {
+				k.idx = i
+				original = pk.original
+
+				break
+			}
+		}
+
+		if k.idx == -1 {
+			return fmt.Errorf("unknown column %s", k.name)
+		}
+	}
+
+	if tf, ok := p.cfg.ParseTime[original]; ok && tf != "RAW" {
+		k.timeFmt = outTimeFmt
+		k.loc = loc
+	}
+
+	if k.idx >= 0 {
+		return nil
+	}
+
+	ck := p.ck(k.name)
+
+	if slot, ok := slots.byCK[ck]; ok {
+		k.slot = slot
+
+		return nil
+	}
+
+	k.slot = slots.n
+	slots.byCK[ck] = k.slot
+	slots.n++
+
+	slots.extra[newHasher().hashBytes([]byte(k.name))] = k.slot
+
+	p.flKeys.Range(func(pk uint64, fk flKey) bool {
+		if fk.canonical == ck {
+			slots.extra[pk] = k.slot
+		}
+
+		return true
+	})
+
+	return nil
+}
+
+// parseFilter compiles row filter expression.
+func parseFilter(expr string) (*rowFilter, error) {
+	e, keys, err := parseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rowFilter{expr: e, keys: keys}, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokKey
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type filterToken struct {
+	kind tokenKind
+	s    string
+	pos  int
+}
+
+var exprOps = []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!", "+", "-", "*", "/", "%"}
+
+// exprKeyStop lists characters that end unquoted key, minus is allowed in keys, so subtraction needs spaces around.
+const exprKeyStop = "=!<>&|()\"'~,+*/%"
+
+// lexExpr splits expression into tokens.
+func lexExpr(s string) ([]filterToken, error) {
+	var tokens []filterToken
+
+	i := 0
+
+	for i < len(s) {
+		c := s[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{kind: tokLParen, s: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{kind: tokRParen, s: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, filterToken{kind: tokComma, s: ",", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+
+			for j < len(s) && s[j] != c {
+				if s[j] == '\\' {
+					j++
+				}
+
+				j++
+			}
+
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+
+			// Single-quoted strings are raw, convenient for regular expressions.
+			v := strings.ReplaceAll(s[i+1:j], `\'`, `'`)
+
+			if c == '"' {
+				var err error
+
+				if v, err = strconv.Unquote(s[i : j+1]); err != nil {
+					return nil, fmt.Errorf("invalid string at %d: %w", i, err)
+				}
+			}
+
+			tokens = append(tokens, filterToken{kind: tokString, s: v, pos: i})
+			i = j + 1
+		case c == '.' && i+1 < len(s) && !unicode.IsDigit(rune(s[i+1])):
+			j := i + 1
+
+			for j < len(s) && !unicode.IsSpace(rune(s[j])) && !strings.ContainsRune(exprKeyStop, rune(s[j])) {
+				j++
+			}
+
+			tokens = append(tokens, filterToken{kind: tokKey, s: s[i:j], pos: i})
+			i = j
+		case c == '.' || unicode.IsDigit(rune(c)) || (c == '-' && i+1 < len(s) && !afterOperand(tokens) &&
+			(s[i+1] == '.' || unicode.IsDigit(rune(s[i+1])))):
+			j := i + 1
+
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.' || s[j] == 'e' || s[j] == 'E' ||
+				((s[j] == '+' || s[j] == '-') && (s[j-1] == 'e' || s[j-1] == 'E'))) {
+				j++
+			}
+
+			tokens = append(tokens, filterToken{kind: tokNumber, s: s[i:j], pos: i})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i + 1
+
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+
+			tokens = append(tokens, filterToken{kind: tokIdent, s: s[i:j], pos: i})
+			i = j
+		default:
+			found := false
+
+			for _, op := range exprOps {
+				if strings.HasPrefix(s[i:], op) {
+					tokens = append(tokens, filterToken{kind: tokOp, s: op, pos: i})
+					i += len(op)
+					found = true
+
+					break
+				}
+			}
+
+			if !found {
+				return nil, fmt.Errorf("unexpected %q at %d", string(c), i)
+			}
+		}
+	}
+
+	return append(tokens, filterToken{kind: tokEOF, pos: len(s)}), nil
+}
+
+// afterOperand checks if the last token ends an operand, so that following minus is an operator.
+func afterOperand(tokens []filterToken) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+
+	switch tokens[len(tokens)-1].kind {
+	case tokKey, tokIdent, tokString, tokNumber, tokRParen:
+		return true
+	default:
+		return false
+	}
+}
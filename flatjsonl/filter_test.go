@@ -0,0 +1,81 @@
+package flatjsonl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseFilter(t *testing.T) {
+	for expr, expErr := range map[string]string{
+		`.a == 1 && (.b != "x" || !exists(c)) && .d =~ '^\d+$'`:  "",
+		`1 < .a && .a.[0] >= -1.5e3 && .b == null && .c == true`: "",
+		`.a ==`:         "unexpected end of expression",
+		`.a == 1 &&`:    "unexpected end of expression",
+		`(.a == 1`:      "unexpected end of expression",
+		`.a == 1)`:      `unexpected ")" at 7`,
+		`.a = 1`:        `unexpected "=" at 3`,
+		`.a == "x`:      "unterminated string at 6",
+		`.a =~ 1`:       "regex match requires string at 3",
+		`.a =~ "("`:     "invalid regex at 6: error parsing regexp: missing closing ): `(`",
+		`1 == 2`:        "",
+		`.a == .b`:      "",
+		`exists(1)`:     "exists requires key at 0",
+		`.a == 1 .b`:    `unexpected ".b" at 8`,
+		`.a == 1 ; .b`:  `unexpected ";" at 8`,
+		`exists(.a) ==`: "unexpected end of expression",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := parseFilter(expr)
+			if expErr == "" {
+				require.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, expErr)
+			}
+		})
+	}
+}
+
+func Test_rowFilter_match(t *testing.T) {
+	l := &lineBuf{
+		values: []Value{
+			newNumberValue(9007199254740993, "9007199254740993"),
+			{Type: TypeString, String: "10"},
+			{Type: TypeString, String: "abc"},
+		},
+		exprValues: make([]Value, 1),
+	}
+
+	for expr, exp := range map[string]bool{
+		`.id == 9007199254740993`:    true,
+		`.id == 9007199254740992`:    false,
+		`.id > "9007199254740992"`:   true,
+		`.n == 10 && 9 < .n`:         true,
+		`.n == "10"`:                 true,
+		`.s == 10`:                   false,
+		`.s != 10`:                   true,
+		`.s > 10`:                    false,
+		`.s =~ '^a' && .s !~ 'z'`:    true,
+		`exists(.n) && !exists(.x)`:  true,
+		`.x == null && .n != null`:   true,
+		`len(.s) == 3 && .n * 2 > 1`: true,
+	} {
+		t.Run(expr, func(t *testing.T) {
+			f, err := parseFilter(expr)
+			require.NoError(t, err)
+
+			c, err := parseComputedColumn("c", expr)
+			require.NoError(t, err)
+
+			for _, k := range append(f.keys, c.keys...) {
+				k.idx = map[string]int{".id": 0, ".n": 1, ".s": 2, ".x": -1}[k.name]
+			}
+
+			assert.Equal(t, exp, f.match(l))
+
+			// Computed column of the same expression has the same result.
+			assert.Equal(t, Value{Type: TypeBool, Bool: exp}, c.expr.eval(l))
+		})
+	}
+}
@@ -19,8 +19,14 @@ type Flags struct {
 	Verbosity        int
 	ProgressInterval time.Duration
 	Input            string
+	InputInclude     string
+	InputExclude     string
+	InputSort        string
 	Output           string
 
+	SpillDir  string
+	SpillZstd bool
+
 	CSV     string
 	CSVNull string
 
@@ -40,23 +46,54 @@ type Flags struct {
 	Raw      string
 	RawDelim string
 
+	Rejects string
+
+	Checkpoint         string
+	CheckpointInterval time.Duration
+	Resume             bool
+
+	State  string
+	Append bool
+
 	MaxLines            int
 	OffsetLines         int
 	MaxLinesKeys        int
+	MaxErrorRate        float64
+	SampleRate          float64
+	SampleRateKeys      float64
+	SampleKey           string
+	SampleSize          int
+	SampleSeed          int64
 	FieldLimit          int
 	ChildrenLimitObject int
 	ChildrenLimitArray  int
 	KeyLimit            int
 	BufSize             int
+	BufSizeMax          int
+	LongLines           string
 
 	Config            string
+	SaveSchema        string
+	LoadSchema        string
 	GetKey            string
+	Where             string
 	ReplaceKeys       bool
 	StripKeys         bool
 	ExtractStrings    bool
+	InferTypes        bool
+	InferEpoch        bool
 	SkipZeroCols      bool
 	AddSequence       bool
+	AddFile           bool
+	AddLine           bool
+	AddOffset         bool
+	ArchiveMembers    string
+	InputMode         string
+	ArrayPath         string
+	Format            string
+	LogfmtDetectTypes bool
 	MatchLinePrefix   string
+	MatchLinePrefixes []string
 	CaseSensitiveKeys bool
 
 	ShowKeysFlat   bool
@@ -64,8 +101,20 @@ type Flags struct {
 	ShowKeysInfo   bool
 	ShowJSONSchema bool
 
-	Concurrency int
-	MemLimit    int
+	Concurrency      int
+	InputConcurrency int
+	SplitRanges      int
+	Unordered        bool
+	MemLimit         int
+
+	Follow        bool
+	FollowPoll    time.Duration
+	FlushInterval time.Duration
+
+	// inputs are expanded once by Parse.
+	inputs    []Input
+	inputsErr error
+	expanded  bool
 }
 
 // Register registers command-line flags.
@@ -73,7 +122,12 @@ func (f *Flags) Register() {
 	f.ChildrenLimitObject = 100
 	f.ChildrenLimitArray = 10
 
-	flag.StringVar(&f.Input, "input", "", "Input from JSONL files, comma-separated.")
+	flag.StringVar(&f.Input, "input", "", "Input from JSONL files, comma-separated, use - for STDIN.")
+	flag.StringVar(&f.InputInclude, "input-include", "", "File name patterns to include when walking input directories or globs, comma-separated, e.g. *.jsonl,*.jsonl.zst.")
+	flag.StringVar(&f.InputExclude, "input-exclude", "", "File name patterns to exclude when walking input directories or globs, comma-separated.")
+	flag.StringVar(&f.InputSort, "input-sort", InputSortName, "Order of files expanded from input directories or globs: name, mtime.")
+	flag.StringVar(&f.SpillDir, "spill-dir", "", "Directory for temporary files to keep STDIN data for second pass and decoded data of concurrently read inputs (default system temp dir).")
+	flag.BoolVar(&f.SpillZstd, "spill-zstd", false, "Compress temporary spill files with zstd.")
 	flag.StringVar(&f.Output, "output", "", "Output to a file (default <input>.csv).")
 	flag.StringVar(&f.CSV, "csv", "", "Output to CSV file (gzip encoded if ends with .gz).")
 	flag.StringVar(&f.CSVNull, "csv-null", "", "Render NULL/ABSENT values as this string in CSV output and DuckDB CLI CSV import; empty keeps blank fields.")
@@ -90,6 +144,12 @@ func (f *Flags) Register() {
 
 	flag.StringVar(&f.Raw, "raw", "", "Output to RAW file (column values are written as is without escaping, gzip encoded if ends with .gz).")
 	flag.StringVar(&f.RawDelim, "raw-delim", "", "RAW file column delimiter.")
+	flag.StringVar(&f.Rejects, "rejects", "", "Write lines that could not be processed to a file, with file name, line number and reason (tab-separated).")
+	flag.StringVar(&f.Checkpoint, "checkpoint", "", "Periodically save scanned keys and committed output position to a file to continue interrupted run with -resume (CSV, RAW, SQLite, PG dump outputs).")
+	flag.DurationVar(&f.CheckpointInterval, "checkpoint-interval", time.Minute, "Interval to commit output and save checkpoint.")
+	flag.BoolVar(&f.Resume, "resume", false, "Continue from -checkpoint file if it exists, skipping keys scan and rows that are already written.")
+	flag.StringVar(&f.State, "state", "", "File to keep ingested size of every input between runs, only new content of inputs is processed.")
+	flag.BoolVar(&f.Append, "append", false, "Append rows to existing outputs (CSV, RAW, SQLite, PG dump) and reuse schema from -state.")
 
 	flag.IntVar(&f.Verbosity, "verbosity", 1, "Show progress in STDERR, 0 disables status, 2 adds more metrics.")
 	flag.DurationVar(&f.ProgressInterval, "progress-interval", 5*time.Second, "Progress update interval.")
@@ -97,19 +157,49 @@ func (f *Flags) Register() {
 	flag.BoolVar(&f.ReplaceKeys, "replace-keys", false, "Use unique tail segment converted to snake_case as key.")
 	flag.BoolVar(&f.StripKeys, "strip-keys", false, "Trim leading whitespaces from the key, then cut key after the next whitespace.")
 	flag.BoolVar(&f.ExtractStrings, "extract-strings", false, "Check string values for JSON content and extract when available.")
+	flag.BoolVar(&f.InferTypes, "infer-types", false, "Infer types of string values (numbers, booleans, RFC3339 timestamps, UUIDs) during keys scanning.")
+	flag.BoolVar(&f.InferEpoch, "infer-epoch", false, "Infer timestamps from integer strings of 10 and 13 digits as seconds and milliseconds since epoch, with -infer-types.")
 	flag.StringVar(&f.GetKey, "get-key", "", "Add a single key to list of included keys.")
 	flag.StringVar(&f.Config, "config", "", "Configuration JSON value, path to JSON5 or YAML file.")
+	flag.StringVar(&f.Where, "where", "", "Row filter expression, e.g. '.level == \"error\" && .status >= 500', overrides where in config.")
+	flag.StringVar(&f.SaveSchema, "save-schema", "", "Save scanned keys, their types and replaces to a JSON file for reuse with -load-schema.")
+	flag.StringVar(&f.LoadSchema, "load-schema", "", "Load keys from a JSON file saved with -save-schema and skip keys scanning.")
 	flag.BoolVar(&f.ShowKeysFlat, "show-keys-flat", false, "Show all available keys as flat list.")
 	flag.BoolVar(&f.ShowKeysHier, "show-keys-hier", false, "Show all available keys as hierarchy.")
 	flag.BoolVar(&f.ShowKeysInfo, "show-keys-info", false, "Show keys, their replaces and types.")
 	flag.BoolVar(&f.ShowJSONSchema, "show-json-schema", false, "Show hierarchy as JSON schema.")
 	flag.BoolVar(&f.SkipZeroCols, "skip-zero-cols", false, "Skip columns with zero values.")
 	flag.BoolVar(&f.AddSequence, "add-sequence", false, "Add auto incremented sequence number.")
+	flag.BoolVar(&f.AddFile, "add-file", false, "Add file name (or archive member name) as _file column.")
+	flag.BoolVar(&f.AddLine, "add-line", false, "Add line number in file (or archive member) as _line column.")
+	flag.BoolVar(&f.AddOffset, "add-offset", false, "Add byte offset of line in uncompressed file (or archive member) as _offset column.")
+	flag.StringVar(&f.ArchiveMembers, "archive-members", "", "Comma-separated glob patterns to filter members of tar or zip archives, e.g. *.jsonl,logs/*.json.")
+	flag.StringVar(&f.InputMode, "input-mode", InputModeLines, "Input mode: lines (JSON value per line), stream (concatenated JSON values, e.g. pretty-printed), array (elements of top-level array).")
+	flag.StringVar(&f.ArrayPath, "array-path", "", "Path to array of records in array input mode, e.g. .Records, top-level array by default.")
+	flag.StringVar(&f.Format, "format", FormatJSON, "Line format: json, logfmt, docker (json-file envelope), cri (Kubernetes container logs), syslog (RFC 5424, RFC 3164).")
+	flag.BoolVar(&f.LogfmtDetectTypes, "logfmt-detect-types", false, "Detect numbers and booleans in unquoted logfmt values.")
+	flag.BoolVar(&f.Follow, "follow", false, "Keep reading lines appended to the last input file (like tail -F), requires fixed schema with includeKeys or -load-schema.")
+	flag.DurationVar(&f.FollowPoll, "follow-poll", time.Second, "Poll interval to check for new data in follow mode.")
+	flag.DurationVar(&f.FlushInterval, "flush-interval", 5*time.Second, "Interval to flush written rows to output in follow mode.")
 	flag.BoolVar(&f.CaseSensitiveKeys, "case-sensitive-keys", false, "Use case-sensitive keys (can fail for SQLite).")
-	flag.StringVar(&f.MatchLinePrefix, "match-line-prefix", "", "Regular expression to capture parts of line prefix (preceding JSON).")
+	flag.Func("match-line-prefix", "Regular expression to capture parts of line prefix (preceding JSON), named groups are used as column names, can be repeated for alternative patterns.", func(s string) error {
+		if f.MatchLinePrefix == "" {
+			f.MatchLinePrefix = s
+		} else {
+			f.MatchLinePrefixes = append(f.MatchLinePrefixes, s)
+		}
+
+		return nil
+	})
 	flag.IntVar(&f.MaxLines, "max-lines", 0, "Max number of lines to process.")
 	flag.IntVar(&f.OffsetLines, "offset-lines", 0, "Skip a number of first lines.")
 	flag.IntVar(&f.MaxLinesKeys, "max-lines-keys", 0, "Max number of lines to process when scanning keys.")
+	flag.Float64Var(&f.MaxErrorRate, "max-error-rate", 0, "Max share of rejected lines, e.g. 0.01, processing fails when exceeded, 0 for no limit.")
+	flag.Float64Var(&f.SampleRate, "sample-rate", 0, "Share of lines to keep in output, e.g. 0.01, 0 to keep all.")
+	flag.Float64Var(&f.SampleRateKeys, "sample-rate-keys", 0, "Share of lines to scan for keys, e.g. 0.01, 0 to scan all.")
+	flag.StringVar(&f.SampleKey, "sample-key", "", "Key to sample lines by a hash of its value with -sample-rate, lines with the same value are kept together, e.g. .request_id.")
+	flag.IntVar(&f.SampleSize, "sample-size", 0, "Number of rows to keep in output with reservoir sampling across all inputs, 0 for unlimited.")
+	flag.Int64Var(&f.SampleSeed, "sample-seed", 0, "Seed for sampling, 0 for random line sampling.")
 	flag.IntVar(&f.FieldLimit, "field-limit", 0, "Max length of field value, exceeding tail is truncated, 0 for unlimited.")
 	flag.Func("children-limit", "Max number of unique child keys, keep JSON is enabled for high cardinality parent, 0 for unlimited, comma-separated for <object>,<array>, default 100,10.", func(s string) error {
 		v := strings.Split(s, ",")
@@ -135,8 +225,13 @@ func (f *Flags) Register() {
 	})
 	flag.IntVar(&f.KeyLimit, "key-limit", 0, "Max length of key, exceeding tail is truncated, 0 for unlimited.")
 	flag.IntVar(&f.BufSize, "buf-size", 1e7, "Buffer size (max length of file line) in bytes.")
+	flag.IntVar(&f.BufSizeMax, "buf-size-max", 1e8, "Hard cap of buffer size in bytes for -long-lines grow.")
+	flag.StringVar(&f.LongLines, "long-lines", LongLinesFail, "Handling of lines longer than buffer size: fail, skip (lines are rejected), truncate, grow (buffer grows up to -buf-size-max, longer lines are skipped).")
 
 	flag.IntVar(&f.Concurrency, "concurrency", 2*runtime.NumCPU(), "Number of concurrent routines in reader.")
+	flag.IntVar(&f.InputConcurrency, "input-concurrency", 1, "Number of input files to decode concurrently, rows keep order of inputs if keys are scanned.")
+	flag.IntVar(&f.SplitRanges, "split-ranges", 0, "Split plain uncompressed files into a number of byte ranges aligned to lines to read them concurrently.")
+	flag.BoolVar(&f.Unordered, "unordered", false, "Allow writing rows in any order to read inputs concurrently without known line counts.")
 	flag.IntVar(&f.MemLimit, "mem-limit", 1000, "Heap in use soft limit, in MB.")
 
 	flag.Func("geo-ip-db", "MaxMind GeoIP db file for GEOIP extractor, you can provide multiple files", f.LoadGeoIPDB)
@@ -170,15 +265,25 @@ func (f *Flags) LoadNetrieDB(fn string) error {
 	return nil
 }
 
-// Parse parses and prepares command-line flags.
+// Parse parses and prepares command-line flags, inputs are expanded and kept for later Inputs calls.
+//
+// Use ExpandInputs after Parse to check for invalid or unmatched inputs.
 func (f *Flags) Parse() {
 	flag.Parse()
 
-	if f.Output == "" && !f.ShowKeysHier && !f.ShowKeysFlat && !f.ShowKeysInfo && !f.ShowJSONSchema {
-		inputs := f.Inputs()
+	inputs, err := f.expandInputs()
+
+	f.inputs = inputs
+	f.inputsErr = err
+	f.expanded = true
 
+	if f.Output == "" && !f.ShowKeysHier && !f.ShowKeysFlat && !f.ShowKeysInfo && !f.ShowJSONSchema {
 		if len(inputs) > 0 && f.CSV == "" && f.Parquet == "" && f.DuckDB == "" && f.SQLite == "" && f.Raw == "" && f.PGDump == "" {
-			f.Output = inputs[0].FileName + ".csv"
+			if inputs[0].FileName == StdinFileName {
+				f.Output = "stdin.csv"
+			} else {
+				f.Output = inputs[0].FileName + ".csv"
+			}
 		}
 	}
 
@@ -262,9 +367,41 @@ func (f *Flags) PrepareOutput() {
 	}
 }
 
-// Inputs returns list of file names to read.
+// Inputs returns list of file names to read, expansion error is printed.
+//
+// Use ExpandInputs to handle the error.
 func (f *Flags) Inputs() []Input {
-	inputs := flag.Args()
+	inputs, err := f.ExpandInputs()
+	if err != nil {
+		println(err.Error())
+	}
+
+	return inputs
+}
+
+// ExpandInputs returns list of file names to read.
+//
+// Glob patterns (including **) and directories are expanded to files.
+// File name "-" stands for STDIN, it is also used when no inputs are provided and STDIN is a pipe.
+// Error names every input that did not match any file.
+func (f *Flags) ExpandInputs() ([]Input, error) {
+	if f.expanded {
+		return f.inputs, f.inputsErr
+	}
+
+	return f.expandInputs()
+}
+
+func (f *Flags) expandInputs() ([]Input, error) {
+	var inputs []string
+
+	for _, fn := range flag.Args() {
+		if strings.HasPrefix(fn, "-") && fn != StdinFileName {
+			break
+		}
+
+		inputs = append(inputs, fn)
+	}
 
 	if f.Input != "" {
 		inputs = append(inputs, strings.Split(f.Input, ",")...)
@@ -272,13 +409,44 @@ func (f *Flags) Inputs() []Input {
 
 	res := make([]Input, 0, len(inputs))
 
+	e, err := newInputExpander(f.InputInclude, f.InputExclude, f.InputSort)
+	if err != nil {
+		return nil, err
+	}
+
+	var unmatched []string
+
+	seen := map[string]bool{}
+
 	for _, fn := range inputs {
-		if strings.HasPrefix(fn, "-") {
-			break
+		files, err := e.expand(fn)
+		if err != nil {
+			return nil, fmt.Errorf("expand input %s: %w", fn, err)
 		}
 
-		res = append(res, Input{FileName: fn})
+		if len(files) == 0 {
+			unmatched = append(unmatched, fn)
+		}
+
+		for _, fn := range files {
+			if seen[fn] {
+				continue
+			}
+
+			seen[fn] = true
+
+			res = append(res, Input{FileName: fn})
+		}
+	}
+
+	// STDIN is only implied if no inputs are provided.
+	if len(inputs) == 0 && stdinIsPipe() {
+		res = append(res, Input{FileName: StdinFileName})
+	}
+
+	if len(unmatched) > 0 {
+		return nil, fmt.Errorf("no files matched input %s", strings.Join(unmatched, ", "))
 	}
 
-	return res
+	return res, nil
 }
@@ -32,18 +32,21 @@ type FastWalker struct {
 	FnString     func(seq int64, flatPath []byte, pl int, path []string, value []byte) []extractor
 	FnBool       func(seq int64, flatPath []byte, pl int, path []string, value bool)
 	FnNull       func(seq int64, flatPath []byte, pl int, path []string)
+	FnList       func(seq int64, flatPath []byte, pl int, path []string, values []Value)
 
 	WantPath       bool
 	ExtractStrings bool
 	KeepJSON       map[string]bool
 	KeepJSONRegex  []*regexp.Regexp
 	extractJSON    map[string]bool
+	arrays         map[string]arraySummary
 
 	buf []byte
 }
 
 func (fv *FastWalker) configure(p *Processor) {
 	fv.ExtractStrings = p.f.ExtractStrings
+	fv.arrays = p.arrays
 
 	fv.extractJSON = make(map[string]bool)
 
@@ -121,6 +124,12 @@ func (fv *FastWalker) walkFastJSONArray(seq int64, flatPath []byte, pl int, path
 		fv.FnArrayStop(seq, flatPath, pl, path)
 	}
 
+	if s, ok := fv.arrays[string(flatPath)]; ok {
+		fv.walkArraySummary(seq, flatPath, pl, path, a, s)
+
+		return
+	}
+
 	pl = len(flatPath)
 
 	if len(fv.KeepJSON) > 0 && fv.KeepJSON[string(flatPath)] { //nolint:dupl
@@ -217,6 +226,11 @@ func (fv *FastWalker) walkFastJSONString(seq int64, flatPath []byte, pl int, pat
 		panic(fmt.Sprintf("BUG: failed to use JSON string: %v", err))
 	}
 
+	fv.WalkString(seq, flatPath, pl, path, s)
+}
+
+// WalkString passes string value to FnString and walks into extracted contents if available.
+func (fv *FastWalker) WalkString(seq int64, flatPath []byte, pl int, path []string, s []byte) {
 	extractors := fv.FnString(seq, flatPath, pl, path, s)
 
 	if len(extractors) > 0 { //nolint:nestif
@@ -329,15 +343,15 @@ func (j *jsonSchema) AddType(t Type) {
 	var tt string
 
 	switch t {
-	case TypeString:
+	case TypeString, TypeTimestamp, TypeUUID:
 		tt = "string"
-	case TypeInt:
+	case TypeInt, TypeDecimal:
 		tt = "integer"
 	case TypeFloat:
 		tt = "number"
 	case TypeBool:
 		tt = "boolean"
-	case TypeArray:
+	case TypeArray, TypeList:
 		tt = "array"
 	case TypeObject:
 		tt = "object"
@@ -0,0 +1,138 @@
+package flatjsonl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// FollowReader reads a growing file, like tail -F.
+//
+// When end of file is reached, it waits for appended data instead of returning io.EOF.
+// If file is rotated (path points to a new file), reading continues from the beginning of the new file.
+// If file is truncated, reading continues from the beginning.
+// Reading ends with io.EOF when stop channel is closed and available data is consumed.
+type FollowReader struct {
+	fn     string
+	poll   time.Duration
+	stop   <-chan struct{}
+	f      *os.File
+	offset int64
+}
+
+// NewFollowReader opens file for following.
+func NewFollowReader(fn string, poll time.Duration, stop <-chan struct{}) (*FollowReader, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", fn, err)
+	}
+
+	if poll <= 0 {
+		poll = time.Second
+	}
+
+	return &FollowReader{
+		fn:   fn,
+		poll: poll,
+		stop: stop,
+		f:    f,
+	}, nil
+}
+
+// Compression implements Input, followed file is not sniffed to avoid waiting for enough data.
+func (r *FollowReader) Compression() string {
+	return CompressionNone
+}
+
+// Size implements Input, it returns the number of bytes read from current file.
+func (r *FollowReader) Size() int64 {
+	return r.offset
+}
+
+// Reset implements Input.
+func (r *FollowReader) Reset() {}
+
+// Read implements io.Reader.
+func (r *FollowReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.f.Read(p)
+		r.offset += int64(n)
+
+		if n > 0 {
+			return n, nil
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		reopened, err := r.checkRotation()
+		if err != nil {
+			return 0, err
+		}
+
+		if reopened {
+			continue
+		}
+
+		select {
+		case <-r.stop:
+			return 0, io.EOF
+		case <-time.After(r.poll):
+		}
+	}
+}
+
+// checkRotation switches to the new file if path was rotated and rewinds truncated file.
+func (r *FollowReader) checkRotation() (bool, error) {
+	fi, err := os.Stat(r.fn)
+	if err != nil {
+		// File may be temporarily missing during rotation.
+		return false, nil //nolint:nilerr
+	}
+
+	cur, err := r.f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat followed file %s: %w", r.fn, err)
+	}
+
+	if !os.SameFile(fi, cur) {
+		// Data that was appended to the old file just before rotation is drained first.
+		if cur.Size() > r.offset {
+			return true, nil
+		}
+
+		f, err := os.Open(r.fn)
+		if err != nil {
+			return false, nil //nolint:nilerr // New file may not be created yet.
+		}
+
+		if err := r.f.Close(); err != nil {
+			println("failed to close rotated file:", err.Error())
+		}
+
+		r.f = f
+		r.offset = 0
+
+		return true, nil
+	}
+
+	if fi.Size() < r.offset {
+		if _, err := r.f.Seek(0, io.SeekStart); err != nil {
+			return false, fmt.Errorf("failed to rewind truncated file %s: %w", r.fn, err)
+		}
+
+		r.offset = 0
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Close closes current file.
+func (r *FollowReader) Close() error {
+	return r.f.Close()
+}
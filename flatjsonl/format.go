@@ -0,0 +1,307 @@
+package flatjsonl
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vearutop/fastjson"
+)
+
+// Line formats.
+const (
+	// FormatJSON expects JSON value in line, optionally preceded by a prefix.
+	FormatJSON = "json"
+	// FormatLogfmt expects key=value pairs in line, e.g. level=info msg="hello world" dur=12ms.
+	FormatLogfmt = "logfmt"
+	// FormatDocker expects Docker json-file envelope, e.g. {"log":"{...}\n","stream":"stdout","time":"..."}.
+	FormatDocker = "docker"
+	// FormatCRI expects CRI (Kubernetes container runtime) envelope, e.g. 2024-01-01T00:00:00Z stdout F {...}.
+	FormatCRI = "cri"
+	// FormatSyslog expects RFC 5424 or RFC 3164 syslog envelope.
+	FormatSyslog = "syslog"
+)
+
+var formats = []string{FormatJSON, FormatLogfmt, FormatDocker, FormatCRI, FormatSyslog}
+
+// formatPrefixes are envelope patterns of presets that build on prefix matching.
+var formatPrefixes = map[string][]string{
+	FormatCRI: {
+		`^(?P<time>\S+) (?P<stream>stdout|stderr) (?P<tag>[FP]) ?`,
+	},
+	FormatSyslog: {
+		// RFC 5424.
+		`^<(?P<pri>\d{1,3})>(?P<version>\d{1,2}) (?P<time>\S+) (?P<host>\S+) (?P<app>\S+) (?P<procid>\S+) (?P<msgid>\S+) (?P<sd>-|(?:\[(?:[^\]\\]|\\.)*\])+) ?`,
+		// RFC 3164.
+		`^<(?P<pri>\d{1,3})>(?P<time>\w{3} [ \d]\d \d\d:\d\d:\d\d) (?P<host>\S+) (?P<app>[^:\[\s]+)(?:\[(?P<procid>\d+)\])?: ?`,
+	},
+}
+
+func checkFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+
+	for _, f := range formats {
+		if f == format {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unexpected format %q, one of %s expected", format, strings.Join(formats, ", "))
+}
+
+// formatPrefixRegexps returns compiled envelope patterns of a format preset.
+func formatPrefixRegexps(format string) []*regexp.Regexp {
+	var res []*regexp.Regexp
+
+	for _, p := range formatPrefixes[format] {
+		res = append(res, regexp.MustCompile(p))
+	}
+
+	return res
+}
+
+// isEnvelope checks if format has payload after envelope prefix.
+func (rd *Reader) isEnvelope() bool {
+	return rd.Format == FormatCRI || rd.Format == FormatSyslog
+}
+
+// envelopeLine passes envelope fields to walkFn and returns JSON payload.
+// Non-JSON payload is passed to walkFn as ._message and nil is returned.
+func (rd *Reader) envelopeLine(seq int64, line []byte, walkFn func(seq int64, flatPath []byte, pl int, path []string, value []byte) []extractor) []byte {
+	end := rd.walkPrefix(seq, line, walkFn)
+
+	return rd.payload(seq, line[end:], walkFn)
+}
+
+func (rd *Reader) payload(seq int64, payload []byte, walkFn func(seq int64, flatPath []byte, pl int, path []string, value []byte) []extractor) []byte {
+	payload = bytes.TrimPrefix(payload, []byte("\xef\xbb\xbf")) // Syslog message may start with BOM.
+	payload = bytes.TrimSpace(payload)
+
+	if len(payload) > 1 && payload[0] == '{' {
+		return payload
+	}
+
+	if len(payload) > 0 {
+		walkFn(seq, []byte("._message"), 0, []string{"_message"}, payload)
+	}
+
+	return nil
+}
+
+// dockerLine unwraps Docker json-file envelope, it passes envelope fields to walker
+// and returns JSON payload of log field.
+func (rd *Reader) dockerLine(w *syncWorker, seq int64) []byte {
+	v, err := w.p.ParseBytes(w.line)
+	if err != nil {
+		rd.reject(w, rejectMalformedEnvelope, err)
+
+		if rd.OnError != nil {
+			rd.OnError(fmt.Errorf("malformed Docker envelope at line %d: %w: %s", seq, err, string(w.line)))
+		}
+
+		return nil
+	}
+
+	o, err := v.Object()
+	if err != nil {
+		rd.reject(w, rejectMalformedEnvelope, err)
+
+		if rd.OnError != nil {
+			rd.OnError(fmt.Errorf("unexpected Docker envelope at line %d: %w: %s", seq, err, string(w.line)))
+		}
+
+		return nil
+	}
+
+	w.payload = w.payload[:0]
+
+	o.Visit(func(key []byte, v *fastjson.Value) {
+		if string(key) == "log" {
+			// Payload is copied, because parser is reused for it.
+			w.payload = append(w.payload, v.GetStringBytes()...)
+
+			return
+		}
+
+		flatPath := append(w.flatPath[:0], "._prefix."...)
+		flatPath = append(flatPath, key...)
+
+		var path []string
+
+		if w.walker.WantPath {
+			path = append(w.path[:0], "_prefix", string(key))
+		}
+
+		w.walker.WalkFastJSON(seq, flatPath, 0, path, v)
+	})
+
+	return rd.payload(seq, w.payload, w.walker.FnString)
+}
+
+// criScanner reassembles partial (P) CRI lines.
+type criScanner struct {
+	lineScanner
+	maxSize int
+
+	partial map[string][]byte
+	pending []string
+	joined  []byte
+	cur     []byte
+	err     error
+
+	// offsets keep positions of the first parts of partial lines.
+	offsets map[string]int64
+	offset  int64
+}
+
+func newCRIScanner(s lineScanner, maxSize int) *criScanner {
+	return &criScanner{
+		lineScanner: s,
+		maxSize:     maxSize,
+		partial:     map[string][]byte{},
+		offsets:     map[string]int64{},
+	}
+}
+
+// splitCRI returns stream name, position of tag and position of message in CRI line.
+func splitCRI(line []byte) (stream string, tag int, msg int) {
+	sp1 := bytes.IndexByte(line, ' ')
+	if sp1 == -1 {
+		return "", -1, -1
+	}
+
+	sp2 := bytes.IndexByte(line[sp1+1:], ' ')
+	if sp2 == -1 {
+		return "", -1, -1
+	}
+
+	sp2 += sp1 + 1
+	tag = sp2 + 1
+
+	if tag >= len(line) {
+		return "", -1, -1
+	}
+
+	msg = tag + 1
+	if msg < len(line) && line[msg] == ' ' {
+		msg++
+	}
+
+	return string(line[sp1+1 : sp2]), tag, msg
+}
+
+// Scan implements lineScanner.
+func (s *criScanner) Scan() bool {
+	for {
+		if !s.lineScanner.Scan() {
+			// Flush unfinished partial lines.
+			for len(s.pending) > 0 {
+				stream := s.pending[0]
+				s.pending = s.pending[1:]
+
+				if buf := s.partial[stream]; len(buf) > 0 {
+					s.joined = append(s.joined[:0], buf...)
+					s.cur = s.joined
+					s.offset = s.offsets[stream]
+					s.partial[stream] = buf[:0]
+
+					return true
+				}
+			}
+
+			return false
+		}
+
+		line := s.lineScanner.Bytes()
+
+		var offset int64
+		if o, ok := s.lineScanner.(offsetter); ok {
+			offset = o.lineOffset()
+		}
+
+		stream, tag, msg := splitCRI(line)
+		if tag == -1 {
+			s.cur = line
+			s.offset = offset
+
+			return true
+		}
+
+		buf := s.partial[stream]
+
+		if line[tag] == 'P' {
+			if len(buf) == 0 {
+				// Header of the first part is used for the whole line.
+				buf = append(buf, line[:msg]...)
+				buf[tag] = 'F'
+				s.offsets[stream] = offset
+
+				s.pending = append(s.pending, stream)
+			}
+
+			buf = append(buf, line[msg:]...)
+			s.partial[stream] = buf
+
+			if s.maxSize > 0 && len(buf) > s.maxSize {
+				s.err = fmt.Errorf("partial CRI line is too long: %d bytes", len(buf))
+
+				return false
+			}
+
+			continue
+		}
+
+		if len(buf) == 0 {
+			s.cur = line
+			s.offset = offset
+
+			return true
+		}
+
+		s.joined = append(append(s.joined[:0], buf...), line[msg:]...)
+		s.cur = s.joined
+		s.offset = s.offsets[stream]
+		s.partial[stream] = buf[:0]
+
+		for i, p := range s.pending {
+			if p == stream {
+				s.pending = append(s.pending[:i], s.pending[i+1:]...)
+
+				break
+			}
+		}
+
+		return true
+	}
+}
+
+// Bytes implements lineScanner.
+func (s *criScanner) Bytes() []byte {
+	return s.cur
+}
+
+func (s *criScanner) lineOffset() int64 {
+	return s.offset
+}
+
+// longLine implements longLiner for lines that are not reassembled from parts.
+func (s *criScanner) longLine() (int, bool) {
+	ll, ok := s.lineScanner.(longLiner)
+	if !ok || len(s.cur) == 0 || len(s.cur) != len(s.lineScanner.Bytes()) || &s.cur[0] != &s.lineScanner.Bytes()[0] {
+		return 0, false
+	}
+
+	return ll.longLine()
+}
+
+// Err implements lineScanner.
+func (s *criScanner) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	return s.lineScanner.Err()
+}
@@ -0,0 +1,277 @@
+package flatjsonl
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// valueKinds is a set of kinds of values seen in a key during scanning with -infer-types.
+type valueKinds uint16
+
+// Kinds of values.
+const (
+	kindInt         valueKinds = 1 << iota // Integer that fits int64.
+	kindDecimal                            // Integer that does not fit int64, but fits decimal precision.
+	kindFloat                              // Number with fraction.
+	kindBool                               // Bool or "true"/"false" string.
+	kindTime                               // RFC3339 string.
+	kindEpoch                              // Integer string of 10 digits, seconds since epoch.
+	kindEpochMillis                        // Integer string of 13 digits, milliseconds since epoch.
+	kindUUID                               // UUID string.
+	kindString                             // String that does not conform to other kinds.
+)
+
+const kindNumbers = kindInt | kindDecimal | kindFloat | kindEpoch | kindEpochMillis
+
+// valueKindNames are names of kinds in schema.
+var valueKindNames = []struct {
+	k    valueKinds
+	name string
+}{
+	{kindInt, "int"},
+	{kindDecimal, "decimal"},
+	{kindFloat, "float"},
+	{kindBool, "bool"},
+	{kindTime, "time"},
+	{kindEpoch, "epoch"},
+	{kindEpochMillis, "epochMillis"},
+	{kindUUID, "uuid"},
+	{kindString, "string"},
+}
+
+// names returns names of kinds in the set.
+func (k valueKinds) names() []string {
+	var names []string
+
+	for _, n := range valueKindNames {
+		if k&n.k != 0 {
+			names = append(names, n.name)
+		}
+	}
+
+	return names
+}
+
+// parseValueKinds makes a set of kinds from names.
+func parseValueKinds(names []string) (valueKinds, error) {
+	var k valueKinds
+
+	for _, name := range names {
+		found := false
+
+		for _, n := range valueKindNames {
+			if n.name == name {
+				k |= n.k
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return 0, fmt.Errorf("unknown value kind %q", name)
+		}
+	}
+
+	return k, nil
+}
+
+// inferredType returns common type of kinds, it is string if kinds do not conform to a single type.
+// Epoch timestamps mixed with other numbers are numbers.
+func (k valueKinds) inferredType() (t Type, epochMillis bool) {
+	switch {
+	case k&kindString != 0:
+		return TypeString, false
+	case k == kindBool:
+		return TypeBool, false
+	case k == kindUUID:
+		return TypeUUID, false
+	case k == kindTime, k == kindEpoch:
+		return TypeTimestamp, false
+	case k == kindEpochMillis:
+		return TypeTimestamp, true
+	case k&^kindNumbers != 0:
+		return TypeString, false
+	case k&kindDecimal != 0:
+		return TypeDecimal, false
+	case k&kindFloat != 0:
+		return TypeFloat, false
+	default:
+		return TypeInt, false
+	}
+}
+
+// numberKind returns kind of JSON number of type t.
+func numberKind(t Type) valueKinds {
+	switch t { //nolint:exhaustive
+	case TypeInt:
+		return kindInt
+	case TypeDecimal:
+		return kindDecimal
+	default:
+		return kindFloat
+	}
+}
+
+// stringKind detects kind of string value, empty string has no kind.
+func stringKind(s []byte) valueKinds {
+	switch {
+	case len(s) == 0:
+		return 0
+	case isJSONNumber(s):
+		if _, err := strconv.ParseInt(string(s), 10, 64); err == nil {
+			switch {
+			case len(s) == 10 && s[0] != '-':
+				return kindEpoch
+			case len(s) == 13 && s[0] != '-':
+				return kindEpochMillis
+			default:
+				return kindInt
+			}
+		} else if errors.Is(err, strconv.ErrRange) {
+			if !fitsDecimal(s) {
+				return kindString
+			}
+
+			return kindDecimal
+		}
+
+		return kindFloat
+	case string(s) == "true" || string(s) == "false":
+		return kindBool
+	case isUUID(s):
+		return kindUUID
+	case len(s) >= len(time.DateOnly) && s[4] == '-':
+		if _, err := time.Parse(time.RFC3339Nano, string(s)); err == nil {
+			return kindTime
+		}
+	}
+
+	return kindString
+}
+
+// isJSONNumber checks if s is a number literal in JSON syntax, so that values like "007", "+1" or "NaN" stay strings.
+func isJSONNumber(s []byte) bool {
+	i := 0
+
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+
+	digits := func() int {
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+
+		return i - start
+	}
+
+	n := digits()
+	if n == 0 || (n > 1 && s[i-n] == '0') {
+		return false
+	}
+
+	if i < len(s) && s[i] == '.' {
+		i++
+
+		if digits() == 0 {
+			return false
+		}
+	}
+
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+
+		if digits() == 0 {
+			return false
+		}
+	}
+
+	return i == len(s)
+}
+
+// isUUID checks if s is a UUID in canonical 8-4-4-4-12 hex form.
+func isUUID(s []byte) bool {
+	_, ok := parseUUID(s)
+
+	return ok
+}
+
+func parseUUID(s []byte) ([16]byte, bool) {
+	var u [16]byte
+
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, false
+	}
+
+	b := make([]byte, 0, 32)
+	b = append(b, s[:8]...)
+	b = append(b, s[9:13]...)
+	b = append(b, s[14:18]...)
+	b = append(b, s[19:23]...)
+	b = append(b, s[24:]...)
+
+	if _, err := hex.Decode(u[:], b); err != nil {
+		return u, false
+	}
+
+	return u, true
+}
+
+// scanKind records kind of value in a key for type inference.
+func (p *Processor) scanKind(pk uint64, kind valueKinds) {
+	if k, ok := p.flKeys.Load(pk); !ok || kind == 0 || k.kinds&kind == kind {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k, _ := p.flKeys.Load(pk)
+	k.kinds |= kind
+
+	p.flKeys.Store(pk, k)
+}
+
+// inferTypes sets types of string keys from kinds of their values, keys with parseTime are not changed.
+func (p *Processor) inferTypes() {
+	if !p.f.InferTypes {
+		return
+	}
+
+	for i, k := range p.keys {
+		if k.t != TypeString || k.kinds == 0 || k.computed != nil {
+			continue
+		}
+
+		if _, ok := p.cfg.ParseTime[k.original]; ok {
+			continue
+		}
+
+		kinds := k.kinds
+
+		// Integers that look like epoch timestamps are often IDs.
+		if !p.f.InferEpoch && kinds&(kindEpoch|kindEpochMillis) != 0 {
+			kinds = kinds&^(kindEpoch|kindEpochMillis) | kindInt
+		}
+
+		t, epochMillis := kinds.inferredType()
+		if t == TypeString {
+			continue
+		}
+
+		k.t = t
+		k.inferred = true
+		k.epochMillis = epochMillis
+
+		p.keys[i] = k
+	}
+}
@@ -0,0 +1,62 @@
+package flatjsonl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_stringKind(t *testing.T) {
+	for s, exp := range map[string]valueKinds{
+		"":                     0,
+		"200":                  kindInt,
+		"-12":                  kindInt,
+		"1.5":                  kindFloat,
+		"1e3":                  kindFloat,
+		"18446744073709551615": kindDecimal,
+		"123456789012345678901234567890123456789": kindString,
+		"1714557600":                           kindEpoch,
+		"1714557600123":                        kindEpochMillis,
+		"007":                                  kindString,
+		"+1":                                   kindString,
+		"NaN":                                  kindString,
+		"1.":                                   kindString,
+		"true":                                 kindBool,
+		"True":                                 kindString,
+		"2024-05-01T10:00:00Z":                 kindTime,
+		"2024-05-01T10:00:00.123+02:00":        kindTime,
+		"2024-05-01 10:00:00":                  kindString,
+		"0b6e8d1a-3c4f-4a5e-9f7b-2d1c0e9a8b7c": kindUUID,
+		"0b6e8d1a-3c4f-4a5e-9f7b-2d1c0e9a8b7g": kindString,
+		"hello":                                kindString,
+	} {
+		assert.Equal(t, exp, stringKind([]byte(s)), s)
+	}
+}
+
+func Test_valueKinds_inferredType(t *testing.T) {
+	for _, tc := range []struct {
+		kinds       valueKinds
+		exp         Type
+		epochMillis bool
+	}{
+		{kinds: kindInt, exp: TypeInt},
+		{kinds: kindInt | kindFloat, exp: TypeFloat},
+		{kinds: kindInt | kindDecimal, exp: TypeDecimal},
+		{kinds: kindFloat | kindDecimal, exp: TypeDecimal},
+		{kinds: kindEpoch, exp: TypeTimestamp},
+		{kinds: kindEpochMillis, exp: TypeTimestamp, epochMillis: true},
+		{kinds: kindEpoch | kindInt, exp: TypeInt},
+		{kinds: kindEpoch | kindEpochMillis, exp: TypeInt},
+		{kinds: kindTime, exp: TypeTimestamp},
+		{kinds: kindTime | kindEpoch, exp: TypeString},
+		{kinds: kindBool, exp: TypeBool},
+		{kinds: kindBool | kindInt, exp: TypeString},
+		{kinds: kindUUID, exp: TypeUUID},
+		{kinds: kindInt | kindString, exp: TypeString},
+	} {
+		typ, epochMillis := tc.kinds.inferredType()
+		assert.Equal(t, tc.exp, typ, tc.kinds)
+		assert.Equal(t, tc.epochMillis, epochMillis, tc.kinds)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package flatjsonl
+
+import "os"
+
+// fileInode returns 0 as inode is not available, replaced files are detected by size only.
+func fileInode(_ os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package flatjsonl
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns inode number of file, it is used to detect replaced files.
+func fileInode(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino) //nolint:unconvert // Type of Ino differs between platforms.
+	}
+
+	return 0
+}
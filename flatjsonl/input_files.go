@@ -0,0 +1,225 @@
+package flatjsonl
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Sorting orders of expanded input files.
+const (
+	InputSortName  = "name"
+	InputSortMtime = "mtime"
+)
+
+// inputExpander resolves glob patterns and directories into a list of files.
+type inputExpander struct {
+	include []string
+	exclude []string
+	sortBy  string
+}
+
+func newInputExpander(include, exclude, sortBy string) (*inputExpander, error) {
+	e := &inputExpander{
+		include: splitPatterns(include),
+		exclude: splitPatterns(exclude),
+		sortBy:  sortBy,
+	}
+
+	switch sortBy {
+	case "", InputSortName, InputSortMtime:
+	default:
+		return nil, fmt.Errorf("unexpected input sort order %q, %s or %s expected", sortBy, InputSortName, InputSortMtime)
+	}
+
+	for _, p := range append(e.include, e.exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("bad file name pattern %q: %w", p, err)
+		}
+	}
+
+	return e, nil
+}
+
+func splitPatterns(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	var res []string
+
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			res = append(res, p)
+		}
+	}
+
+	return res
+}
+
+// expand returns file names for a single input argument.
+// Plain file names are returned as is, directories are walked recursively,
+// glob patterns (with ** for any number of directories) are matched against file system.
+func (e *inputExpander) expand(name string) ([]string, error) {
+	if name == StdinFileName {
+		return []string{name}, nil
+	}
+
+	if !hasGlobMeta(name) {
+		fi, err := os.Stat(name)
+		if err != nil || !fi.IsDir() {
+			return []string{name}, nil //nolint:nilerr // Missing file is reported later by processor.
+		}
+
+		return e.walk(name, nil)
+	}
+
+	base, pattern := splitGlobBase(name)
+
+	return e.walk(base, pattern)
+}
+
+func (e *inputExpander) walk(root string, pattern []string) ([]string, error) {
+	type file struct {
+		name  string
+		mtime int64
+	}
+
+	var files []file
+
+	err := filepath.WalkDir(root, func(fn string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		if pattern != nil {
+			rel, err := filepath.Rel(root, fn)
+			if err != nil {
+				return err
+			}
+
+			if !matchGlobSegments(pattern, strings.Split(filepath.ToSlash(rel), "/")) {
+				return nil
+			}
+		}
+
+		if !e.matchName(d.Name()) {
+			return nil
+		}
+
+		f := file{name: fn}
+
+		if e.sortBy == InputSortMtime {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			f.mtime = fi.ModTime().UnixNano()
+		}
+
+		files = append(files, f)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s: %w", root, err)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if e.sortBy == InputSortMtime && files[i].mtime != files[j].mtime {
+			return files[i].mtime < files[j].mtime
+		}
+
+		return files[i].name < files[j].name
+	})
+
+	res := make([]string, 0, len(files))
+	for _, f := range files {
+		res = append(res, f.name)
+	}
+
+	return res, nil
+}
+
+func (e *inputExpander) matchName(name string) bool {
+	for _, p := range e.exclude {
+		if ok, _ := path.Match(p, name); ok {
+			return false
+		}
+	}
+
+	if len(e.include) == 0 {
+		return true
+	}
+
+	for _, p := range e.include {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// splitGlobBase splits pattern into static base directory and a list of pattern segments.
+func splitGlobBase(pattern string) (base string, segments []string) {
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+
+	i := 0
+	for ; i < len(parts)-1; i++ {
+		if hasGlobMeta(parts[i]) {
+			break
+		}
+	}
+
+	base = strings.Join(parts[:i], "/")
+
+	switch {
+	case base == "" && i > 0: // Absolute path.
+		base = "/"
+	case base == "":
+		base = "."
+	}
+
+	return filepath.FromSlash(base), parts[i:]
+}
+
+// matchGlobSegments checks if path segments match the pattern, ** matches any number of segments.
+func matchGlobSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchGlobSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+
+	return matchGlobSegments(pattern[1:], segments[1:])
+}
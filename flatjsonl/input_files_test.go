@@ -0,0 +1,120 @@
+package flatjsonl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputExpander_expand(t *testing.T) {
+	dir := t.TempDir()
+
+	files := []string{
+		"logs/2024/01/b.jsonl.zst",
+		"logs/2024/01/a.jsonl.zst",
+		"logs/2024/02/c.jsonl.zst",
+		"logs/2024/02/c.txt",
+		"logs/2023/12/d.jsonl.zst",
+		"logs/e.jsonl",
+	}
+
+	now := time.Now()
+
+	for i, fn := range files {
+		fn = filepath.Join(dir, fn)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fn), 0o700))
+		require.NoError(t, os.WriteFile(fn, []byte("{}\n"), 0o600))
+
+		// Older files first in the list.
+		mt := now.Add(time.Duration(i-len(files)) * time.Minute)
+		require.NoError(t, os.Chtimes(fn, mt, mt))
+	}
+
+	rel := func(names []string) []string {
+		res := make([]string, 0, len(names))
+
+		for _, n := range names {
+			r, err := filepath.Rel(dir, n)
+			require.NoError(t, err)
+
+			res = append(res, filepath.ToSlash(r))
+		}
+
+		return res
+	}
+
+	e, err := newInputExpander("", "", "")
+	require.NoError(t, err)
+
+	res, err := e.expand(filepath.Join(dir, "logs/2024/*/*.jsonl.zst"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"logs/2024/01/a.jsonl.zst",
+		"logs/2024/01/b.jsonl.zst",
+		"logs/2024/02/c.jsonl.zst",
+	}, rel(res))
+
+	res, err = e.expand(filepath.Join(dir, "**/*.jsonl*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"logs/2023/12/d.jsonl.zst",
+		"logs/2024/01/a.jsonl.zst",
+		"logs/2024/01/b.jsonl.zst",
+		"logs/2024/02/c.jsonl.zst",
+		"logs/e.jsonl",
+	}, rel(res))
+
+	e, err = newInputExpander("*.zst,*.txt", "d.*,*.txt", InputSortMtime)
+	require.NoError(t, err)
+
+	res, err = e.expand(filepath.Join(dir, "logs"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"logs/2024/01/b.jsonl.zst",
+		"logs/2024/01/a.jsonl.zst",
+		"logs/2024/02/c.jsonl.zst",
+	}, rel(res))
+
+	res, err = e.expand(filepath.Join(dir, "logs/missing.jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "logs/missing.jsonl")}, res)
+
+	_, err = newInputExpander("", "", "size")
+	require.Error(t, err)
+}
+
+func TestFlags_Inputs(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "a.jsonl")
+	require.NoError(t, os.WriteFile(fn, []byte("{}\n"), 0o600))
+
+	f := Flags{Input: filepath.Join(dir, "*.jsonl")}
+
+	inputs, err := f.ExpandInputs()
+	require.NoError(t, err)
+	assert.Equal(t, []Input{{FileName: fn}}, inputs)
+	assert.Equal(t, []Input{{FileName: fn}}, f.Inputs())
+
+	// Unmatched glob is not replaced with STDIN.
+	f.Input = filepath.Join(dir, "nomatch*.jsonl")
+
+	_, err = f.ExpandInputs()
+	require.EqualError(t, err, "no files matched input "+f.Input)
+
+	// Every unmatched pattern is reported even if others match.
+	f.Input = fn + "," + filepath.Join(dir, "nomatch*.jsonl") + "," + filepath.Join(dir, "missing*.jsonl")
+
+	_, err = f.ExpandInputs()
+	require.EqualError(t, err, "no files matched input "+
+		filepath.Join(dir, "nomatch*.jsonl")+", "+filepath.Join(dir, "missing*.jsonl"))
+
+	f.Input = fn
+	f.InputSort = "bogus"
+
+	_, err = f.ExpandInputs()
+	require.EqualError(t, err, `unexpected input sort order "bogus", name or mtime expected`)
+}
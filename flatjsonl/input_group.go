@@ -0,0 +1,283 @@
+package flatjsonl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bool64/progress"
+)
+
+// inputGroup aggregates progress of inputs that are read concurrently.
+type inputGroup struct {
+	mu      sync.Mutex
+	bytes   []func() int64
+	lines   []func() int64
+	aborted int64
+	onFail  []func()
+
+	// ordered inputs are decoded to spools, because lines of an input are only processed
+	// after preceding inputs are written.
+	ordered bool
+}
+
+func (g *inputGroup) add(bytes, lines func() int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.bytes = append(g.bytes, bytes)
+	g.lines = append(g.lines, lines)
+}
+
+func (g *inputGroup) sum(counters *[]func() int64) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var s int64
+
+	for _, c := range *counters {
+		s += c()
+	}
+
+	return s
+}
+
+// Bytes returns number of bytes read from all inputs.
+func (g *inputGroup) Bytes() int64 {
+	return g.sum(&g.bytes)
+}
+
+// Lines returns number of lines read from all inputs.
+func (g *inputGroup) Lines() int64 {
+	return g.sum(&g.lines)
+}
+
+// fail aborts reading of inputs in group.
+func (g *inputGroup) fail() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	atomic.StoreInt64(&g.aborted, 1)
+
+	for _, f := range g.onFail {
+		f()
+	}
+
+	g.onFail = nil
+}
+
+// whenFailed calls f once reading of any input has failed, it has no effect for nil group.
+func (g *inputGroup) whenFailed(f func()) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if atomic.LoadInt64(&g.aborted) != 0 {
+		f()
+
+		return
+	}
+
+	g.onFail = append(g.onFail, f)
+}
+
+// spooled is true if compressed inputs of group are decoded in background.
+func (g *inputGroup) spooled() bool {
+	return g != nil && g.ordered
+}
+
+// failed checks if reading of any input has failed, it is false for nil group.
+func (g *inputGroup) failed() bool {
+	return g != nil && atomic.LoadInt64(&g.aborted) != 0
+}
+
+// seqRange is a range of sequence numbers reserved for lines of an input.
+type seqRange struct {
+	base  int64
+	lines int64
+}
+
+// readInputs reads all inputs with sessions set up by prepare, it returns the number of lines read from each input.
+//
+// With -input-concurrency or -split-ranges several inputs are decoded at the same time.
+// If ordered is true, every input gets its own range of sequence numbers built from line counts of keys scanning,
+// so that rows are written in the same order as with sequential reading. Without known line counts
+// ordered inputs are read sequentially. Inputs that are not ordered share sequence numbers.
+func (p *Processor) readInputs(task string, ordered bool, prepare func(sess *readSession)) ([]int64, error) {
+	counts := make([]int64, len(p.inputs))
+
+	concurrency := p.f.InputConcurrency
+	if p.f.SplitRanges > concurrency {
+		concurrency = p.f.SplitRanges
+	}
+
+	if ordered && len(p.inputLines) != len(p.inputs) {
+		concurrency = 1
+	}
+
+	if concurrency <= 1 || len(p.inputs) < 2 {
+		for i := range p.inputs {
+			n, err := p.readInput(i, task, nil, nil, prepare)
+			if err != nil {
+				return nil, err
+			}
+
+			counts[i] = n
+		}
+
+		return counts, nil
+	}
+
+	g := &inputGroup{ordered: ordered}
+
+	p.pr.Start(func(t *progress.Task) {
+		t.Task = task + " (" + strconv.Itoa(len(p.inputs)) + " inputs)"
+		t.TotalBytes = func() int64 {
+			// Size of streamed input may be unknown.
+			if c := g.Bytes(); c > p.rd.totalBytes {
+				return c
+			}
+
+			return p.rd.totalBytes
+		}
+		t.CurrentBytes = g.Bytes
+		t.CurrentLines = g.Lines
+		t.Continue = true
+	})
+	defer p.pr.Stop()
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, concurrency)
+		errs = make([]error, len(p.inputs))
+		base = atomic.LoadInt64(&p.rd.Sequence)
+	)
+
+	for i := range p.inputs {
+		sem <- struct{}{}
+
+		if g.failed() {
+			break
+		}
+
+		var sr *seqRange
+
+		if ordered {
+			sr = &seqRange{base: base, lines: p.inputLines[i]}
+			base += sr.lines
+		}
+
+		wg.Add(1)
+
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			n, err := p.readInput(i, task, sr, g, prepare)
+			if err != nil {
+				errs[i] = err
+
+				g.fail()
+			}
+
+			counts[i] = n
+		}(i)
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if ordered {
+		atomic.StoreInt64(&p.rd.Sequence, base)
+	}
+
+	return counts, nil
+}
+
+// readInput reads lines of input with sequence numbers from reserved range or shared sequence if range is nil,
+// it returns the number of lines read.
+func (p *Processor) readInput(i int, task string, sr *seqRange, g *inputGroup, prepare func(sess *readSession)) (n int64, err error) {
+	input := p.inputs[i]
+
+	if len(p.inputs) > 1 && input.FileName != "" {
+		if g == nil {
+			task += " (" + input.FileName + ")"
+		}
+
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("%s: %w", input.FileName, err)
+			}
+		}()
+	}
+
+	base := atomic.LoadInt64(&p.rd.Sequence)
+	if sr != nil {
+		base = sr.base
+	}
+
+	if lines, ok := p.cp.committedInput(i); ok {
+		// Input was committed to output before interruption.
+		if sr == nil {
+			atomic.AddInt64(&p.rd.Sequence, lines)
+		}
+
+		p.cp.inputStarted(i, base)
+		p.cp.inputFinished(i, lines)
+
+		return lines, nil
+	}
+
+	sess, err := p.rd.session(input, task, g)
+	if err != nil {
+		if errors.Is(err, errEmptyFile) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	defer sess.Close()
+
+	sess.lineBase = p.lineBase(i)
+	sess.skipLines = input.skipLines
+
+	if sr != nil {
+		*sess.sequence = sr.base
+		sess.seqLimit = sr.base + sr.lines
+	} else {
+		sess.sequence = &p.rd.Sequence
+	}
+
+	prepare(sess)
+
+	if sess.window != nil {
+		// Reading of following inputs waits for rows of this input.
+		g.whenFailed(sess.window.abort)
+	}
+
+	p.cp.inputStarted(i, base)
+
+	if err := p.rd.Read(sess); err != nil {
+		return 0, err
+	}
+
+	if sr != nil && sess.lines != sr.lines {
+		return sess.lines, fmt.Errorf("input has %d lines, %d lines were read during keys scan", sess.lines, sr.lines)
+	}
+
+	p.cp.inputFinished(i, sess.lines)
+	p.st.inputRead(input, sess.scanned)
+
+	return sess.lines, nil
+}
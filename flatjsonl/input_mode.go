@@ -0,0 +1,433 @@
+package flatjsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bool64/progress"
+)
+
+// Input modes define how records are delimited in input.
+const (
+	// InputModeLines expects one JSON value per line.
+	InputModeLines = "lines"
+	// InputModeStream decodes concatenated JSON values regardless of newlines (e.g. pretty-printed records).
+	InputModeStream = "stream"
+	// InputModeArray unwraps top-level array (or array at configured path) into individual records.
+	InputModeArray = "array"
+)
+
+// lineScanner iterates input records.
+type lineScanner interface {
+	Scan() bool
+	Bytes() []byte
+	Err() error
+}
+
+// offsetter is implemented by scanners that know byte offset of the last record in uncompressed input.
+type offsetter interface {
+	lineOffset() int64
+}
+
+func checkInputMode(mode string) error {
+	switch mode {
+	case "", InputModeLines, InputModeStream, InputModeArray:
+		return nil
+	default:
+		return fmt.Errorf("unexpected input mode %q, %s, %s or %s expected",
+			mode, InputModeLines, InputModeStream, InputModeArray)
+	}
+}
+
+// parseArrayPath splits path like .Records or .data.items into object keys.
+func parseArrayPath(p string) []string {
+	p = strings.Trim(p, ".")
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, ".")
+}
+
+// jsonScanner reads complete JSON values from stream.
+//
+// In array mode, elements of arrays at path are returned as separate values,
+// enclosing objects are skipped. Values that do not contain the array are returned as is.
+type jsonScanner struct {
+	br      *bufio.Reader
+	cr      *progress.CountingReader
+	unwrap  bool
+	path    []string
+	maxSize int
+
+	// offset is a position of the last value.
+	offset int64
+
+	buf []byte
+	err error
+
+	inArray bool
+	// open is a number of enclosing objects to skip when array is finished.
+	open int
+}
+
+func newJSONScanner(r io.Reader, mode string, path []string, maxSize int) *jsonScanner {
+	cr := progress.NewCountingReader(r)
+	cr.SetLines(nil)
+
+	return &jsonScanner{
+		br:      bufio.NewReaderSize(cr, 64*1024),
+		cr:      cr,
+		unwrap:  mode == InputModeArray,
+		path:    path,
+		maxSize: maxSize,
+	}
+}
+
+// Bytes returns last scanned value.
+func (s *jsonScanner) Bytes() []byte {
+	return s.buf
+}
+
+func (s *jsonScanner) lineOffset() int64 {
+	return s.offset
+}
+
+// Err returns scan error.
+func (s *jsonScanner) Err() error {
+	if errors.Is(s.err, io.EOF) {
+		return nil
+	}
+
+	return s.err
+}
+
+// Scan reads next value, it returns false when input is finished or failed.
+func (s *jsonScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+
+	if err := s.scan(); err != nil {
+		s.err = err
+
+		return false
+	}
+
+	return true
+}
+
+func (s *jsonScanner) scan() error {
+	s.buf = s.buf[:0]
+
+	for {
+		if s.inArray {
+			c, err := s.skipSpace()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+
+			if c == ',' {
+				continue
+			}
+
+			if c == ']' {
+				s.inArray = false
+
+				for ; s.open > 0; s.open-- {
+					if err := s.skipMembers(); err != nil {
+						return err
+					}
+				}
+
+				continue
+			}
+
+			if err := s.br.UnreadByte(); err != nil {
+				return err
+			}
+
+			return s.readValue()
+		}
+
+		c, err := s.skipSpace()
+		if err != nil {
+			return err
+		}
+
+		if err := s.br.UnreadByte(); err != nil {
+			return err
+		}
+
+		if !s.unwrap {
+			return s.readValue()
+		}
+
+		if len(s.path) == 0 && c == '[' {
+			_, _ = s.br.ReadByte() //nolint:errcheck // Byte was peeked.
+			s.inArray = true
+
+			continue
+		}
+
+		if len(s.path) > 0 && c == '{' {
+			if err := s.findArray(); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		// Value without array, e.g. a single record.
+		return s.readValue()
+	}
+}
+
+// findArray descends into object along the path until array is found.
+func (s *jsonScanner) findArray() error {
+	for level := 0; level < len(s.path); level++ {
+		if _, err := s.br.ReadByte(); err != nil { // Opening brace.
+			return err
+		}
+
+		s.open++
+
+		found, err := s.seekKey(&s.path[level])
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			// Object was closed without the key, skip the rest of enclosing objects.
+			s.open--
+
+			for ; s.open > 0; s.open-- {
+				if err := s.skipMembers(); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		c, err := s.skipSpace()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		last := level == len(s.path)-1
+
+		switch {
+		case last && c == '[':
+			s.inArray = true
+
+			return nil
+		case !last && c == '{':
+			if err := s.br.UnreadByte(); err != nil {
+				return err
+			}
+		default:
+			if err := s.br.UnreadByte(); err != nil {
+				return err
+			}
+
+			if err := s.skipValue(); err != nil {
+				return err
+			}
+
+			for ; s.open > 0; s.open-- {
+				if err := s.skipMembers(); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// seekKey reads object members until key is found, value of the key is not consumed.
+// It returns false if object is closed without the key, nil key skips all members.
+func (s *jsonScanner) seekKey(key *string) (bool, error) {
+	for {
+		c, err := s.skipSpace()
+		if err != nil {
+			return false, unexpectedEOF(err)
+		}
+
+		switch c {
+		case '}':
+			return false, nil
+		case ',':
+			continue
+		case '"':
+		default:
+			return false, fmt.Errorf("unexpected character %q in object", c)
+		}
+
+		if err := s.br.UnreadByte(); err != nil {
+			return false, err
+		}
+
+		k, err := s.readKey()
+		if err != nil {
+			return false, err
+		}
+
+		if key != nil && k == *key {
+			return true, nil
+		}
+
+		if err := s.skipValue(); err != nil {
+			return false, err
+		}
+	}
+}
+
+// skipMembers reads the rest of object up to and including closing brace.
+func (s *jsonScanner) skipMembers() error {
+	_, err := s.seekKey(nil)
+
+	return err
+}
+
+func (s *jsonScanner) readKey() (string, error) {
+	start := len(s.buf)
+
+	if err := s.readValue(); err != nil {
+		return "", err
+	}
+
+	raw := s.buf[start:]
+	s.buf = s.buf[:start]
+
+	var k string
+
+	if err := json.Unmarshal(raw, &k); err != nil {
+		return "", fmt.Errorf("failed to decode object key %s: %w", string(raw), err)
+	}
+
+	c, err := s.skipSpace()
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+
+	if c != ':' {
+		return "", fmt.Errorf("unexpected character %q after object key", c)
+	}
+
+	return k, nil
+}
+
+func (s *jsonScanner) skipValue() error {
+	start := len(s.buf)
+	err := s.readValue()
+	s.buf = s.buf[:start]
+
+	return err
+}
+
+func (s *jsonScanner) skipSpace() (byte, error) {
+	for {
+		c, err := s.br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+
+		return c, nil
+	}
+}
+
+// readValue appends a complete JSON value to buffer.
+func (s *jsonScanner) readValue() error {
+	if _, err := s.skipSpace(); err != nil {
+		return err
+	}
+
+	if err := s.br.UnreadByte(); err != nil {
+		return err
+	}
+
+	// The last value that is read by scan is a record.
+	s.offset = s.cr.Bytes() - int64(s.br.Buffered())
+
+	start := len(s.buf)
+	depth := 0
+	inString := false
+	escaped := false
+
+	for {
+		c, err := s.br.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) && depth == 0 && !inString && len(s.buf) > start {
+				// Scalar value at the end of stream.
+				return nil
+			}
+
+			return unexpectedEOF(err)
+		}
+
+		if !inString && depth == 0 && len(s.buf) > start {
+			switch c {
+			case ' ', '\t', '\r', '\n', ',', ']', '}', '[', '{', '"':
+				// End of scalar value.
+				return s.br.UnreadByte()
+			}
+		}
+
+		s.buf = append(s.buf, c)
+
+		if s.maxSize > 0 && len(s.buf)-start > s.maxSize {
+			return bufio.ErrTooLong
+		}
+
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+
+				if depth == 0 {
+					return nil
+				}
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+
+			if depth == 0 {
+				return nil
+			}
+
+			if depth < 0 {
+				return fmt.Errorf("unexpected character %q", c)
+			}
+		}
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,91 @@
+package flatjsonl
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONScanner(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		mode     string
+		path     string
+		input    string
+		maxSize  int
+		expected []string
+		err      error
+	}{
+		{
+			name: "stream",
+			mode: InputModeStream,
+			input: `{
+  "a": 1,
+  "b": {"c": "}{\"]"}
+}{"a":2}
+  [1, 2]
+"str" 123 true`,
+			expected: []string{`{
+  "a": 1,
+  "b": {"c": "}{\"]"}
+}`, `{"a":2}`, `[1, 2]`, `"str"`, `123`, `true`},
+		},
+		{
+			name:     "top-level array",
+			mode:     InputModeArray,
+			input:    "[\n  {\"a\": 1},\n  {\"a\": [2, 3]}\n]\n[{\"a\":4}]",
+			expected: []string{`{"a": 1}`, `{"a": [2, 3]}`, `{"a":4}`},
+		},
+		{
+			name:     "records path",
+			mode:     InputModeArray,
+			path:     ".Records",
+			input:    `{"Foo":{"Records":[1]},"Records":[{"a":1},{"a":2}],"Bar":"}"} {"Records":[]} {"Records":[{"a":3}]}`,
+			expected: []string{`{"a":1}`, `{"a":2}`, `{"a":3}`},
+		},
+		{
+			name:     "nested path",
+			mode:     InputModeArray,
+			path:     ".data.items",
+			input:    `{"data":{"total":2,"items":[{"a":1},{"a":2}],"next":null},"meta":{}}{"data":{"items":null}}{"other":1}`,
+			expected: []string{`{"a":1}`, `{"a":2}`},
+		},
+		{
+			name:     "unexpected end",
+			mode:     InputModeArray,
+			input:    `[{"a":1},{"a":`,
+			expected: []string{`{"a":1}`},
+			err:      io.ErrUnexpectedEOF,
+		},
+		{
+			name:     "too long",
+			mode:     InputModeStream,
+			input:    `{"a":1} {"a":"1234567890"}`,
+			maxSize:  10,
+			expected: []string{`{"a":1}`},
+			err:      bufio.ErrTooLong,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newJSONScanner(strings.NewReader(tc.input), tc.mode, parseArrayPath(tc.path), tc.maxSize)
+
+			var values []string
+
+			for s.Scan() {
+				values = append(values, string(s.Bytes()))
+			}
+
+			assert.Equal(t, tc.expected, values)
+
+			if tc.err != nil {
+				require.ErrorIs(t, s.Err(), tc.err)
+			} else {
+				require.NoError(t, s.Err())
+			}
+		})
+	}
+}
@@ -1,9 +1,10 @@
 package flatjsonl
 
 import (
-	"errors"
 	"fmt"
+	"math"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,12 +19,24 @@ type flKey struct {
 	isZero           bool
 	t                Type
 	tt               []Type
+	elemType         Type // Type of elements for TypeList.
 	original         string
 	canonical        string
 	replaced         string
 	transposeDst     string
 	transposeKey     intOrString
 	transposeTrimmed string
+	explodeIdx       int
+	explodeTrimmed   string
+	computed         *computedColumn
+	typed            bool // Type is set by columnTypes config.
+	inferred         bool // Type is inferred from kinds of string values with -infer-types.
+	kinds            valueKinds
+	epochMillis      bool // Inferred timestamp is in milliseconds since epoch.
+	precision        int  // Precision and scale of TypeDecimal.
+	scale            int
+	digits           int // Max digits of integer and fraction parts of decimal and fractional numbers.
+	fraction         int
 	extractors       []extractor
 	parent           uint64
 }
@@ -98,6 +111,8 @@ func (p *Processor) initKey(pk, parent uint64, path []string, t Type, isZero boo
 		}
 	}
 
+	p.explodeKey(&k)
+
 	for r, x := range p.extractRegex {
 		if r.MatchString(key) {
 			k.extractors = append(k.extractors, x...)
@@ -163,6 +178,65 @@ func (p *Processor) scanKey(pk, parent uint64, path []string, t Type, isZero boo
 	return k.extractors, false
 }
 
+// scanDigits records digits of decimal or fractional number in a key to fit decimal column.
+func (p *Processor) scanDigits(pk uint64, raw []byte) {
+	digits, fraction := numberDigits(raw)
+
+	if k, ok := p.flKeys.Load(pk); !ok || (k.digits >= digits && k.fraction >= fraction) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k, _ := p.flKeys.Load(pk)
+	k.digits = max(k.digits, digits)
+	k.fraction = max(k.fraction, fraction)
+
+	p.flKeys.Store(pk, k)
+}
+
+// fitDecimals sets scale of decimal columns from fraction digits of their values,
+// columns with values that do not fit decimal precision become strings.
+func (p *Processor) fitDecimals() {
+	for i, k := range p.keys {
+		if k.t != TypeDecimal || k.computed != nil {
+			continue
+		}
+
+		digits := k.digits
+
+		// Digits of int64 values are not scanned.
+		if slices.Contains(k.tt, TypeInt) || k.kinds&(kindInt|kindEpoch|kindEpochMillis) != 0 {
+			digits = max(digits, len(strconv.Itoa(math.MaxInt64)))
+		}
+
+		if digits+k.fraction > defaultDecimalPrecision {
+			k.t = TypeString
+			k.inferred = false
+		} else {
+			k.scale = k.fraction
+		}
+
+		p.keys[i] = k
+	}
+}
+
+// scanElemType updates type of list elements.
+func (p *Processor) scanElemType(pk uint64, t Type) {
+	if k, ok := p.flKeys.Load(pk); !ok || k.elemType == k.elemType.Update(t) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k, _ := p.flKeys.Load(pk)
+	k.elemType = k.elemType.Update(t)
+
+	p.flKeys.Store(pk, k)
+}
+
 func (p *Processor) collectKeyCardinality(k flKey) {
 	parentCardinality := p.parentCardinality[k.parent]
 	parentCardinality++
@@ -192,7 +266,7 @@ func (p *Processor) collectKeyCardinality(k flKey) {
 	if parentCardinality > limit {
 		pp := k.path[0 : len(k.path)-1]
 		parentKey := KeyFromPath(pp)
-		allowCardinality := false
+		allowCardinality := parentKey == p.cfg.Explode
 
 		for _, ac := range p.cfg.AllowCardinality {
 			if parentKey == ac {
@@ -262,6 +336,40 @@ func scanTransposedKey(dst string, tk string, k *flKey) {
 	k.transposeTrimmed = trimmed
 }
 
+// explodeKey marks key of exploded array element with element index and key without index, e.g. .items.[3].name
+// becomes .items.name.
+func (p *Processor) explodeKey(k *flKey) {
+	prefix := p.cfg.Explode
+	if prefix == "" || k.transposeDst != "" || !strings.HasPrefix(k.original, prefix+".[") {
+		return
+	}
+
+	rest := k.original[len(prefix)+2:]
+
+	pos := strings.Index(rest, "]")
+	if pos <= 0 {
+		return
+	}
+
+	i, err := strconv.Atoi(rest[:pos])
+	if err != nil {
+		return
+	}
+
+	k.explodeIdx = i
+	k.explodeTrimmed = prefix + rest[pos+1:]
+}
+
+// explodeIndexKey returns key of element index column of exploded array, or empty string if explode is not
+// configured.
+func (p *Processor) explodeIndexKey() string {
+	if p.cfg.Explode == "" {
+		return ""
+	}
+
+	return p.cfg.Explode + "._index"
+}
+
 type hasher struct {
 	digest *xxhash.Digest
 }
@@ -331,91 +439,104 @@ func (p *Processor) scanAvailableKeys() error {
 	}
 
 	p.rd.OffsetLines = int64(p.f.OffsetLines)
+	p.rd.sample = newSampler(p.f.SampleRateKeys, p.sampleSeed)
 
 	for _, input := range p.inputs {
-		err := func() error {
-			task := "scanning keys"
-			if len(p.inputs) > 1 && input.FileName != "" {
-				task = "scanning keys (" + input.FileName + ")"
-			}
-
-			sess, err := p.rd.session(input, task)
-			if err != nil {
-				if errors.Is(err, errEmptyFile) {
-					return nil
-				}
-
-				return err
-			}
+		// Streamed input is copied to a temporary file to be available for the second pass.
+		if sr, ok := input.Reader.(*StdinReader); ok {
+			sr.EnableSpill(p.f.SpillDir, p.f.SpillZstd)
+		}
+	}
 
-			defer sess.Close()
+	counts, err := p.readInputs("scanning keys", false, func(sess *readSession) {
+		sess.setupWalker = func(w *FastWalker) {
+			h := newHasher()
 
-			sess.setupWalker = func(w *FastWalker) {
-				h := newHasher()
+			w.WantPath = true
 
-				w.WantPath = true
+			w.FnObjectStop = func(_ int64, flatPath []byte, pl int, path []string) (stop bool) {
+				// Nothing to do with empty path.
+				if len(flatPath) == 0 {
+					return false
+				}
 
-				w.FnObjectStop = func(_ int64, flatPath []byte, pl int, path []string) (stop bool) {
-					// Nothing to do with empty path.
-					if len(flatPath) == 0 {
-						return false
-					}
+				pk, parent := h.hashParentBytes(flatPath, pl)
 
-					pk, parent := h.hashParentBytes(flatPath, pl)
+				_, stop = p.scanKey(pk, parent, path, TypeObject, false)
 
-					_, stop = p.scanKey(pk, parent, path, TypeObject, false)
+				return stop
+			}
 
-					return stop
+			w.FnArrayStop = func(_ int64, flatPath []byte, pl int, path []string) (stop bool) {
+				if len(flatPath) == 0 {
+					return
 				}
 
-				w.FnArrayStop = func(_ int64, flatPath []byte, pl int, path []string) (stop bool) {
-					if len(flatPath) == 0 {
-						return
-					}
+				pk, parent := h.hashParentBytes(flatPath, pl)
 
-					pk, parent := h.hashParentBytes(flatPath, pl)
+				_, stop = p.scanKey(pk, parent, path, TypeArray, false)
 
-					_, stop = p.scanKey(pk, parent, path, TypeArray, false)
+				return stop
+			}
+			w.FnString = func(_ int64, flatPath []byte, pl int, path []string, value []byte) []extractor {
+				pk, parent := h.hashParentBytes(flatPath, pl)
 
-					return stop
-				}
-				w.FnString = func(_ int64, flatPath []byte, pl int, path []string, value []byte) []extractor {
-					pk, parent := h.hashParentBytes(flatPath, pl)
+				x, _ := p.scanKey(pk, parent, path, TypeString, len(value) == 0)
 
-					x, _ := p.scanKey(pk, parent, path, TypeString, len(value) == 0)
+				if p.f.InferTypes {
+					kind := stringKind(value)
+					p.scanKind(pk, kind)
 
-					return x
-				}
-				w.FnNumber = func(_ int64, flatPath []byte, pl int, path []string, value float64, _ []byte) {
-					pk, parent := h.hashParentBytes(flatPath, pl)
-					isInt := float64(int(value)) == value
-
-					if isInt {
-						p.scanKey(pk, parent, path, TypeInt, value == 0)
-					} else {
-						p.scanKey(pk, parent, path, TypeFloat, value == 0)
+					if kind == kindDecimal || kind == kindFloat {
+						p.scanDigits(pk, value)
 					}
 				}
-				w.FnBool = func(_ int64, flatPath []byte, pl int, path []string, value bool) {
-					pk, parent := h.hashParentBytes(flatPath, pl)
-					p.scanKey(pk, parent, path, TypeBool, !value)
+
+				return x
+			}
+			w.FnNumber = func(_ int64, flatPath []byte, pl int, path []string, value float64, raw []byte) {
+				pk, parent := h.hashParentBytes(flatPath, pl)
+				t := numberType(value, raw)
+				p.scanKey(pk, parent, path, t, value == 0)
+
+				if t == TypeDecimal || t == TypeFloat {
+					p.scanDigits(pk, raw)
 				}
-				w.FnNull = func(_ int64, flatPath []byte, pl int, path []string) {
-					pk, parent := h.hashParentBytes(flatPath, pl)
-					p.scanKey(pk, parent, path, TypeNull, true)
+
+				if p.f.InferTypes {
+					p.scanKind(pk, numberKind(t))
 				}
 			}
+			w.FnBool = func(_ int64, flatPath []byte, pl int, path []string, value bool) {
+				pk, parent := h.hashParentBytes(flatPath, pl)
+				p.scanKey(pk, parent, path, TypeBool, !value)
 
-			err = p.rd.Read(sess)
-			if err != nil {
-				return fmt.Errorf("failed to read: %w", err)
+				if p.f.InferTypes {
+					p.scanKind(pk, kindBool)
+				}
+			}
+			w.FnNull = func(_ int64, flatPath []byte, pl int, path []string) {
+				pk, parent := h.hashParentBytes(flatPath, pl)
+				p.scanKey(pk, parent, path, TypeNull, true)
+			}
+			w.FnList = func(_ int64, flatPath []byte, pl int, path []string, values []Value) {
+				pk, parent := h.hashParentBytes(flatPath, pl)
+				p.scanKey(pk, parent, path, TypeList, len(values) == 0)
+				p.scanElemType(pk, elemType(values))
 			}
-
-			return nil
-		}()
-		if err != nil {
-			return err
 		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read: %w", err)
+	}
+
+	if err := p.checkErrorRate(true); err != nil {
+		return err
+	}
+
+	// Line counts allow concurrent reading of inputs for output, unless keys were scanned from a head of inputs.
+	if p.f.MaxLinesKeys == 0 || (p.f.MaxLines > 0 && p.f.MaxLines <= p.f.MaxLinesKeys) {
+		p.inputLines = counts
 	}
 
 	p.prepareScannedKeys()
@@ -520,6 +641,8 @@ func (p *Processor) flKeysInit() {
 				canonical: p.ck(key),
 			}
 
+			p.explodeKey(&k)
+
 			for r, x := range p.extractRegex {
 				if r.MatchString(key) {
 					k.extractors = append(k.extractors, x...)
@@ -541,12 +664,30 @@ func (p *Processor) flKeysInit() {
 		v := p.canonicalKeys[value.canonical]
 		value.isZero = value.isZero && v.isZero
 		value.t = v.t.Update(value.t)
+		value.elemType = v.elemType.Update(value.elemType)
+		value.kinds |= v.kinds
+		value.digits = max(value.digits, v.digits)
+		value.fraction = max(value.fraction, v.fraction)
 
 		p.canonicalKeys[value.canonical] = value
 
 		return true
 	})
 
+	if ek := p.explodeIndexKey(); ek != "" {
+		// Element index is not a key of input, its values are added to exploded rows.
+		p.canonicalKeys[p.ck(ek)] = flKey{
+			path:      strings.Split(strings.TrimPrefix(ek, "."), "."),
+			t:         TypeInt,
+			original:  ek,
+			canonical: p.ck(ek),
+		}
+	}
+
+	for _, c := range p.computed {
+		p.canonicalKeys[p.ck(c.name)] = p.computedKey(c)
+	}
+
 	for _, k := range p.flKeysList {
 		if len(p.cfg.Transpose) > 0 {
 			for tk := range p.cfg.Transpose {
@@ -643,6 +784,14 @@ func (p *Processor) iterateIncludeKeys() {
 			}
 		}
 	}
+
+	if ek := p.explodeIndexKey(); ek != "" {
+		p.addIncludeKey(ek, &i)
+	}
+
+	for _, c := range p.computed {
+		p.addIncludeKey(c.name, &i)
+	}
 }
 
 func (p *Processor) addIncludeKey(k string, i *int) {
@@ -650,6 +799,11 @@ func (p *Processor) addIncludeKey(k string, i *int) {
 		return
 	}
 
+	if ek := p.explodeIndexKey(); ek != "" && p.canonicalKeys[p.ck(k)].explodeTrimmed != "" {
+		// Element index goes before the first column of exploded array.
+		p.addIncludeKey(ek, i)
+	}
+
 	p.includeKeys[k] = *i
 	*i++
 }
@@ -694,6 +848,10 @@ func (p *Processor) prepareKeys() {
 		switch {
 		case !ok: // Can happen for meta keys like `const:X`.
 			ck.replaced = p.prepareKey(origKey)
+		case ck.computed != nil:
+			ck.replaced = ck.original
+		case ck.explodeTrimmed != "":
+			ck.replaced = p.prepareKey(ck.explodeTrimmed)
 		case ck.transposeDst == "":
 			ck.replaced = p.prepareKey(ck.original)
 		default:
@@ -709,9 +867,13 @@ func (p *Processor) prepareKeys() {
 
 	for i, pk := range p.keys {
 		if pk.transposeDst == "" {
-			if j, ok := keyExists[pk.replaced]; ok {
-				pk.UpdateType(p.keys[i].t)
-				p.keys[i] = pk
+			// Computed columns are not merged, so that conflicting names fail on binding.
+			if j, ok := keyExists[pk.replaced]; ok && pk.computed == nil && keys[j].computed == nil {
+				// Keys share a column, so it gets a type that fits values of all keys.
+				keys[j].UpdateType(pk.t)
+				keys[j].kinds |= pk.kinds
+				keys[j].digits = max(keys[j].digits, pk.digits)
+				keys[j].fraction = max(keys[j].fraction, pk.fraction)
 				keyMap[i] = j
 
 				continue
@@ -737,6 +899,17 @@ func (p *Processor) prepareKeys() {
 	}
 
 	p.keys = keys
+
+	p.inferTypes()
+	p.fitDecimals()
+	p.applyColumnTypes()
+
+	// Types of computed columns are inferred from types of keys they refer to.
+	for i, k := range p.keys {
+		if k.computed != nil && !k.typed {
+			p.keys[i].t = k.computed.expr.typ(p)
+		}
+	}
 }
 
 func (p *Processor) prepareKey(origKey string) (kk string) {
@@ -0,0 +1,172 @@
+package flatjsonl
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// walkLogfmt decodes logfmt pairs of a line into walker callbacks.
+//
+// If prefix matching is enabled, logfmt pairs are expected after the end of the last match.
+func (rd *Reader) walkLogfmt(w *syncWorker, seq int64, line []byte) {
+	if rd.MatchPrefix != nil {
+		line = line[rd.walkPrefix(seq, line, w.walker.FnString):]
+	}
+
+	walker := w.walker
+
+	parseLogfmt(line, func(key, value []byte, quoted bool) {
+		flatPath := append(w.flatPath[:0], '.')
+		flatPath = append(flatPath, key...)
+
+		var path []string
+
+		if walker.WantPath {
+			path = append(w.path[:0], string(key))
+		}
+
+		if rd.LogfmtDetectTypes && !quoted {
+			switch {
+			case value == nil:
+				walker.FnBool(seq, flatPath, 0, path, true)
+
+				return
+			case string(value) == "true" || string(value) == "false":
+				walker.FnBool(seq, flatPath, 0, path, value[0] == 't')
+
+				return
+			case looksNumeric(value):
+				if f, err := strconv.ParseFloat(string(value), 64); err == nil {
+					walker.FnNumber(seq, flatPath, 0, path, f, value)
+
+					return
+				}
+			}
+		}
+
+		walker.WalkString(seq, flatPath, 0, path, value)
+	})
+}
+
+// looksNumeric checks if value starts like a decimal number, so that special values like NaN or Inf are kept as strings.
+func looksNumeric(v []byte) bool {
+	if len(v) == 0 {
+		return false
+	}
+
+	c := v[0]
+	if (c == '-' || c == '+') && len(v) > 1 {
+		c = v[1]
+	}
+
+	return (c >= '0' && c <= '9') || c == '.'
+}
+
+// parseLogfmt iterates key=value pairs, value is nil for a bare key.
+// Quoted values are unescaped.
+func parseLogfmt(line []byte, fn func(key, value []byte, quoted bool)) {
+	i := 0
+
+	for i < len(line) {
+		// Skip spaces.
+		for i < len(line) && line[i] <= ' ' {
+			i++
+		}
+
+		start := i
+
+		for i < len(line) && line[i] > ' ' && line[i] != '=' && line[i] != '"' {
+			i++
+		}
+
+		key := line[start:i]
+
+		if len(key) == 0 {
+			// Garbage, e.g. value without key.
+			if i < len(line) && line[i] == '=' {
+				i++
+			}
+
+			if i < len(line) && line[i] == '"' {
+				_, i = readLogfmtQuoted(line, i)
+			}
+
+			for i < len(line) && line[i] > ' ' {
+				i++
+			}
+
+			continue
+		}
+
+		if i >= len(line) || line[i] != '=' {
+			fn(key, nil, false)
+
+			continue
+		}
+
+		i++ // Skip '='.
+
+		if i < len(line) && line[i] == '"' {
+			var value []byte
+
+			value, i = readLogfmtQuoted(line, i)
+
+			fn(key, value, true)
+
+			continue
+		}
+
+		start = i
+
+		for i < len(line) && line[i] > ' ' {
+			i++
+		}
+
+		fn(key, line[start:i], false)
+	}
+}
+
+// readLogfmtQuoted reads quoted string starting at position i, it returns unescaped value and position after closing quote.
+func readLogfmtQuoted(line []byte, i int) ([]byte, int) {
+	start := i
+	i++ // Skip opening quote.
+
+	escaped := false
+	terminated := false
+
+	for ; i < len(line); i++ {
+		if escaped {
+			escaped = false
+
+			continue
+		}
+
+		if line[i] == '\\' {
+			escaped = true
+
+			continue
+		}
+
+		if line[i] == '"' {
+			i++
+			terminated = true
+
+			break
+		}
+	}
+
+	quoted := line[start:i]
+
+	if terminated {
+		if bytes.IndexByte(quoted, '\\') == -1 {
+			return quoted[1 : len(quoted)-1], i
+		}
+
+		if s, err := strconv.Unquote(string(quoted)); err == nil {
+			return []byte(s), i
+		}
+	}
+
+	// Unterminated or invalid quoted string is kept as is.
+	return quoted[1:], i
+}
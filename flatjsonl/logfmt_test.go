@@ -0,0 +1,40 @@
+package flatjsonl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogfmt(t *testing.T) {
+	type pair struct {
+		key    string
+		value  *string
+		quoted bool
+	}
+
+	str := func(s string) *string { return &s }
+
+	var pairs []pair
+
+	parseLogfmt([]byte(`level=info msg="hello \"world\"" dur=12ms  debug empty= q="" =orphan ="junk" "junk" path=/a=b tail="unterminated`),
+		func(key, value []byte, quoted bool) {
+			p := pair{key: string(key), quoted: quoted}
+			if value != nil {
+				p.value = str(string(value))
+			}
+
+			pairs = append(pairs, p)
+		})
+
+	assert.Equal(t, []pair{
+		{key: "level", value: str("info")},
+		{key: "msg", value: str(`hello "world"`), quoted: true},
+		{key: "dur", value: str("12ms")},
+		{key: "debug"},
+		{key: "empty", value: str("")},
+		{key: "q", value: str(""), quoted: true},
+		{key: "path", value: str("/a=b")},
+		{key: "tail", value: str("unterminated"), quoted: true},
+	}, pairs)
+}
@@ -0,0 +1,183 @@
+package flatjsonl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Long lines modes define handling of lines that are longer than buffer size.
+const (
+	// LongLinesFail stops processing with an error.
+	LongLinesFail = "fail"
+	// LongLinesSkip skips and rejects long lines.
+	LongLinesSkip = "skip"
+	// LongLinesTruncate cuts long lines to buffer size.
+	LongLinesTruncate = "truncate"
+	// LongLinesGrow grows buffer up to a hard cap, longer lines are skipped.
+	LongLinesGrow = "grow"
+)
+
+func checkLongLines(mode string) error {
+	switch mode {
+	case "", LongLinesFail, LongLinesSkip, LongLinesTruncate, LongLinesGrow:
+		return nil
+	default:
+		return fmt.Errorf("unexpected long lines mode %q, %s, %s, %s or %s expected",
+			mode, LongLinesFail, LongLinesSkip, LongLinesTruncate, LongLinesGrow)
+	}
+}
+
+// longLiner is implemented by scanners that can pass through lines longer than buffer size.
+type longLiner interface {
+	// longLine returns size of the last line if it did not fit in buffer and whether line should be skipped.
+	longLine() (size int, skip bool)
+}
+
+// longLineScanner scans lines like bufio.Scanner, but handles lines that exceed buffer size according to mode
+// instead of failing.
+type longLineScanner struct {
+	br    *bufio.Reader
+	mode  string
+	limit int
+
+	buf  []byte
+	line []byte
+	size int
+	long bool
+	err  error
+
+	// pos is a number of consumed bytes, offset is a position of the last line.
+	pos    int64
+	offset int64
+}
+
+// newLongLineScanner creates a scanner with buffer, lines can grow up to maxSize in grow mode.
+func newLongLineScanner(r io.Reader, buf []byte, mode string, maxSize int) *longLineScanner {
+	s := &longLineScanner{
+		br:    bufio.NewReaderSize(r, 64*1024),
+		mode:  mode,
+		limit: len(buf),
+		buf:   buf[:0],
+	}
+
+	if s.limit == 0 {
+		s.limit = bufio.MaxScanTokenSize
+	}
+
+	if mode == LongLinesGrow && maxSize > s.limit {
+		s.limit = maxSize
+	}
+
+	return s
+}
+
+// Scan reads next line, it returns false when input is finished or failed.
+func (s *longLineScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+
+	s.buf = s.buf[:0]
+	s.line = nil
+	s.size = 0
+	s.long = false
+	s.offset = s.pos
+
+	for {
+		frag, err := s.br.ReadSlice('\n')
+		s.size += len(frag)
+		s.pos += int64(len(frag))
+
+		switch {
+		case s.long:
+		case len(s.buf)+len(frag) > s.limit:
+			if s.mode == "" || s.mode == LongLinesFail {
+				s.err = fmt.Errorf("%w: line is longer than %d bytes, use -long-lines or larger -buf-size",
+					bufio.ErrTooLong, s.limit)
+
+				return false
+			}
+
+			s.long = true
+			s.buf = append(s.buf, frag[:s.limit-len(s.buf)]...)
+		case err == nil && len(s.buf) == 0:
+			// Complete line is available in reader buffer.
+			s.line = frag
+		default:
+			s.buf = append(s.buf, frag...)
+		}
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			s.err = err
+
+			return false
+		}
+
+		if err != nil && s.size == 0 {
+			s.err = err
+
+			return false
+		}
+
+		if err == nil {
+			// Size does not include new line.
+			s.size--
+		}
+
+		break
+	}
+
+	if s.line == nil {
+		s.line = s.buf
+	}
+
+	if !s.long {
+		s.line = dropCRLF(s.line)
+	}
+
+	return true
+}
+
+func dropCRLF(line []byte) []byte {
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+
+	return line
+}
+
+// Bytes returns the last scanned line, truncated to buffer size if it is too long.
+func (s *longLineScanner) Bytes() []byte {
+	return s.line
+}
+
+// Err returns scan error.
+func (s *longLineScanner) Err() error {
+	if errors.Is(s.err, io.EOF) {
+		return nil
+	}
+
+	return s.err
+}
+
+func (s *longLineScanner) lineOffset() int64 {
+	return s.offset
+}
+
+func (s *longLineScanner) longLine() (int, bool) {
+	if !s.long {
+		return 0, false
+	}
+
+	return s.size, s.mode != LongLinesTruncate
+}
@@ -0,0 +1,72 @@
+package flatjsonl
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_longLineScanner(t *testing.T) {
+	input := "short\r\n\n0123456789abcdef\nlast"
+
+	for _, tc := range []struct {
+		mode     string
+		maxSize  int
+		expected []string
+		long     []int
+		err      error
+	}{
+		{
+			mode:     LongLinesFail,
+			expected: []string{"short", ""},
+			long:     []int{0, 0},
+			err:      bufio.ErrTooLong,
+		},
+		{
+			mode:     LongLinesSkip,
+			expected: []string{"short", "", "0123456789", "last"},
+			long:     []int{0, 0, 16, 0},
+		},
+		{
+			mode:     LongLinesTruncate,
+			expected: []string{"short", "", "0123456789", "last"},
+			long:     []int{0, 0, 16, 0},
+		},
+		{
+			mode:     LongLinesGrow,
+			maxSize:  20,
+			expected: []string{"short", "", "0123456789abcdef", "last"},
+			long:     []int{0, 0, 0, 0},
+		},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			s := newLongLineScanner(strings.NewReader(input), make([]byte, 10), tc.mode, tc.maxSize)
+
+			var (
+				lines []string
+				long  []int
+			)
+
+			for s.Scan() {
+				size, skip := s.longLine()
+
+				lines = append(lines, string(s.Bytes()))
+				long = append(long, size)
+
+				assert.Equal(t, size > 0 && tc.mode != LongLinesTruncate, skip)
+			}
+
+			assert.Equal(t, tc.expected, lines)
+			assert.Equal(t, tc.long, long)
+
+			if tc.err != nil {
+				require.ErrorIs(t, s.Err(), tc.err)
+			} else {
+				require.NoError(t, s.Err())
+			}
+		})
+	}
+}
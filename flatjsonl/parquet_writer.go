@@ -2,7 +2,9 @@ package flatjsonl
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/parquet-go/parquet-go"
 	"github.com/parquet-go/parquet-go/compress"
@@ -37,7 +39,12 @@ type parquetColumn struct {
 	valueIndex  int
 	columnIndex int
 	columnType  Type
+	elemType    Type
 	columnName  string
+
+	// precision and scale of decimal column.
+	precision int
+	scale     int
 }
 
 // NewParquetWriter creates an instance of ParquetWriter.
@@ -55,7 +62,7 @@ func NewParquetWriter(fn string, compression string, p *Processor) (*ParquetWrit
 		return nil, err
 	}
 
-	c.b = &baseWriter{p: p}
+	c.b = &baseWriter{p: p, typedColumns: true}
 
 	return c, nil
 }
@@ -104,6 +111,18 @@ func (c *ParquetWriter) setupParquetWriter() error {
 	}
 
 	for _, k := range c.b.filteredKeys {
+		if k.t == TypeList {
+			group[k.replaced] = parquet.Optional(parquet.List(parquet.Optional(parquetNode(k.elemType))))
+
+			continue
+		}
+
+		if k.t == TypeDecimal {
+			group[k.replaced] = parquet.Optional(parquetDecimalNode(k.precision, k.scale))
+
+			continue
+		}
+
 		group[k.replaced] = parquet.Optional(parquetNode(k.t))
 	}
 
@@ -122,7 +141,8 @@ func (c *ParquetWriter) setupParquetWriter() error {
 			return fmt.Errorf("failed to look up parquet column %v", colPath)
 		}
 
-		name := colPath[len(colPath)-1]
+		// Lists have nested leaf column, so top level name is used.
+		name := colPath[0]
 		found := false
 
 		for i, k := range c.b.filteredKeys {
@@ -131,7 +151,10 @@ func (c *ParquetWriter) setupParquetWriter() error {
 					valueIndex:  i,
 					columnIndex: leaf.ColumnIndex,
 					columnType:  k.t,
+					elemType:    k.elemType,
 					columnName:  k.replaced,
+					precision:   k.precision,
+					scale:       k.scale,
 				})
 				found = true
 
@@ -155,11 +178,32 @@ func parquetNode(t Type) parquet.Node {
 		return parquet.Int(64)
 	case TypeFloat:
 		return parquet.Leaf(parquet.DoubleType)
+	case TypeTimestamp:
+		return parquet.Timestamp(parquet.Microsecond)
+	case TypeUUID:
+		return parquet.UUID()
 	default:
 		return parquet.String()
 	}
 }
 
+// parquetDecimalNode returns decimal node with the smallest physical type for precision,
+// decimal without precision is inferred from values and has the widest precision.
+func parquetDecimalNode(precision, scale int) parquet.Node {
+	if precision == 0 {
+		precision = defaultDecimalPrecision
+	}
+
+	switch {
+	case precision <= 9:
+		return parquet.Decimal(scale, precision, parquet.Int32Type)
+	case precision <= 18:
+		return parquet.Decimal(scale, precision, parquet.Int64Type)
+	default:
+		return parquet.Decimal(scale, precision, parquet.FixedLenByteArrayType(16))
+	}
+}
+
 func parquetFlushSize(numCols int) int {
 	switch {
 	case numCols >= 1500:
@@ -234,7 +278,28 @@ func (c *ParquetWriter) writeRow(values []Value) error {
 	for _, col := range c.orderedColumns {
 		v := values[col.valueIndex]
 
-		pv, err := parquetValue(v, col.columnType, col.columnIndex)
+		if col.columnType == TypeList {
+			lv, err := parquetListValues(v, col.elemType, col.columnIndex)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", col.columnName, err)
+			}
+
+			row = append(row, lv...)
+
+			continue
+		}
+
+		var (
+			pv  parquet.Value
+			err error
+		)
+
+		if col.columnType == TypeDecimal {
+			pv, err = parquetDecimalValue(v, col.precision, col.scale, col.columnIndex)
+		} else {
+			pv, err = parquetValue(v, col.columnType, col.columnIndex)
+		}
+
 		if err != nil {
 			return fmt.Errorf("column %s: %w", col.columnName, err)
 		}
@@ -310,6 +375,10 @@ func parquetValue(v Value, inferredType Type, columnIndex int) (parquet.Value, e
 	case TypeInt:
 		switch v.Type { //nolint:exhaustive
 		case TypeFloat:
+			if v.IsInt {
+				return parquet.Int64Value(v.Int).Level(0, 1, columnIndex), nil
+			}
+
 			return parquet.Int64Value(int64(v.Number)).Level(0, 1, columnIndex), nil
 		case TypeBool:
 			if v.Bool {
@@ -347,11 +416,109 @@ func parquetValue(v Value, inferredType Type, columnIndex int) (parquet.Value, e
 		default:
 			return parquet.Value{}, fmt.Errorf("unexpected value type %s for float column", v.Type)
 		}
+	case TypeTimestamp:
+		t, ok := valueTime(v, "", nil)
+		if !ok {
+			return parquet.Value{}, fmt.Errorf("parse timestamp value %q", v.Format())
+		}
+
+		return parquet.Int64Value(t.UnixMicro()).Level(0, 1, columnIndex), nil
+	case TypeUUID:
+		u, ok := parseUUID([]byte(v.Format()))
+		if !ok {
+			return parquet.Value{}, fmt.Errorf("parse UUID value %q", v.Format())
+		}
+
+		return parquet.FixedLenByteArrayValue(u[:]).Level(0, 1, columnIndex), nil
 	default:
 		return parquet.ByteArrayValue([]byte(v.Format())).Level(0, 1, columnIndex), nil
 	}
 }
 
+// parquetDecimalValue encodes decimal value as unscaled integer of decimal node physical type.
+func parquetDecimalValue(v Value, precision, scale, columnIndex int) (parquet.Value, error) {
+	if v.Type == TypeNull || v.Type == TypeAbsent {
+		return parquet.ValueOf(nil).Level(0, 0, columnIndex), nil
+	}
+
+	if precision == 0 {
+		precision = defaultDecimalPrecision
+	}
+
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(v.Format()))
+	if !ok {
+		return parquet.Value{}, fmt.Errorf("parse decimal value %q", v.Format())
+	}
+
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	u := new(big.Int).Quo(r.Num(), r.Denom())
+
+	if len(new(big.Int).Abs(u).String()) > precision {
+		return parquet.Value{}, fmt.Errorf("decimal value %q exceeds precision %d", v.Format(), precision)
+	}
+
+	switch {
+	case precision <= 9:
+		return parquet.Int32Value(int32(u.Int64())).Level(0, 1, columnIndex), nil //nolint:gosec // Precision fits.
+	case precision <= 18:
+		return parquet.Int64Value(u.Int64()).Level(0, 1, columnIndex), nil
+	}
+
+	// Big-endian two's complement of 16 bytes.
+	b := make([]byte, 16)
+
+	if u.Sign() < 0 {
+		u.Add(u, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+
+	u.FillBytes(b)
+
+	return parquet.FixedLenByteArrayValue(b).Level(0, 1, columnIndex), nil
+}
+
+// parquetListValues returns leaf values of optional list with optional elements.
+//
+// Definition level is 0 for null list, 1 for empty list, 2 for null element and 3 for element value,
+// repetition level is 1 for elements after the first one.
+func parquetListValues(v Value, elemType Type, columnIndex int) ([]parquet.Value, error) {
+	switch v.Type { //nolint:exhaustive
+	case TypeNull, TypeAbsent:
+		return []parquet.Value{parquet.ValueOf(nil).Level(0, 0, columnIndex)}, nil
+	case TypeList:
+	default:
+		// Value that is not an array in some lines is a single element.
+		v = Value{Type: TypeList, List: []Value{v}}
+	}
+
+	if len(v.List) == 0 {
+		return []parquet.Value{parquet.ValueOf(nil).Level(0, 1, columnIndex)}, nil
+	}
+
+	res := make([]parquet.Value, 0, len(v.List))
+
+	for i, e := range v.List {
+		rep := 1
+		if i == 0 {
+			rep = 0
+		}
+
+		if e.Type == TypeNull || e.Type == TypeAbsent {
+			res = append(res, parquet.ValueOf(nil).Level(rep, 2, columnIndex))
+
+			continue
+		}
+
+		pv, err := parquetValue(e, elemType, columnIndex)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+
+		res = append(res, pv.Level(rep, 3, columnIndex))
+	}
+
+	return res, nil
+}
+
 // Close flushes rows and closes file.
 func (c *ParquetWriter) Close() error {
 	for _, tw := range c.transposed {
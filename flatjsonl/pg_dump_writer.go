@@ -36,6 +36,9 @@ type PGDumpWriter struct {
 	sortedTables     []string
 
 	linesReceived int
+
+	// appended is true to continue existing output of interrupted run or previous run, tables are not created then.
+	appended bool
 }
 
 type csvCopier struct {
@@ -57,10 +60,30 @@ func NewPGDumpWriter(fn string, tableName string, p *Processor) (*PGDumpWriter,
 		csvCopiers: map[string]csvCopier{},
 	}
 
-	if fn == "<nop>" {
+	pos, lastRow, resume := p.cp.resumeOutput()
+
+	switch {
+	case fn == "<nop>":
 		c.f = nopWriter{}
-	} else {
-		c.f, err = os.Create(fn)
+	case resume:
+		size, ok := pos[fn]
+		if !ok {
+			return nil, fmt.Errorf("no position of %s in checkpoint", fn)
+		}
+
+		if c.f, err = openOutputFile(fn, size); err != nil {
+			return nil, err
+		}
+
+		c.appended = true
+		c.linesReceived = int(lastRow)
+	default:
+		if p.f.Append {
+			c.f, err = appendOutputFile(fn, &c.appended)
+		} else {
+			c.f, err = os.Create(fn)
+		}
+
 		if err != nil {
 			return nil, fmt.Errorf("failed to create file: %w", err)
 		}
@@ -83,6 +106,8 @@ func NewPGDumpWriter(fn string, tableName string, p *Processor) (*PGDumpWriter,
 func (c *PGDumpWriter) SetupKeys(keys []flKey) error {
 	c.b = &baseWriter{}
 	c.b.p = c.p
+	c.b.listFormat = pgArray
+	c.b.typedColumns = true
 	c.b.setupKeys(keys)
 
 	if err := c.createTable(c.tableName, c.b.filteredKeys, false); err != nil {
@@ -176,10 +201,15 @@ func (c *PGDumpWriter) flush() error {
 	}
 
 	var status string
-	if c.p.totalLines != 0 {
+
+	switch {
+	case c.p.cfg.Explode != "":
+		// Exploded rows are not comparable with lines.
+		status = fmt.Sprintf("%d rows completed", c.linesReceived)
+	case c.p.totalLines != 0:
 		status = fmt.Sprintf("%d/%d lines completed, %.1f%%",
 			c.linesReceived, c.p.totalLines, 100*float64(c.linesReceived)/float64(c.p.totalLines))
-	} else {
+	default:
 		status = fmt.Sprintf("%d lines completed", c.linesReceived)
 	}
 
@@ -255,8 +285,7 @@ func (c *PGDumpWriter) createTable(tn string, keys []flKey, isTransposed bool) e
 				cw:   csv.NewWriter(cb),
 			}
 
-			_, err := c.f.Write([]byte(createTable))
-			if err != nil {
+			if err := c.writeCreate(createTable); err != nil {
 				return fmt.Errorf("failed to create table with %d keys: %w", len(keys), err)
 			}
 
@@ -290,6 +319,21 @@ func (c *PGDumpWriter) createTable(tn string, keys []flKey, isTransposed bool) e
 			if _, ok := c.p.cfg.ParseTime[k.original]; ok {
 				tp = " TIMESTAMP"
 			}
+		case TypeList:
+			tp = " " + pgListType(k.elemType)
+		case TypeTimestamp:
+			tp = " TIMESTAMP"
+		case TypeUUID:
+			tp = " UUID"
+		case TypeDecimal:
+			tp = " NUMERIC"
+			if k.precision > 0 {
+				tp += "(" + strconv.Itoa(k.precision) + "," + strconv.Itoa(k.scale) + ")"
+			}
+		case TypeJSON:
+			if k.typed {
+				tp = " JSON"
+			}
 		case TypeAbsent, TypeNull:
 			tp = " VARCHAR"
 		}
@@ -301,8 +345,7 @@ func (c *PGDumpWriter) createTable(tn string, keys []flKey, isTransposed bool) e
 	createTable = createTable[:len(createTable)-2] + "\n);\n\n"
 	copyStmt = copyStmt[:len(copyStmt)-1] + ") FROM stdin WITH (FORMAT csv);\n"
 
-	_, err := c.f.Write([]byte(createTable))
-	if err != nil {
+	if err := c.writeCreate(createTable); err != nil {
 		return fmt.Errorf("failed to create table with %d keys: %w", len(keys), err)
 	}
 
@@ -316,6 +359,39 @@ func (c *PGDumpWriter) createTable(tn string, keys []flKey, isTransposed bool) e
 	return nil
 }
 
+// writeCreate writes table statement, unless output is continued and file already has it.
+func (c *PGDumpWriter) writeCreate(createTable string) error {
+	if c.appended {
+		return nil
+	}
+
+	_, err := c.f.Write([]byte(createTable))
+
+	return err
+}
+
+// Flush writes buffered rows to file.
+func (c *PGDumpWriter) Flush() error {
+	if c.linesTx == 0 {
+		return nil
+	}
+
+	c.linesTx = 0
+
+	return c.flush()
+}
+
+func (c *PGDumpWriter) positions(pos map[string]int64) error {
+	p, err := filePosition(c.fn, c.f)
+	if err != nil {
+		return err
+	}
+
+	pos[c.fn] = p
+
+	return nil
+}
+
 // Close flushes CSV and closes output file.
 func (c *PGDumpWriter) Close() error {
 	if err := c.flush(); err != nil {
@@ -324,3 +400,17 @@ func (c *PGDumpWriter) Close() error {
 
 	return c.f.Close()
 }
+
+// pgListType returns array type for elements of list.
+func pgListType(elemType Type) string {
+	switch elemType { //nolint:exhaustive
+	case TypeInt:
+		return "INT8[]"
+	case TypeFloat:
+		return "FLOAT8[]"
+	case TypeBool:
+		return "BOOL[]"
+	default:
+		return "VARCHAR[]"
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -27,6 +28,7 @@ import (
 type Processor struct {
 	Log    func(args ...any)
 	Stdout io.Writer
+	Stdin  io.Reader
 
 	cfg    Config
 	f      Flags
@@ -42,6 +44,15 @@ type Processor struct {
 	replaceRegex map[*regexp.Regexp]string
 	extractRegex map[*regexp.Regexp][]extractor
 	constVals    map[int]string
+	where        *rowFilter
+	computed     []*computedColumn
+	arrays       map[string]arraySummary
+	columnTypes  *columnTypes
+
+	// cp saves state of processing with -checkpoint, it is nil if checkpoints are disabled.
+	cp *checkpointer
+	// st keeps ingested parts of inputs with -state, it is nil if state is disabled.
+	st *stateTracker
 
 	replaceKeys  map[string]string
 	replaceByKey map[string]string
@@ -64,11 +75,18 @@ type Processor struct {
 	lastProgressAt     time.Time
 
 	totalLines int
+	inputLines []int64
+	sampleSeed uint64
 	totalKeys  int64
 	errors     int64
+	linesRead  int64
+	longLines  int64
 	inProgress int64
 
 	throttle int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 // New creates Processor from config.
@@ -79,7 +97,12 @@ func New(f Flags) (*Processor, error) {
 		return nil, err
 	}
 
-	return NewProcessor(f, cfg, f.Inputs()...)
+	inputs, err := f.ExpandInputs()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProcessor(f, cfg, inputs...)
 }
 
 func loadConfig(value string, cfg *Config) error {
@@ -114,15 +137,86 @@ func NewProcessor(f Flags, cfg Config, inputs ...Input) (*Processor, error) { //
 		IncrementalSpeed: true,
 	}
 
+	if err := checkInputMode(f.InputMode); err != nil {
+		return nil, err
+	}
+
+	if err := checkFormat(f.Format); err != nil {
+		return nil, err
+	}
+
+	if err := checkLongLines(f.LongLines); err != nil {
+		return nil, err
+	}
+
+	if err := checkCheckpoint(f); err != nil {
+		return nil, err
+	}
+
+	if err := checkState(f); err != nil {
+		return nil, err
+	}
+
+	if f.Follow && f.LoadSchema == "" && (len(cfg.IncludeKeys) == 0 || len(cfg.IncludeKeysRegex) > 0) {
+		return nil, errors.New("follow mode requires fixed schema, use includeKeys in config or -load-schema")
+	}
+
+	for _, r := range []float64{f.SampleRate, f.SampleRateKeys} {
+		if r < 0 || r > 1 {
+			return nil, fmt.Errorf("sample rate must be between 0 and 1, %v given", r)
+		}
+	}
+
+	if f.MaxErrorRate < 0 || f.MaxErrorRate > 1 {
+		return nil, fmt.Errorf("max error rate must be between 0 and 1, %v given", f.MaxErrorRate)
+	}
+
+	if f.SampleKey != "" && f.SampleRate == 0 {
+		return nil, errors.New("sample key requires sample rate")
+	}
+
 	if f.GetKey != "" {
 		cfg.IncludeKeys = append(cfg.IncludeKeys, f.GetKey)
 	}
 
+	if f.Where != "" {
+		cfg.Where = f.Where
+	}
+
+	var where *rowFilter
+
+	if cfg.Where != "" {
+		w, err := parseFilter(cfg.Where)
+		if err != nil {
+			return nil, fmt.Errorf("where: %w", err)
+		}
+
+		where = w
+	}
+
+	computed, err := parseComputedColumns(cfg.ComputedColumns)
+	if err != nil {
+		return nil, fmt.Errorf("computed columns: %w", err)
+	}
+
+	arrays, err := parseArrays(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("arrays: %w", err)
+	}
+
+	columnTypes, err := parseColumnTypes(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("column types: %w", err)
+	}
+
 	p := &Processor{
 		Log: func(args ...any) {
 			_, _ = fmt.Fprintln(os.Stderr, args...)
 		},
 		Stdout: os.Stdout,
+		Stdin:  os.Stdin,
+
+		stop: make(chan struct{}),
 
 		cfg:    cfg,
 		f:      f,
@@ -130,18 +224,33 @@ func NewProcessor(f Flags, cfg Config, inputs ...Input) (*Processor, error) { //
 
 		pr: pr,
 		w: &Writer{
-			Progress: pr,
+			Progress:   pr,
+			concurrent: f.Follow || f.Unordered,
 		},
 		rd: &Reader{
-			Concurrency:    f.Concurrency,
-			AddSequence:    f.AddSequence,
-			Progress:       pr,
-			Buf:            make([]byte, f.BufSize),
-			ExtractStrings: f.ExtractStrings,
+			Concurrency:       f.Concurrency,
+			AddSequence:       f.AddSequence,
+			AddFile:           f.AddFile,
+			AddLine:           f.AddLine,
+			AddOffset:         f.AddOffset,
+			ArchiveMembers:    splitPatterns(f.ArchiveMembers),
+			InputMode:         f.InputMode,
+			ArrayPath:         parseArrayPath(f.ArrayPath),
+			Format:            f.Format,
+			LogfmtDetectTypes: f.LogfmtDetectTypes,
+			LongLines:         f.LongLines,
+			BufMax:            f.BufSizeMax,
+			Progress:          pr,
+			Buf:               make([]byte, f.BufSize),
+			ExtractStrings:    f.ExtractStrings,
 		},
 		includeKeys:   map[string]int{},
 		constVals:     map[int]string{},
 		canonicalKeys: map[string]flKey{},
+		where:         where,
+		computed:      computed,
+		arrays:        arrays,
+		columnTypes:   columnTypes,
 
 		flKeysList:   make([]string, 0),
 		keyHierarchy: KeyHierarchy{Name: "."},
@@ -152,6 +261,7 @@ func NewProcessor(f Flags, cfg Config, inputs ...Input) (*Processor, error) { //
 	}
 
 	p.rd.Processor = p
+	p.sampleSeed = sampleSeed(f.SampleSeed)
 
 	switch f.Verbosity {
 	case 0:
@@ -184,8 +294,34 @@ func NewProcessor(f Flags, cfg Config, inputs ...Input) (*Processor, error) { //
 		f.MatchLinePrefix = cfg.MatchLinePrefix
 	}
 
-	if f.MatchLinePrefix != "" {
-		p.rd.MatchPrefix = regexp.MustCompile(f.MatchLinePrefix)
+	if len(cfg.MatchLinePrefixes) > 0 && len(f.MatchLinePrefixes) == 0 {
+		f.MatchLinePrefixes = cfg.MatchLinePrefixes
+	}
+
+	for _, mp := range append([]string{f.MatchLinePrefix}, f.MatchLinePrefixes...) {
+		if mp == "" {
+			continue
+		}
+
+		r, err := regexp.Compile(mp)
+		if err != nil {
+			return nil, fmt.Errorf("match line prefix: %w", err)
+		}
+
+		if p.rd.MatchPrefix == nil {
+			p.rd.MatchPrefix = r
+		} else {
+			p.rd.MatchPrefixes = append(p.rd.MatchPrefixes, r)
+		}
+	}
+
+	// Envelope patterns of format preset are tried after custom patterns.
+	for _, r := range formatPrefixRegexps(f.Format) {
+		if p.rd.MatchPrefix == nil {
+			p.rd.MatchPrefix = r
+		} else {
+			p.rd.MatchPrefixes = append(p.rd.MatchPrefixes, r)
+		}
 	}
 
 	p.replaceRegex = map[*regexp.Regexp]string{}
@@ -264,16 +400,121 @@ func (p *Processor) ProgressStatus() (string, time.Time) {
 	return p.lastProgressStatus, p.lastProgressAt
 }
 
+// Stop interrupts following of inputs, output is finished with data that was read so far.
+func (p *Processor) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
 // Process dispatches data from Reader to Writer.
 func (p *Processor) Process() error {
+	defer p.closeInputs()
+
+	if err := p.prepareInputs(); err != nil {
+		return err
+	}
+
+	if p.st != nil && len(p.inputs) == 0 {
+		p.Log("no new data in inputs")
+
+		return p.st.save(p)
+	}
+
+	if p.f.Checkpoint != "" {
+		cp, err := newCheckpointer(p)
+		if err != nil {
+			return err
+		}
+
+		if cp.resumed != nil && cp.resumed.Completed {
+			p.Log("processing is already completed according to checkpoint", p.f.Checkpoint)
+
+			return nil
+		}
+
+		p.cp = cp
+	}
+
+	if err := p.countTotalBytes(); err != nil {
+		return err
+	}
+
+	if err := p.PrepareKeys(); err != nil {
+		return err
+	}
+
+	// Size of streamed input is only known after the first pass.
+	if err := p.countTotalBytes(); err != nil {
+		return err
+	}
+
+	if err := p.WriteOutput(); err != nil {
+		return err
+	}
+
+	if err := p.st.save(p); err != nil {
+		return err
+	}
+
+	return p.maybeShowKeys()
+}
+
+// prepareInputs replaces STDIN file name with a reader, and the last file with FollowReader in follow mode.
+func (p *Processor) prepareInputs() error {
+	for i, in := range p.inputs {
+		if in.FileName == StdinFileName {
+			p.inputs[i] = Input{Reader: NewStdinReader(p.Stdin)}
+
+			continue
+		}
+
+		if p.f.Follow && i == len(p.inputs)-1 && in.Reader == nil {
+			fr, err := NewFollowReader(in.FileName, p.f.FollowPoll, p.stop)
+			if err != nil {
+				return err
+			}
+
+			p.inputs[i].Reader = fr
+		}
+	}
+
+	if p.f.State != "" {
+		if err := p.prepareState(); err != nil {
+			return err
+		}
+	}
+
+	return p.splitInputs()
+}
+
+func (p *Processor) closeInputs() {
+	for _, in := range p.inputs {
+		if c, ok := in.Reader.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				p.Log("failed to close input:", err.Error())
+			}
+		}
+	}
+}
+
+func (p *Processor) countTotalBytes() error {
+	p.rd.totalBytes = 0
+
 	for _, i := range p.inputs {
+		if r, ok := i.Reader.(*RangeReader); ok {
+			p.rd.totalBytes += r.Size()
+
+			continue
+		}
+
 		if i.FileName != "" {
 			fi, err := os.Stat(i.FileName)
 			if err != nil {
 				return fmt.Errorf("stat %s: %w", i.FileName, err)
 			}
 
-			if fi.Size() == 0 {
+			if fi.Size() == 0 && !p.f.Follow {
 				return fmt.Errorf("%s: %w", i.FileName, errEmptyFile)
 			}
 
@@ -283,22 +524,45 @@ func (p *Processor) Process() error {
 		}
 	}
 
-	if err := p.PrepareKeys(); err != nil {
-		return err
-	}
-
-	if err := p.WriteOutput(); err != nil {
-		return err
-	}
+	return nil
+}
 
-	return p.maybeShowKeys()
+// scansKeys checks if keys are scanned in the first pass of reading.
+func (p *Processor) scansKeys() bool {
+	return p.f.LoadSchema == "" && (len(p.includeRegex) > 0 || len(p.cfg.IncludeKeys) == 0)
 }
 
 // PrepareKeys runs first pass of reading if necessary to scan the keys.
 func (p *Processor) PrepareKeys() error {
-	if len(p.includeRegex) == 0 && len(p.cfg.IncludeKeys) > 0 {
+	switch {
+	case p.f.LoadSchema != "":
+		if err := p.loadSchema(p.f.LoadSchema); err != nil {
+			return err
+		}
+
 		p.iterateIncludeKeys()
-	} else {
+	case !p.scansKeys():
+		p.iterateIncludeKeys()
+	case p.cp != nil && p.cp.resumed != nil && p.cp.resumed.Schema != nil:
+		cp := p.cp.resumed
+
+		if err := p.useSchema(*cp.Schema, "checkpoint "+p.f.Checkpoint); err != nil {
+			return err
+		}
+
+		p.inputLines = cp.InputLines
+		p.totalLines = cp.TotalLines
+		p.sampleSeed = cp.SampleSeed
+
+		p.iterateIncludeKeys()
+		p.Log(fmt.Sprintf("keys loaded from checkpoint: %d", len(p.includeKeys)))
+	case p.st.reusedSchema(p) != nil:
+		if err := p.useSchema(*p.st.reusedSchema(p), "state "+p.f.State); err != nil {
+			return err
+		}
+
+		p.iterateIncludeKeys()
+	default:
 		p.pr.Reset()
 
 		p.pr.AddMetrics(progress.Metric{
@@ -321,7 +585,14 @@ func (p *Processor) PrepareKeys() error {
 			Value: func() int64 { return atomic.LoadInt64(&p.throttle) },
 		})
 
+		p.pr.AddMetrics(progress.Metric{
+			Name: "long lines", Type: progress.Gauge,
+			Value: func() int64 { return atomic.LoadInt64(&p.longLines) },
+		})
+
 		atomic.StoreInt64(&p.errors, 0)
+		atomic.StoreInt64(&p.linesRead, 0)
+		atomic.StoreInt64(&p.longLines, 0)
 
 		// Scan available keys.
 		if err := p.scanAvailableKeys(); err != nil {
@@ -334,7 +605,13 @@ func (p *Processor) PrepareKeys() error {
 
 	p.prepareKeys()
 
-	return nil
+	if p.f.SaveSchema != "" {
+		if err := p.saveSchema(p.f.SaveSchema); err != nil {
+			return err
+		}
+	}
+
+	return p.cp.scanned()
 }
 
 // WriteOutput runs second pass of reading to create the output.
@@ -413,6 +690,18 @@ func (p *Processor) showKeysInfo() {
 
 		line := k.replaced + ", TYPE " + string(k.t)
 
+		if k.t == TypeDecimal && k.precision > 0 {
+			line += "(" + strconv.Itoa(k.precision) + "," + strconv.Itoa(k.scale) + ")"
+		}
+
+		if k.typed {
+			line += ", TYPED"
+		}
+
+		if k.inferred {
+			line += ", INFERRED"
+		}
+
 		if k.replaced != k.original {
 			line = k.original + ", REPLACED WITH " + line
 		}
@@ -427,6 +716,10 @@ func (p *Processor) showKeysInfo() {
 			line += ", TRANSPOSED TO " + k.transposeDst
 		}
 
+		if k.computed != nil {
+			line += ", COMPUTED " + k.computed.src
+		}
+
 		if len(k.extractors) > 0 {
 			line += ", EXTRACTED"
 			for _, e := range k.extractors {
@@ -449,8 +742,11 @@ func (p *Processor) showKeysInfo() {
 }
 
 func (p *Processor) setupWriters() error {
+	resume, _, _ := p.cp.resumeOutput()
+	om := outputMode{resume: resume, append: p.f.Append}
+
 	if p.f.CSV != "" {
-		cw, err := NewCSVWriter(p.f.CSV, p.f.CSVNull)
+		cw, err := newCSVWriter(p.f.CSV, p.f.CSVNull, om)
 		if err != nil {
 			return fmt.Errorf("failed to create CSV file: %w", err)
 		}
@@ -505,7 +801,7 @@ func (p *Processor) setupWriters() error {
 	}
 
 	if p.f.Raw != "" {
-		rw, err := NewRawWriter(p.f.Raw, p.f.RawDelim)
+		rw, err := newRawWriter(p.f.Raw, p.f.RawDelim, om)
 		if err != nil {
 			return fmt.Errorf("failed to setup raw writer: %w", err)
 		}
@@ -541,14 +837,34 @@ func (p *Processor) iterateForWriters() error {
 		Value: func() int64 { return atomic.LoadInt64(&p.throttle) },
 	})
 
+	p.pr.AddMetrics(progress.Metric{
+		Name: "long lines", Type: progress.Gauge,
+		Value: func() int64 { return atomic.LoadInt64(&p.longLines) },
+	})
+
 	p.rd.MaxLines = 0
 	atomic.StoreInt64(&p.rd.Sequence, 0)
+
+	if p.st != nil {
+		// Sequence continues from previous run.
+		atomic.StoreInt64(&p.rd.Sequence, p.st.prev.Sequence)
+		p.st.startWriting()
+	}
+
 	atomic.StoreInt64(&p.errors, 0)
+	atomic.StoreInt64(&p.linesRead, 0)
+	atomic.StoreInt64(&p.longLines, 0)
 
 	if p.f.MaxLines > 0 {
 		p.rd.MaxLines = int64(p.f.MaxLines)
 	}
 
+	p.rd.sample = nil
+
+	if p.f.SampleKey == "" {
+		p.rd.sample = newSampler(p.f.SampleRate, p.sampleSeed)
+	}
+
 	includeKeys := make(map[string]int, len(p.includeKeys))
 	for k, i := range p.includeKeys {
 		includeKeys[p.ck(k)] = i
@@ -557,6 +873,7 @@ func (p *Processor) iterateForWriters() error {
 	pkIndex := make(map[uint64]int)
 	pkDst := make(map[uint64]string)
 	pkTimeFmt := make(map[uint64]string)
+	pkElem := make(map[uint64]int)
 
 	p.flKeys.Range(func(key uint64, value flKey) bool {
 		if i, ok := includeKeys[value.canonical]; ok {
@@ -565,6 +882,10 @@ func (p *Processor) iterateForWriters() error {
 			if value.transposeDst != "" {
 				pkDst[key] = value.transposeDst
 			}
+
+			if value.explodeTrimmed != "" {
+				pkElem[key] = value.explodeIdx
+			}
 		}
 
 		if f, ok := p.cfg.ParseTime[value.original]; ok {
@@ -575,46 +896,169 @@ func (p *Processor) iterateForWriters() error {
 	})
 
 	wi := newWriteIterator(p, pkIndex, pkDst, pkTimeFmt)
+	wi.pkElem = pkElem
+
+	if p.st != nil {
+		wi.seqExpected = p.st.prev.Sequence + 1
+
+		if wi.renumbered() {
+			wi.rows = p.st.prev.LastRow
+		}
+	}
+
+	if wi.filter != nil {
+		if err := wi.filter.bind(p, wi.slots, wi.outTimeFmt, wi.timeLocation()); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range wi.computed {
+		if err := c.bind(p, wi.slots, wi.outTimeFmt, wi.timeLocation()); err != nil {
+			return err
+		}
+	}
 
 	if err := p.w.SetupKeys(p.keys); err != nil {
 		return err
 	}
 
-	for _, input := range p.inputs {
-		task := "flattening data"
-		if len(p.inputs) > 1 && input.FileName != "" {
-			task = "flattening data (" + input.FileName + ")"
+	if p.cp != nil {
+		if err := wi.startCheckpoints(); err != nil {
+			return err
 		}
 
-		sess, err := p.rd.session(input, task)
-		if err != nil {
-			if errors.Is(err, errEmptyFile) {
-				continue
-			}
+		done := make(chan struct{})
+		defer close(done)
 
+		go p.cp.watch(p.f.CheckpointInterval, done)
+	}
+
+	if p.f.Follow {
+		done := make(chan struct{})
+		defer close(done)
+
+		go p.flushPeriodically(done)
+	}
+
+	p.rd.prepareSingleKey()
+
+	if p.f.Rejects != "" {
+		rw, err := newRejectsWriter(p.f.Rejects)
+		if err != nil {
 			return err
 		}
 
+		p.rd.rejects = rw
+
+		defer func() {
+			p.rd.rejects = nil
+
+			if err := rw.Close(); err != nil {
+				p.Log(err.Error())
+			}
+		}()
+	}
+
+	_, err := p.readInputs("flattening data", !p.f.Unordered, func(sess *readSession) {
 		sess.lineStarted = wi.lineStarted
 		sess.setupWalker = wi.setupWalker
 		sess.lineFinished = wi.lineFinished
+		sess.lineSkipped = wi.lineSkipped
 
-		err = p.rd.Read(sess)
-		if err != nil {
-			sess.Close()
+		if wi.computed != nil || p.convertsValues() {
+			sess.lineWalked = wi.lineWalked
+		}
 
-			return fmt.Errorf("failed to process file %s: %w", input, err)
+		if !wi.unordered {
+			sess.window = wi.window
 		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to process file: %w", err)
+	}
 
-		sess.Close()
+	if err := p.checkErrorRate(true); err != nil {
+		return err
+	}
+
+	if err := wi.waitPending(); err != nil {
+		return err
+	}
+
+	if wi.reservoir != nil {
+		if err := wi.reservoir.flush(p.w.ReceiveRow); err != nil {
+			return err
+		}
+	}
+
+	if err := wi.checkpoint(atomic.LoadInt64(&wi.seqExpected)-1, true); err != nil {
+		return err
 	}
 
-	return wi.waitPending()
+	p.st.written(wi.lastRow(atomic.LoadInt64(&wi.seqExpected) - 1))
+
+	wi.reportUnknownKeys()
+
+	if wi.filter != nil {
+		p.Log(fmt.Sprintf("rows filtered out: %d", atomic.LoadInt64(&wi.filtered)))
+	}
+
+	if p.convertsValues() {
+		p.Log(fmt.Sprintf("values failed to convert: %d", atomic.LoadInt64(&wi.conversionErrors)))
+	}
+
+	return nil
+}
+
+// flushPeriodically makes written rows available in output while inputs are followed.
+func (p *Processor) flushPeriodically(done <-chan struct{}) {
+	interval := p.f.FlushInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			if err := p.w.Flush(); err != nil {
+				p.Log("failed to flush output:", err.Error())
+			}
+		}
+	}
 }
 
+// readAheadValues limits memory of rows that are finished ahead of written rows.
+const readAheadValues = 1 << 16
+
 type lineBuf struct {
 	h      *hasher
 	values []Value
+
+	// skip is true for lines that are not sampled.
+	skip bool
+	// sampleValue is a value of sample key.
+	sampleValue string
+	// exprValues are values of keys that are used in row filter or computed columns, but are not in output.
+	exprValues []Value
+
+	// elements are values of exploded array, a row is written for every element.
+	elements []elementValue
+	row      []Value
+
+	// rejectErr is the first conversion error of a line that is rejected by columnTypesOnError.
+	rejectErr error
+}
+
+// elementValue is a value of column in exploded array element with index idx.
+type elementValue struct {
+	idx int
+	col int
+	v   Value
 }
 
 func newWriteIterator(p *Processor, pkIndex map[uint64]int, pkDst map[uint64]string, pkTimeFmt map[uint64]string) *writeIterator {
@@ -622,7 +1066,14 @@ func newWriteIterator(p *Processor, pkIndex map[uint64]int, pkDst map[uint64]str
 	wi.pending = xsync.NewMap[int64, *lineBuf]()
 	wi.finished = &sync.Map{}
 
-	if len(p.includeKeys) == 1 {
+	wi.filter = p.where
+	wi.computed = p.computed
+
+	if wi.filter != nil || wi.computed != nil {
+		wi.slots = newExprSlots()
+	}
+
+	if len(p.includeKeys) == 1 && wi.slots == nil {
 		for _, i := range p.includeKeys {
 			kk := p.keys[i]
 			wi.singleKeyHash = newHasher().hashBytes([]byte("." + strings.Join(kk.path, ".")))
@@ -631,13 +1082,57 @@ func newWriteIterator(p *Processor, pkIndex map[uint64]int, pkDst map[uint64]str
 
 	wi.lineBufPool = sync.Pool{
 		New: func() interface{} {
-			return &lineBuf{
+			l := &lineBuf{
 				h:      newHasher(),
 				values: make([]Value, len(p.keys)),
 			}
+
+			if wi.slots != nil {
+				l.exprValues = make([]Value, wi.slots.n)
+			}
+
+			return l
 		},
 	}
 	wi.seqExpected = 1
+	wi.unordered = p.f.Unordered
+
+	if p.f.SampleKey != "" {
+		wi.sampleKey = newHasher().hashBytes([]byte(p.f.SampleKey))
+		wi.keySample = newSampler(p.f.SampleRate, uint64(p.f.SampleSeed))
+	}
+
+	if p.f.SampleSize > 0 {
+		wi.reservoir = newReservoir(p.f.SampleSize, p.sampleSeed)
+	}
+
+	wi.seqIndex = -1
+
+	if i, ok := p.includeKeys["._sequence"]; ok && p.f.AddSequence {
+		wi.seqIndex = i
+	}
+
+	wi.explode = p.cfg.Explode != ""
+	wi.explodeIndex = -1
+
+	if i, ok := p.includeKeys[p.explodeIndexKey()]; ok && wi.explode {
+		wi.explodeIndex = i
+	}
+
+	readAhead := readAheadValues / int64(len(p.keys)+1)
+
+	concurrency := p.f.Concurrency
+	if concurrency == 0 {
+		concurrency = 2 * runtime.NumCPU()
+	}
+
+	if c := int64(2 * concurrency); readAhead < c {
+		// Every worker must be able to take a line to avoid deadlock.
+		readAhead = c
+	}
+
+	wi.window = newReadWindow(&wi.seqExpected, readAhead)
+
 	wi.pkIndex = pkIndex
 	wi.pkDst = pkDst
 	wi.pkTimeFmt = pkTimeFmt
@@ -666,6 +1161,38 @@ func newWriteIterator(p *Processor, pkIndex map[uint64]int, pkDst map[uint64]str
 		},
 	)
 
+	if wi.filter != nil {
+		p.pr.AddMetrics(
+			progress.Metric{
+				Name:  "rows filtered out",
+				Type:  progress.Gauge,
+				Value: func() int64 { return atomic.LoadInt64(&wi.filtered) },
+			},
+		)
+	}
+
+	if p.convertsValues() {
+		p.pr.AddMetrics(
+			progress.Metric{
+				Name:  "values failed to convert",
+				Type:  progress.Gauge,
+				Value: func() int64 { return atomic.LoadInt64(&wi.conversionErrors) },
+			},
+		)
+	}
+
+	if p.f.LoadSchema != "" || p.st.reusedSchema(p) != nil {
+		wi.unknownKeys = xsync.NewMap[uint64, string]()
+
+		p.pr.AddMetrics(
+			progress.Metric{
+				Name:  "keys not in schema",
+				Type:  progress.Gauge,
+				Value: func() int64 { return int64(wi.unknownKeys.Size()) },
+			},
+		)
+	}
+
 	if p.f.Verbosity >= 3 {
 		p.pr.AddMetrics(
 			progress.Metric{
@@ -710,6 +1237,7 @@ type writeIterator struct {
 	pkIndex    map[uint64]int
 	pkDst      map[uint64]string
 	pkTimeFmt  map[uint64]string
+	pkElem     map[uint64]int
 	p          *Processor
 	fieldLimit int
 	outTimeFmt string
@@ -730,6 +1258,41 @@ type writeIterator struct {
 	inProgress int64
 
 	singleKeyHash uint64
+
+	// window blocks reading of lines that are too far ahead of written rows.
+	window *readWindow
+
+	// unordered rows are written as soon as they are finished.
+	unordered bool
+
+	// keySample selects lines by value of a key with sampleKey hash.
+	sampleKey uint64
+	keySample *sampler
+
+	// reservoir keeps a sample of rows to write after all lines are read.
+	reservoir *reservoir
+
+	// computed columns are evaluated on finished lines before filter.
+	computed []*computedColumn
+	// slots keep values of keys that are used in expressions, but are not in output.
+	slots *exprSlots
+
+	// filter skips rows that do not match -where expression, written rows are numbered with rows.
+	filter   *rowFilter
+	filtered int64
+	rows     int64
+	seqIndex int
+
+	// explode writes a row for every element of exploded array, element index is written to explodeIndex column.
+	explode      bool
+	explodeIndex int
+
+	// conversionErrors is a number of values that failed to convert to explicit column type.
+	conversionErrors int64
+
+	// Keys that are missing in loaded schema, nil if schema is scanned.
+	unknownKeys   *xsync.Map[uint64, string]
+	unknownValues int64
 }
 
 func (wi *writeIterator) setupWalker(w *FastWalker) {
@@ -749,7 +1312,7 @@ func (wi *writeIterator) setupWalker(w *FastWalker) {
 		wi.setValue(Value{
 			Type:   TypeString,
 			String: string(value),
-		}, pk, l)
+		}, pk, flatPath, l)
 
 		return k.extractors
 	}
@@ -757,11 +1320,7 @@ func (wi *writeIterator) setupWalker(w *FastWalker) {
 		l, _ := wi.pending.Load(seq)
 		pk := l.h.hashBytes(flatPath)
 
-		wi.setValue(Value{
-			Type:      TypeFloat,
-			Number:    value,
-			RawNumber: string(raw),
-		}, pk, l)
+		wi.setValue(newNumberValue(value, string(raw)), pk, flatPath, l)
 	}
 	w.FnBool = func(seq int64, flatPath []byte, pl int, _ []string, value bool) {
 		l, _ := wi.pending.Load(seq)
@@ -770,7 +1329,7 @@ func (wi *writeIterator) setupWalker(w *FastWalker) {
 		wi.setValue(Value{
 			Type: TypeBool,
 			Bool: value,
-		}, pk, l)
+		}, pk, flatPath, l)
 	}
 	w.FnNull = func(seq int64, flatPath []byte, pl int, _ []string) {
 		l, _ := wi.pending.Load(seq)
@@ -778,45 +1337,64 @@ func (wi *writeIterator) setupWalker(w *FastWalker) {
 
 		wi.setValue(Value{
 			Type: TypeNull,
-		}, pk, l)
+		}, pk, flatPath, l)
+	}
+	w.FnList = func(seq int64, flatPath []byte, pl int, _ []string, values []Value) {
+		l, _ := wi.pending.Load(seq)
+		pk := l.h.hashBytes(flatPath)
+
+		wi.setValue(Value{
+			Type: TypeList,
+			List: values,
+		}, pk, flatPath, l)
 	}
 }
 
-func (wi *writeIterator) setValue(v Value, pk uint64, l *lineBuf) {
+func (wi *writeIterator) setValue(v Value, pk uint64, flatPath []byte, l *lineBuf) {
+	if wi.keySample != nil && pk == wi.sampleKey {
+		l.sampleValue = v.Format()
+	}
+
 	if wi.singleKeyHash != 0 && pk != wi.singleKeyHash {
 		return
 	}
 
 	i, ok := wi.pkIndex[pk]
 	if !ok {
+		if j, ok := wi.slots.slot(pk); ok {
+			if l.exprValues[j].Type == TypeAbsent {
+				l.exprValues[j] = wi.reformatTime(v, pk)
+			}
+
+			return
+		}
+
+		if wi.unknownKeys != nil {
+			wi.unknownKey(pk, flatPath)
+		}
+
 		return
 	}
 
-	if v.Type == TypeString { //nolint:nestif
-		// Reformat time.
-		tf, ok := wi.pkTimeFmt[pk]
-		if ok && tf != "RAW" {
-			var (
-				t   time.Time
-				err error
-			)
-
-			if wi.outputTZ != nil {
-				t, err = time.ParseInLocation(tf, v.String, wi.outputTZ)
-			} else {
-				t, err = time.Parse(tf, v.String)
-			}
+	v = wi.reformatTime(v, pk)
 
-			if err != nil {
-				v.String = fmt.Sprintf("failed to parse time %s: %s", v.String, err)
-			} else {
-				v.String = t.Format(wi.outTimeFmt)
-			}
+	if k := wi.p.keys[i]; k.typed || k.inferred {
+		cv, err := wi.convert(i, v)
+		if err != nil && l.rejectErr == nil {
+			l.rejectErr = err
 		}
+
+		v = cv
 	}
 
 	v.Dst = wi.pkDst[pk]
 
+	if e, ok := wi.pkElem[pk]; ok {
+		l.elements = append(l.elements, elementValue{idx: e, col: i, v: v})
+
+		return
+	}
+
 	ev := l.values[i]
 	t := ev.Type
 
@@ -836,6 +1414,86 @@ func (wi *writeIterator) setValue(v Value, pk uint64, l *lineBuf) {
 	}
 }
 
+// reformatTime converts string value of parseTime key to output time format.
+func (wi *writeIterator) reformatTime(v Value, pk uint64) Value {
+	if v.Type != TypeString {
+		return v
+	}
+
+	tf, ok := wi.pkTimeFmt[pk]
+	if !ok || tf == "RAW" {
+		return v
+	}
+
+	var (
+		t   time.Time
+		err error
+	)
+
+	if wi.outputTZ != nil {
+		t, err = time.ParseInLocation(tf, v.String, wi.outputTZ)
+	} else {
+		t, err = time.Parse(tf, v.String)
+	}
+
+	if err != nil {
+		v.String = fmt.Sprintf("failed to parse time %s: %s", v.String, err)
+	} else {
+		v.String = t.Format(wi.outTimeFmt)
+	}
+
+	return v
+}
+
+// timeLocation returns location of reformatted time values.
+func (wi *writeIterator) timeLocation() *time.Location {
+	if wi.outputTZ != nil {
+		return wi.outputTZ
+	}
+
+	return time.UTC
+}
+
+// unknownKey counts a value of key that is not available in loaded schema.
+func (wi *writeIterator) unknownKey(pk uint64, flatPath []byte) {
+	if _, ok := wi.p.flKeys.Load(pk); ok {
+		return
+	}
+
+	atomic.AddInt64(&wi.unknownValues, 1)
+
+	if _, ok := wi.unknownKeys.Load(pk); !ok {
+		wi.unknownKeys.Store(pk, string(flatPath))
+	}
+}
+
+// reportUnknownKeys logs keys that were skipped because they are missing in loaded schema.
+func (wi *writeIterator) reportUnknownKeys() {
+	if wi.unknownKeys == nil || wi.unknownKeys.Size() == 0 {
+		return
+	}
+
+	var keys []string
+
+	wi.unknownKeys.Range(func(_ uint64, k string) bool {
+		keys = append(keys, k)
+
+		return true
+	})
+
+	sort.Strings(keys)
+
+	const maxListed = 10
+
+	listed := keys
+	if len(listed) > maxListed {
+		listed = append(listed[:maxListed:maxListed], "...")
+	}
+
+	wi.p.Log(fmt.Sprintf("keys not in schema: %d, values skipped: %d, %s",
+		len(keys), atomic.LoadInt64(&wi.unknownValues), strings.Join(listed, ", ")))
+}
+
 func (p *Processor) watchMemUsage() {
 	if p.f.MemLimit == 0 {
 		return
@@ -863,12 +1521,58 @@ func (wi *writeIterator) lineStarted(seq int64) error {
 	return nil
 }
 
+// lineSkipped finishes a line that is not sampled, so that it is not written.
+func (wi *writeIterator) lineSkipped(seq int64) error {
+	if err := wi.lineStarted(seq); err != nil {
+		return err
+	}
+
+	l, _ := wi.pending.Load(seq)
+	l.skip = true
+
+	return wi.lineFinished(seq)
+}
+
+// lineWalked evaluates computed columns, it returns conversion error if line must be rejected.
+func (wi *writeIterator) lineWalked(seq int64) error {
+	l, ok := wi.pending.Load(seq)
+	if !ok {
+		panic("BUG: could not find pending line")
+	}
+
+	if wi.computed != nil && l.rejectErr == nil {
+		wi.evalComputed(l)
+	}
+
+	if l.rejectErr != nil {
+		l.skip = true
+
+		return l.rejectErr
+	}
+
+	return nil
+}
+
 func (wi *writeIterator) lineFinished(seq int64) error {
 	l, ok := wi.pending.LoadAndDelete(seq)
 	if !ok {
 		panic("BUG: could not find pending line to finish")
 	}
 
+	if wi.keySample != nil && !wi.keySample.keepValue(l.sampleValue) {
+		l.skip = true
+	}
+
+	if !l.skip && wi.filter != nil && !wi.filter.match(l) {
+		l.skip = true
+
+		atomic.AddInt64(&wi.filtered, 1)
+	}
+
+	if wi.unordered {
+		return wi.complete(seq, l)
+	}
+
 	if atomic.LoadInt64(&wi.p.throttle) != 0 {
 		for atomic.LoadInt64(&wi.p.throttle) != 0 && atomic.LoadInt64(&wi.seqExpected) != seq {
 			time.Sleep(10 * time.Millisecond)
@@ -902,19 +1606,153 @@ func (wi *writeIterator) complete(seq int64, l *lineBuf) error {
 		l.values[i] = val
 	}
 
-	err := wi.p.w.ReceiveRow(seq, l.values)
+	var err error
+
+	switch {
+	case l.skip:
+	case wi.explode:
+		err = wi.receiveExploded(l)
+	default:
+		err = wi.receive(seq, l.values)
+	}
+
+	if err == nil && wi.p.cp.isDue() {
+		// Completion is serialized, so lines up to expected sequence are committed.
+		err = wi.checkpoint(atomic.LoadInt64(&wi.seqExpected), false)
+	}
 
 	atomic.AddInt64(&wi.seqExpected, 1)
+	wi.window.advanced()
 
 	for i := range l.values {
 		l.values[i] = Value{}
 	}
 
+	for i := range l.exprValues {
+		l.exprValues[i] = Value{}
+	}
+
+	l.elements = l.elements[:0]
+	l.skip = false
+	l.rejectErr = nil
+	l.sampleValue = ""
+
 	wi.lineBufPool.Put(l)
 
 	return err
 }
 
+// receive writes a row of line seq.
+func (wi *writeIterator) receive(seq int64, values []Value) error {
+	if wi.renumbered() {
+		// Filtered out rows do not take sequence numbers, exploded rows take a number for every element.
+		seq = atomic.AddInt64(&wi.rows, 1)
+
+		if wi.seqIndex >= 0 {
+			values[wi.seqIndex] = intValue(seq)
+		}
+	}
+
+	if wi.reservoir != nil {
+		wi.reservoir.offer(seq, values)
+
+		return nil
+	}
+
+	return wi.p.w.ReceiveRow(seq, values)
+}
+
+// receiveExploded writes a row for every element of exploded array with values of line, or a single row with
+// empty element columns if array is empty or missing.
+//
+// Values of transposed keys are only passed with the first element, so that transposed rows are not repeated.
+func (wi *writeIterator) receiveExploded(l *lineBuf) error {
+	if len(l.elements) == 0 {
+		return wi.receive(0, l.values)
+	}
+
+	if len(l.row) != len(l.values) {
+		l.row = make([]Value, len(l.values))
+	}
+
+	sort.SliceStable(l.elements, func(i, j int) bool {
+		return l.elements[i].idx < l.elements[j].idx
+	})
+
+	for start := 0; start < len(l.elements); {
+		idx := l.elements[start].idx
+
+		copy(l.row, l.values)
+
+		if start > 0 {
+			for i, v := range l.row {
+				if v.Dst != "" {
+					l.row[i] = Value{}
+				}
+			}
+		}
+
+		end := start
+		for ; end < len(l.elements) && l.elements[end].idx == idx; end++ {
+			e := l.elements[end]
+			l.row[e.col] = e.v
+		}
+
+		if wi.explodeIndex >= 0 {
+			l.row[wi.explodeIndex] = intValue(int64(idx))
+		}
+
+		if err := wi.receive(0, l.row); err != nil {
+			return err
+		}
+
+		start = end
+	}
+
+	return nil
+}
+
+// renumbered is true if rows in output are numbered separately from lines.
+func (wi *writeIterator) renumbered() bool {
+	return wi.filter != nil || wi.explode
+}
+
+// startCheckpoints continues output from resumed checkpoint and commits initial state of output.
+func (wi *writeIterator) startCheckpoints() error {
+	cp := wi.p.cp
+
+	cp.startWriting()
+
+	if cp.outputResumed() {
+		wi.seqExpected = cp.resumed.Sequence + 1
+		wi.p.rd.resumeSeq = cp.resumed.Sequence
+
+		if wi.renumbered() {
+			wi.rows = cp.resumed.LastRow
+		}
+
+		wi.p.Log(fmt.Sprintf("resuming after line %d", cp.resumed.Sequence))
+	}
+
+	return wi.checkpoint(wi.seqExpected-1, false)
+}
+
+// checkpoint commits output with lines up to seq.
+func (wi *writeIterator) checkpoint(seq int64, completed bool) error {
+	seq, lastRow := wi.lastRow(seq)
+
+	return wi.p.cp.commit(seq, lastRow, completed)
+}
+
+// lastRow returns the last line and row number in output after completion of line seq.
+func (wi *writeIterator) lastRow(seq int64) (int64, int64) {
+	if wi.renumbered() {
+		return seq, atomic.LoadInt64(&wi.rows)
+	}
+
+	return seq, seq
+}
+
 func (wi *writeIterator) checkCompleted() error {
 	for {
 		seqExpected := atomic.LoadInt64(&wi.seqExpected)
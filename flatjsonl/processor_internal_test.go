@@ -158,7 +158,7 @@ func TestReaderRead_stallsWhenAllWorkersWaitBehindBlockedExpectedWrite(t *testin
 
 	sess, err := rd.session(Input{
 		Reader: newTestInputReader("{}\n{}\n{}\n{}\n{}\n{}\n"),
-	}, "test")
+	}, "test", nil)
 	require.NoError(t, err)
 	defer sess.Close()
 
@@ -227,7 +227,7 @@ func TestReaderRead_continuesWhenThrottleIsContinuouslyReasserted(t *testing.T)
 
 	sess, err := rd.session(Input{
 		Reader: newTestInputReader("{}\n"),
-	}, "test")
+	}, "test", nil)
 	require.NoError(t, err)
 	defer sess.Close()
 
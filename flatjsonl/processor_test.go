@@ -1,22 +1,32 @@
 package flatjsonl_test
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"compress/gzip"
+	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/parquet-go/parquet-go"
 	"github.com/parquet-go/parquet-go/format"
+	"github.com/pierrec/lz4/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
 	"github.com/vearutop/flatjsonl/flatjsonl"
 	"gopkg.in/yaml.v3"
 )
@@ -50,7 +60,7 @@ func TestNewProcessor(t *testing.T) {
 
 	require.NoError(t, json.Unmarshal(cj, &cfg))
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -86,7 +96,7 @@ func TestNewProcessor_exclude(t *testing.T) {
 
 	require.NoError(t, json.Unmarshal(cj, &cfg))
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -94,11 +104,11 @@ func TestNewProcessor_exclude(t *testing.T) {
 	b, err := os.ReadFile("testdata/test-exclude.csv")
 	require.NoError(t, err)
 
-	assert.Equal(t, `sequence,name,wins_0_0,wins_1_0,f00_bar VARCHAR(255),f00_qux_baz VARCHAR(255),nested_literal,foo,bar
-1,Gilbert,straight,one pair,1,abc,,,
-2,"""'Alexa'""",two pair,two pair,,,,,
-3,May,,,,,"{""foo"":1, ""bar"": 2}",1,2
-4,Deloise,three of a kind,,,,,,
+	assert.Equal(t, `sequence,name,wins_0_0,wins_1_0,f00_bar VARCHAR(255),f00_qux_baz VARCHAR(255),nested_literal,foo,bar,tail
+1,Gilbert,straight,one pair,1,abc,,,,
+2,"""'Alexa'""",two pair,two pair,,,,,,
+3,May,,,,,"{""foo"":1, ""bar"": 2}",1,2,
+4,Deloise,three of a kind,,,,,,,unexpected non-json tail is ignored
 `, string(b))
 }
 
@@ -123,7 +133,7 @@ func TestNewProcessor_excludeRegex(t *testing.T) {
 
 	require.NoError(t, json.Unmarshal(cj, &cfg))
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -131,11 +141,11 @@ func TestNewProcessor_excludeRegex(t *testing.T) {
 	b, err := os.ReadFile("testdata/test-exclude.csv")
 	require.NoError(t, err)
 
-	assert.Equal(t, `sequence,name,wins_0_0,wins_1_0,f00_bar VARCHAR(255),f00_qux_baz VARCHAR(255),nested_literal,foo,bar
-1,Gilbert,straight,one pair,1,abc,,,
-2,"""'Alexa'""",two pair,two pair,,,,,
-3,May,,,,,"{""foo"":1, ""bar"": 2}",1,2
-4,Deloise,three of a kind,,,,,,
+	assert.Equal(t, `sequence,name,wins_0_0,wins_1_0,f00_bar VARCHAR(255),f00_qux_baz VARCHAR(255),nested_literal,foo,bar,tail
+1,Gilbert,straight,one pair,1,abc,,,,
+2,"""'Alexa'""",two pair,two pair,,,,,,
+3,May,,,,,"{""foo"":1, ""bar"": 2}",1,2,
+4,Deloise,three of a kind,,,,,,,unexpected non-json tail is ignored
 `, string(b))
 }
 
@@ -166,7 +176,7 @@ func TestNewProcessor_concurrency(t *testing.T) {
 
 	require.NoError(t, json.Unmarshal(cj, &cfg))
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -202,7 +212,7 @@ func TestNewProcessor_parquet(t *testing.T) {
 	require.NoError(t, os.RemoveAll(f.Parquet))
 	t.Cleanup(func() { require.NoError(t, os.Remove(f.Parquet)) })
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 	require.NoError(t, proc.Process())
 
@@ -255,7 +265,7 @@ func TestNewProcessor_csvNull(t *testing.T) {
 	require.NoError(t, json.Unmarshal(cj, &cfg))
 	t.Cleanup(func() { require.NoError(t, os.Remove(f.CSV)) })
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -297,7 +307,7 @@ func TestNewProcessor_transpose_parquet(t *testing.T) {
 		t.Cleanup(func(name string) func() { return func() { require.NoError(t, os.Remove(name)) } }(fn))
 	}
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 	require.NoError(t, proc.Process())
 
@@ -327,7 +337,7 @@ func TestNewProcessor_prefixNoJSON(t *testing.T) {
 	f.MatchLinePrefix = `([\w\d-]+) [\w\d]+ ([\d/]+\s[\d:\.]+) (\w+): ([\w\d]+), ([\w\d]+) ([\w\d]+)`
 	f.PrepareOutput()
 
-	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -450,7 +460,7 @@ func TestNewProcessor_coalesceMultipleCols(t *testing.T) {
 			".b": "shared",
 			".c": "shared",
 		},
-	}, f.Inputs()...)
+	}, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -466,6 +476,46 @@ func TestNewProcessor_coalesceMultipleCols(t *testing.T) {
 `, string(b))
 }
 
+func TestNewProcessor_coalesceTypes(t *testing.T) {
+	dir := t.TempDir()
+
+	f := flatjsonl.Flags{}
+	f.Input = "testdata/coalesce.log"
+	f.PGDump = filepath.Join(dir, "out.sql")
+	f.SQLTable = "out"
+	f.SQLMaxCols = 100
+	f.ShowKeysInfo = true
+
+	for _, tc := range []struct {
+		keys []string
+		typ  string
+		col  string
+	}{
+		{keys: []string{".a", ".c"}, typ: "TYPE string", col: `"shared" VARCHAR`},
+		{keys: []string{".a"}, typ: "TYPE int", col: `"shared" INT8`},
+	} {
+		replaceKeys := map[string]string{}
+		for _, k := range tc.keys {
+			replaceKeys[k] = "shared"
+		}
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{ReplaceKeys: replaceKeys}, flagInputs(t, f)...)
+		require.NoError(t, err)
+
+		out := bytes.NewBuffer(nil)
+		proc.Stdout = out
+
+		require.NoError(t, proc.Process())
+
+		// Type of shared column fits values of all keys.
+		assert.Contains(t, out.String(), ".a, REPLACED WITH shared, "+tc.typ+"\n")
+
+		dump, err := os.ReadFile(f.PGDump)
+		require.NoError(t, err)
+		assert.Contains(t, string(dump), tc.col)
+	}
+}
+
 func TestNewProcessor_concatMultipleCols(t *testing.T) {
 	f := flatjsonl.Flags{}
 	f.AddSequence = true
@@ -482,7 +532,7 @@ func TestNewProcessor_concatMultipleCols(t *testing.T) {
 			".b": "shared",
 			".c": "shared",
 		},
-	}, f.Inputs()...)
+	}, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -519,7 +569,7 @@ func TestNewProcessor_constVal(t *testing.T) {
 			".c":        "shared",
 			"const:bar": "bar_name",
 		},
-	}, f.Inputs()...)
+	}, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -559,7 +609,7 @@ func TestNewProcessor_rawWriter(t *testing.T) {
 
 	require.NoError(t, json.Unmarshal(cj, &cfg))
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -605,7 +655,7 @@ func TestNewProcessor_sqlite(t *testing.T) {
 
 	require.NoError(t, json.Unmarshal(cj, &cfg))
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -632,7 +682,7 @@ func TestNewProcessor_transpose(t *testing.T) {
 
 	require.NoError(t, json.Unmarshal(cj, &cfg))
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -735,7 +785,7 @@ func TestNewProcessor_transpose_sqlite(t *testing.T) {
 
 	require.NoError(t, json.Unmarshal(cj, &cfg))
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -763,7 +813,7 @@ func TestNewProcessor_transpose_pg_dump(t *testing.T) {
 		".abaz.a": "abaz_a",
 	}
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -782,6 +832,15 @@ func assertFileEquals(t *testing.T, fn string, contents string) {
 	assert.Equal(t, contents, string(b), fn)
 }
 
+func flagInputs(t *testing.T, f flatjsonl.Flags) []flatjsonl.Input {
+	t.Helper()
+
+	inputs, err := f.ExpandInputs()
+	require.NoError(t, err)
+
+	return inputs
+}
+
 func TestNewProcessor_showKeysInfo(t *testing.T) {
 	f := flatjsonl.Flags{}
 	f.ShowKeysInfo = true
@@ -936,7 +995,7 @@ func TestNewProcessor_transpose_keep_json(t *testing.T) {
 
 	require.NoError(t, json.Unmarshal(cj, &cfg))
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -969,7 +1028,7 @@ func TestNewProcessor_transpose_keep_json_regex(t *testing.T) {
 
 	require.NoError(t, json.Unmarshal(cj, &cfg))
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	require.NoError(t, proc.Process())
@@ -1001,7 +1060,7 @@ func TestNewProcessor_highCardinality(t *testing.T) {
 		require.Contains(t, err.Error(), "no such file or directory")
 	}
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	out := bytes.NewBuffer(nil)
@@ -1050,7 +1109,7 @@ func TestNewProcessor_scalar_vs_array(t *testing.T) {
 
 	var cfg flatjsonl.Config
 
-	proc, err := flatjsonl.NewProcessor(f, cfg, f.Inputs()...)
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
 	require.NoError(t, err)
 
 	out := bytes.NewBuffer(nil)
@@ -1096,3 +1155,2226 @@ keys info:
 	assert.ElementsMatch(t, []string{"integer", "number", "array", "object"}, schema.Properties["a"].Type)
 	assert.ElementsMatch(t, []string{"integer", "string", "boolean"}, schema.Properties["b"].Type)
 }
+
+func TestNewProcessor_stdin(t *testing.T) {
+	coalesce, err := os.ReadFile("testdata/coalesce.log")
+	require.NoError(t, err)
+
+	for _, zst := range []bool{false, true} {
+		t.Run("spill zstd "+strconv.FormatBool(zst), func(t *testing.T) {
+			f := flatjsonl.Flags{}
+			f.AddSequence = true
+			f.Input = flatjsonl.StdinFileName
+			f.CSV = "testdata/stdin.csv"
+			f.SpillDir = t.TempDir()
+			f.SpillZstd = zst
+			f.MaxLinesKeys = 1
+
+			proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+			require.NoError(t, err)
+
+			proc.Stdin = bytes.NewReader(coalesce)
+
+			require.NoError(t, proc.Process())
+
+			assertFileEquals(t, "testdata/stdin.csv", `._sequence,.a,.foo
+1,1,true
+2,123,false
+3,,true
+4,10,true
+`)
+
+			spilled, err := os.ReadDir(f.SpillDir)
+			require.NoError(t, err)
+			assert.Empty(t, spilled)
+		})
+	}
+
+	t.Run("include keys", func(t *testing.T) {
+		f := flatjsonl.Flags{}
+		f.Input = flatjsonl.StdinFileName
+		f.CSV = "testdata/stdin.csv"
+		f.SpillDir = t.TempDir()
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{
+			IncludeKeys: []string{".b", ".foo"},
+		}, flagInputs(t, f)...)
+		require.NoError(t, err)
+
+		proc.Stdin = bytes.NewReader(coalesce)
+
+		require.NoError(t, proc.Process())
+
+		assertFileEquals(t, "testdata/stdin.csv", `.b,.foo
+,true
+b,false
+,true
+,true
+`)
+	})
+}
+
+func TestNewProcessor_sniffCompression(t *testing.T) {
+	coalesce, err := os.ReadFile("testdata/coalesce.log")
+	require.NoError(t, err)
+
+	compressed := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"zst": func(w io.Writer) io.WriteCloser {
+			zw, err := zstd.NewWriter(w)
+			require.NoError(t, err)
+
+			return zw
+		},
+		"xz": func(w io.Writer) io.WriteCloser {
+			xw, err := xz.NewWriter(w)
+			require.NoError(t, err)
+
+			return xw
+		},
+		"lz4": func(w io.Writer) io.WriteCloser { return lz4.NewWriter(w) },
+	}
+
+	inputs := map[string]string{
+		"bzip2": "testdata/coalesce.log.bz2",
+	}
+
+	for codec, newWriter := range compressed {
+		// File names do not reveal compression, like rotated logs.
+		fn := filepath.Join(t.TempDir(), "app.log.1")
+		buf := bytes.NewBuffer(nil)
+		w := newWriter(buf)
+
+		_, err := w.Write(coalesce)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.NoError(t, os.WriteFile(fn, buf.Bytes(), 0o600))
+
+		inputs[codec] = fn
+	}
+
+	for codec, fn := range inputs {
+		t.Run(codec, func(t *testing.T) {
+			f := flatjsonl.Flags{}
+			f.Input = fn
+			f.CSV = "testdata/sniff.csv"
+			f.Concurrency = 1
+			f.Verbosity = 1
+
+			var statuses []string
+
+			proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+			require.NoError(t, err)
+
+			proc.Log = func(args ...any) {
+				statuses = append(statuses, fmt.Sprint(args...))
+			}
+
+			require.NoError(t, proc.Process())
+
+			assertFileEquals(t, "testdata/sniff.csv", `.a,.foo,.b,.c
+1,true,,
+123,false,b,
+,true,,false
+10,true,,
+`)
+			assert.Contains(t, strings.Join(statuses, "\n"), "["+codec+"]")
+		})
+	}
+}
+
+func TestNewProcessor_archive(t *testing.T) {
+	members := []struct {
+		name string
+		data string
+	}{
+		{name: "logs/a.jsonl", data: `{"a":1}` + "\n" + `{"a":2,"b":"x"}` + "\n"},
+		{name: "README.txt", data: "not a json\n"},
+		{name: "logs/b.jsonl", data: `{"b":"y"}` + "\n"},
+	}
+
+	dir := t.TempDir()
+
+	tgz := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(tgz)
+	tw := tar.NewWriter(gw)
+
+	zipped := bytes.NewBuffer(nil)
+	zw := zip.NewWriter(zipped)
+
+	for _, m := range members {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: m.name, Mode: 0o600, Size: int64(len(m.data))}))
+		_, err := tw.Write([]byte(m.data))
+		require.NoError(t, err)
+
+		w, err := zw.Create(m.name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(m.data))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	require.NoError(t, zw.Close())
+
+	inputs := map[string]string{
+		"tar": filepath.Join(dir, "logs.tar.gz"),
+		"zip": filepath.Join(dir, "logs.zip"),
+	}
+
+	require.NoError(t, os.WriteFile(inputs["tar"], tgz.Bytes(), 0o600))
+	require.NoError(t, os.WriteFile(inputs["zip"], zipped.Bytes(), 0o600))
+
+	for kind, fn := range inputs {
+		t.Run(kind, func(t *testing.T) {
+			f := flatjsonl.Flags{}
+			f.Input = fn
+			f.CSV = filepath.Join(dir, kind+".csv")
+			f.AddFile = true
+			f.ArchiveMembers = "*.jsonl"
+			f.Concurrency = 1
+			f.Verbosity = 1
+
+			var statuses []string
+
+			proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+			require.NoError(t, err)
+
+			proc.Log = func(args ...any) {
+				statuses = append(statuses, fmt.Sprint(args...))
+			}
+
+			require.NoError(t, proc.Process())
+
+			assertFileEquals(t, f.CSV, `._file,.a,.b
+`+fn+`/logs/a.jsonl,1,
+`+fn+`/logs/a.jsonl,2,x
+`+fn+`/logs/b.jsonl,,y
+`)
+			assert.Contains(t, strings.Join(statuses, "\n"), "["+kind+"]")
+		})
+	}
+}
+
+func TestNewProcessor_inputModeArray(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "cloudtrail.json")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{
+  "Records": [
+    {
+      "eventName": "GetObject",
+      "requestParameters": {"bucketName": "foo"}
+    },
+    {
+      "eventName": "PutObject",
+      "requestParameters": {"bucketName": "bar"}
+    }
+  ]
+}`), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.InputMode = flatjsonl.InputModeArray
+	f.ArrayPath = ".Records"
+	f.AddSequence = true
+	f.Concurrency = 1
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, `._sequence,.eventName,.requestParameters.bucketName
+1,GetObject,foo
+2,PutObject,bar
+`)
+}
+
+func TestNewProcessor_logfmt(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`2024-01-02T03:04:05Z web-1 level=info msg="request done" dur=12ms status=200 cached=true
+2024-01-02T03:04:06Z web-2 level=error msg="failed \"upstream\"" status=502 retry
+`), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.Format = flatjsonl.FormatLogfmt
+	f.LogfmtDetectTypes = true
+	f.MatchLinePrefix = `^(\S+) (\S+) `
+	f.Concurrency = 1
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, `._prefix.[0],._prefix.[1],.level,.msg,.dur,.status,.cached,.retry
+2024-01-02T03:04:05Z,web-1,info,request done,12ms,200,true,
+2024-01-02T03:04:06Z,web-2,error,"failed ""upstream""",,502,,true
+`)
+
+	f.ShowKeysInfo = true
+	f.CSV = ""
+
+	out := bytes.NewBuffer(nil)
+
+	proc, err = flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+
+	proc.Stdout = out
+
+	require.NoError(t, proc.Process())
+
+	assert.Equal(t, `keys info:
+1: ._prefix.[0], TYPE string
+2: ._prefix.[1], TYPE string
+3: .level, TYPE string
+4: .msg, TYPE string
+5: .dur, TYPE string
+6: .status, TYPE int
+7: .cached, TYPE bool
+8: .retry, TYPE bool
+`, out.String())
+}
+
+func TestNewProcessor_prefixNamedGroups(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`2024-01-02 03:04:05 INFO {"a":1} took 12ms
+[web-1] WARN {"a":2}
+2024-01-02 03:04:07 DEBUG {"a":3}
+`), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.MatchLinePrefix = `^(?P<time>\S+ \S+) (?P<level>\w+) `
+	f.MatchLinePrefixes = []string{`^\[(?P<host>[\w-]+)\] (\w+) `}
+	f.Concurrency = 1
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, `._prefix.time,._prefix.level,._tail,.a,._prefix.host,._prefix.[1]
+2024-01-02 03:04:05,INFO,took 12ms,1,,
+,,,2,web-1,WARN
+2024-01-02 03:04:07,DEBUG,,3,,
+`)
+}
+
+func TestNewProcessor_tailWithoutPrefix(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{"a":1} took 12ms
+{"a":2}
+{"a":3}  retried {twice}
+`), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.Concurrency = 1
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, `._tail,.a
+took 12ms,1
+,2
+retried {twice},3
+`)
+}
+
+func TestNewProcessor_formatPresets(t *testing.T) {
+	for _, tc := range []struct {
+		format   string
+		input    string
+		expected string
+	}{
+		{
+			format: flatjsonl.FormatDocker,
+			input: `{"log":"{\"a\":1,\"msg\":\"hello\"}\n","stream":"stdout","time":"2024-01-01T00:00:00.1Z"}
+{"log":"plain text\n","stream":"stderr","time":"2024-01-01T00:00:01.1Z"}
+`,
+			expected: `._prefix.stream,._prefix.time,.a,.msg,._message
+stdout,2024-01-01T00:00:00.1Z,1,hello,
+stderr,2024-01-01T00:00:01.1Z,,,plain text
+`,
+		},
+		{
+			format: flatjsonl.FormatCRI,
+			input: `2024-01-01T00:00:00.1Z stdout F {"a":1,"msg":"hello"}
+2024-01-01T00:00:01.1Z stdout P {"a":2,
+2024-01-01T00:00:01.2Z stderr F plain text
+2024-01-01T00:00:01.3Z stdout P "msg":"split
+2024-01-01T00:00:01.4Z stdout F  line"}
+`,
+			expected: `._prefix.time,._prefix.stream,._prefix.tag,.a,.msg,._message
+2024-01-01T00:00:00.1Z,stdout,F,1,hello,
+2024-01-01T00:00:01.2Z,stderr,F,,,plain text
+2024-01-01T00:00:01.1Z,stdout,F,2,split line,
+`,
+		},
+		{
+			format: flatjsonl.FormatSyslog,
+			input: `<165>1 2024-01-01T00:00:00.1Z web-1 api 123 ID47 [exampleSDID@32473 iut="3"] {"a":1,"msg":"hello"}
+<34>Oct 11 22:14:15 web-2 su[42]: 'su root' failed
+`,
+			expected: `._prefix.pri,._prefix.version,._prefix.time,._prefix.host,._prefix.app,._prefix.procid,._prefix.msgid,._prefix.sd,.a,.msg,._message
+165,1,2024-01-01T00:00:00.1Z,web-1,api,123,ID47,"[exampleSDID@32473 iut=""3""]",1,hello,
+34,,Oct 11 22:14:15,web-2,su,42,,,,,'su root' failed
+`,
+		},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			dir := t.TempDir()
+			fn := filepath.Join(dir, "app.log")
+
+			require.NoError(t, os.WriteFile(fn, []byte(tc.input), 0o600))
+
+			f := flatjsonl.Flags{}
+			f.Input = fn
+			f.CSV = filepath.Join(dir, "out.csv")
+			f.Format = tc.format
+			f.Concurrency = 1
+
+			proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+			require.NoError(t, err)
+
+			require.NoError(t, proc.Process())
+
+			assertFileEquals(t, f.CSV, tc.expected)
+		})
+	}
+}
+
+func TestNewProcessor_follow(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+	out := filepath.Join(dir, "out.csv")
+
+	appendLines := func(name, lines string) {
+		t.Helper()
+
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+
+		_, err = f.WriteString(lines)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+
+	waitOutput := func(expected string) {
+		t.Helper()
+
+		assert.Eventually(t, func() bool {
+			b, err := os.ReadFile(out)
+
+			return err == nil && string(b) == expected
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	appendLines(fn, `{"a":1,"b":"x"}`+"\n")
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = out
+	f.Follow = true
+	f.FollowPoll = 5 * time.Millisecond
+	f.FlushInterval = 10 * time.Millisecond
+	f.Concurrency = 1
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{IncludeKeys: []string{".a", ".b"}}, flagInputs(t, f)...)
+	require.NoError(t, err)
+
+	done := make(chan error)
+
+	go func() {
+		done <- proc.Process()
+	}()
+
+	waitOutput(".a,.b\n1,x\n")
+
+	appendLines(fn, `{"a":2,"b":"y"}`+"\n"+`{"a":3,`)
+	waitOutput(".a,.b\n1,x\n2,y\n")
+
+	// Partial line is finished before rotation.
+	appendLines(fn, `"b":"z"}`+"\n")
+	require.NoError(t, os.Rename(fn, fn+".1"))
+	appendLines(fn, `{"a":4}`+"\n")
+	waitOutput(".a,.b\n1,x\n2,y\n3,z\n4,\n")
+
+	// Truncated file is read from the beginning, truncation is detected by file size shrinking.
+	require.NoError(t, os.Truncate(fn, 0))
+	time.Sleep(100 * time.Millisecond)
+	appendLines(fn, `{"a":5}`+"\n")
+	waitOutput(".a,.b\n1,x\n2,y\n3,z\n4,\n5,\n")
+
+	proc.Stop()
+	require.NoError(t, <-done)
+
+	assertFileEquals(t, out, ".a,.b\n1,x\n2,y\n3,z\n4,\n5,\n")
+
+	_, err = flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.EqualError(t, err, "follow mode requires fixed schema, use includeKeys in config or -load-schema")
+}
+
+func TestNewProcessor_schema(t *testing.T) {
+	dir := t.TempDir()
+	schemaFile := filepath.Join(dir, "schema.json")
+	in1 := filepath.Join(dir, "day1.jsonl")
+	in2 := filepath.Join(dir, "day2.jsonl")
+
+	require.NoError(t, os.WriteFile(in1, []byte(`{"id":1,"user":{"firstName":"a"},"tags":["x"]}`+"\n"), 0o600))
+	require.NoError(t, os.WriteFile(in2, []byte(`{"id":2,"extra":true,"user":{"firstName":"b","age":3}}`+"\n"), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = in1
+	f.CSV = filepath.Join(dir, "day1.csv")
+	f.ReplaceKeys = true
+	f.SaveSchema = schemaFile
+	f.Concurrency = 1
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, "id,first_name,tags_0\n1,a,x\n")
+
+	// Replaced names are kept from schema, new keys are skipped and reported.
+	f = flatjsonl.Flags{}
+	f.Input = in2
+	f.CSV = filepath.Join(dir, "day2.csv")
+	f.LoadSchema = schemaFile
+	f.Concurrency = 1
+
+	proc, err = flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+
+	var logged []string
+
+	proc.Log = func(args ...any) {
+		logged = append(logged, fmt.Sprint(args...))
+	}
+
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, "id,first_name,tags_0\n2,b,\n")
+	assert.NotContains(t, logged, "scanning keys...")
+	assert.Contains(t, logged, "keys not in schema: 2, values skipped: 2, .extra, .user.age")
+}
+
+func TestNewProcessor_inputConcurrency(t *testing.T) {
+	dir := t.TempDir()
+
+	var inputs []string
+
+	for i := 0; i < 5; i++ {
+		lines := ""
+
+		// Same keys in all lines make keys discovery order deterministic.
+		for j := 0; j < 300+i*10; j++ {
+			lines += fmt.Sprintf(`{"file":%d,"line":%d}`+"\n", i, j)
+		}
+
+		fn := filepath.Join(dir, fmt.Sprintf("%d.jsonl", i))
+		require.NoError(t, os.WriteFile(fn, []byte(lines), 0o600))
+
+		inputs = append(inputs, fn)
+	}
+
+	f := flatjsonl.Flags{}
+	f.Input = strings.Join(inputs, ",")
+	f.AddSequence = true
+	f.Concurrency = 1
+	f.CSV = filepath.Join(dir, "sequential.csv")
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	expected, err := os.ReadFile(f.CSV)
+	require.NoError(t, err)
+
+	f.Concurrency = 4
+	f.InputConcurrency = 3
+	f.CSV = filepath.Join(dir, "concurrent.csv")
+
+	proc, err = flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, string(expected))
+}
+
+// gatedInput is a gzip input that can signal end of data and block in the middle of data.
+type gatedInput struct {
+	*bytes.Reader
+	data   []byte
+	passes int
+
+	// eof is closed when data is read to the end in the write pass.
+	eof chan struct{}
+	// gate blocks reading of the second half of data in the write pass until it is closed.
+	gate    <-chan struct{}
+	blocked bool
+}
+
+func newGatedInput(t *testing.T, lines string) *gatedInput {
+	t.Helper()
+
+	b := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(b)
+	_, err := gw.Write([]byte(lines))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	return &gatedInput{Reader: bytes.NewReader(b.Bytes()), data: b.Bytes(), eof: make(chan struct{})}
+}
+
+func (g *gatedInput) Size() int64 { return int64(len(g.data)) }
+
+func (g *gatedInput) Compression() string { return "" }
+
+func (g *gatedInput) Reset() {
+	g.passes++
+	g.Reader.Reset(g.data)
+}
+
+func (g *gatedInput) Read(p []byte) (int, error) {
+	if g.passes < 2 {
+		return g.Reader.Read(p)
+	}
+
+	if g.gate != nil && !g.blocked && g.Len() < len(g.data)/2 {
+		g.blocked = true
+
+		select {
+		case <-g.gate:
+		case <-time.After(10 * time.Second):
+			return 0, errors.New("following input was not decoded")
+		}
+	}
+
+	n, err := g.Reader.Read(p[:min(len(p), 512)])
+	if errors.Is(err, io.EOF) {
+		close(g.eof)
+	}
+
+	return n, err
+}
+
+func TestNewProcessor_inputConcurrencyDecodesAhead(t *testing.T) {
+	var first, second, expected strings.Builder
+
+	expected.WriteString(".i\n")
+
+	// Second input has more lines than can be read ahead of written rows.
+	for i := 0; i < 100000; i++ {
+		l := fmt.Sprintf(`{"i":%d}`+"\n", i)
+
+		if i < 1000 {
+			first.WriteString(l)
+		} else {
+			second.WriteString(l)
+		}
+
+		expected.WriteString(strconv.Itoa(i) + "\n")
+	}
+
+	in1 := newGatedInput(t, first.String())
+	in2 := newGatedInput(t, second.String())
+
+	// First input can only finish after the second input is decoded.
+	in1.gate = in2.eof
+
+	dir := t.TempDir()
+
+	f := flatjsonl.Flags{}
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.SpillDir = dir
+	f.InputConcurrency = 2
+	f.Concurrency = 2
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{},
+		flatjsonl.Input{Reader: in1}, flatjsonl.Input{Reader: in2})
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	assert.True(t, in1.blocked)
+	assertFileEquals(t, f.CSV, expected.String())
+
+	// Spool files are removed.
+	files, err := filepath.Glob(filepath.Join(dir, "*.spool"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestNewProcessor_splitRanges(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "large.jsonl")
+
+	var lines, expected strings.Builder
+
+	expected.WriteString(".id,.v\n")
+
+	for i := 0; i < 10000; i++ {
+		lines.WriteString(fmt.Sprintf(`{"id":%d,"v":"value %d"}`+"\n", i, i%7))
+		expected.WriteString(fmt.Sprintf("%d,value %d\n", i, i%7))
+	}
+
+	require.NoError(t, os.WriteFile(fn, []byte(lines.String()), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.SplitRanges = 4
+	f.Concurrency = 2
+	f.CSV = filepath.Join(dir, "ordered.csv")
+
+	// Line counts of ranges from keys scanning keep rows ordered.
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, expected.String())
+
+	// Without keys scanning rows are written in any order.
+	f.Unordered = true
+	f.CSV = filepath.Join(dir, "unordered.csv")
+
+	proc, err = flatjsonl.NewProcessor(f, flatjsonl.Config{IncludeKeys: []string{".id", ".v"}}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	b, err := os.ReadFile(f.CSV)
+	require.NoError(t, err)
+
+	rows := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	expectedRows := strings.Split(strings.TrimSuffix(expected.String(), "\n"), "\n")
+
+	sort.Strings(rows[1:])
+	sort.Strings(expectedRows[1:])
+	assert.Equal(t, expectedRows, rows)
+}
+
+func TestNewProcessor_unorderedConcurrentRows(t *testing.T) {
+	// Rows are received by several goroutines at once, race detector needs them to run in parallel.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "large.jsonl")
+
+	var lines strings.Builder
+
+	expected := make([]string, 0, 50000)
+
+	for i := 0; i < 50000; i++ {
+		lines.WriteString(fmt.Sprintf(`{"id":%d,"v":"value %d"}`+"\n", i, i%7))
+		expected = append(expected, fmt.Sprintf("%d,value %d", i, i%7))
+	}
+
+	require.NoError(t, os.WriteFile(fn, []byte(lines.String()), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.SplitRanges = 8
+	f.Concurrency = 8
+	f.Unordered = true
+	f.CSV = filepath.Join(dir, "out.csv")
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{IncludeKeys: []string{".id", ".v"}}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	b, err := os.ReadFile(f.CSV)
+	require.NoError(t, err)
+
+	rows := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	assert.Equal(t, ".id,.v", rows[0])
+
+	rows = rows[1:]
+
+	sort.Strings(rows)
+	sort.Strings(expected)
+	assert.Equal(t, expected, rows)
+}
+
+func TestNewProcessor_sample(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "requests.jsonl")
+
+	var lines strings.Builder
+
+	for i := 0; i < 1000; i++ {
+		lines.WriteString(fmt.Sprintf(`{"id":%d,"req":"r%d"}`+"\n", i, i%50))
+	}
+
+	require.NoError(t, os.WriteFile(fn, []byte(lines.String()), 0o600))
+
+	readRows := func(fn string) [][]string {
+		t.Helper()
+
+		f, err := os.Open(fn)
+		require.NoError(t, err)
+
+		defer f.Close()
+
+		rows, err := csv.NewReader(f).ReadAll()
+		require.NoError(t, err)
+
+		return rows[1:]
+	}
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.Concurrency = 2
+
+	t.Run("rate", func(t *testing.T) {
+		f := f
+		f.CSV = filepath.Join(dir, "rate.csv")
+		f.SampleRate = 0.5
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		rows := readRows(f.CSV)
+		assert.Greater(t, len(rows), 350)
+		assert.Less(t, len(rows), 650)
+	})
+
+	t.Run("key", func(t *testing.T) {
+		f := f
+		f.CSV = filepath.Join(dir, "key.csv")
+		f.SampleRate = 0.3
+		f.SampleKey = ".req"
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		perReq := map[string]int{}
+		for _, r := range readRows(f.CSV) {
+			perReq[r[1]]++
+		}
+
+		assert.NotEmpty(t, perReq)
+		assert.Less(t, len(perReq), 50)
+
+		// All lines of a request are kept together.
+		for req, cnt := range perReq {
+			assert.Equal(t, 20, cnt, req)
+		}
+	})
+
+	t.Run("size", func(t *testing.T) {
+		f := f
+		f.CSV = filepath.Join(dir, "size.csv")
+		f.SampleSize = 10
+		f.SampleSeed = 1
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		rows := readRows(f.CSV)
+		require.Len(t, rows, 10)
+
+		// Sampled rows keep order of lines.
+		assert.True(t, sort.SliceIsSorted(rows, func(i, j int) bool {
+			a, _ := strconv.Atoi(rows[i][0])
+			b, _ := strconv.Atoi(rows[j][0])
+
+			return a < b
+		}))
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		f := f
+		f.CSV = filepath.Join(dir, "keys.csv")
+		f.SampleRateKeys = 0.1
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		// Scanning is sampled, output is complete.
+		assert.Len(t, readRows(f.CSV), 1000)
+	})
+
+	_, err := flatjsonl.NewProcessor(flatjsonl.Flags{SampleRate: 2}, flatjsonl.Config{})
+	require.EqualError(t, err, "sample rate must be between 0 and 1, 2 given")
+}
+
+func TestNewProcessor_where(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{"time":"2024-01-01 10:00:00","level":"info","status":200,"msg":"ok","req":{"id":"a1"}}
+{"time":"2024-01-02 10:00:00","level":"error","status":502,"msg":"upstream timeout","req":{"id":"b2"}}
+{"time":"2024-01-03 10:00:00","level":"error","status":404,"msg":"not found","req":{"id":"c3"}}
+{"time":"2024-01-04 10:00:00","level":"error","status":500,"msg":"panic","panic":true,"req":{"id":"d4"}}
+{"time":"2024-01-05 10:00:00","level":"warn","status":503,"msg":"connection refused","req":{"id":"e5"}}
+`), 0o600))
+
+	for _, tc := range []struct {
+		name     string
+		where    string
+		cfgWhere string
+		expected string
+	}{
+		{
+			name:  "original keys",
+			where: `.level == "error" && .status >= 500`,
+			expected: `sequence,time,level,status,msg,id,panic
+1,2024-01-02T10:00:00Z,error,502,upstream timeout,b2,
+2,2024-01-04T10:00:00Z,error,500,panic,d4,true
+`,
+		},
+		{
+			name:  "replaced keys and regex",
+			where: `msg =~ "timeout|refused" || (exists(.panic) && !(status != 500))`,
+			expected: `sequence,time,level,status,msg,id,panic
+1,2024-01-02T10:00:00Z,error,502,upstream timeout,b2,
+2,2024-01-04T10:00:00Z,error,500,panic,d4,true
+3,2024-01-05T10:00:00Z,warn,503,connection refused,e5,
+`,
+		},
+		{
+			name:     "time range from config",
+			cfgWhere: `.time >= "2024-01-02" && .time < "2024-01-04T00:00:00Z" && 'b2' != .req.id`,
+			expected: `sequence,time,level,status,msg,id,panic
+1,2024-01-03T10:00:00Z,error,404,not found,c3,
+`,
+		},
+		{
+			name:  "null and absent",
+			where: `.panic == null && .status < 300`,
+			expected: `sequence,time,level,status,msg,id,panic
+1,2024-01-01T10:00:00Z,info,200,ok,a1,
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := flatjsonl.Flags{}
+			f.Input = fn
+			f.CSV = filepath.Join(dir, "out.csv")
+			f.AddSequence = true
+			f.ReplaceKeys = true
+			f.Concurrency = 1
+			f.Where = tc.where
+
+			cfg := flatjsonl.Config{
+				ParseTime: map[string]string{".time": "2006-01-02 15:04:05"},
+				Where:     tc.cfgWhere,
+			}
+
+			proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+			require.NoError(t, err)
+			require.NoError(t, proc.Process())
+
+			assertFileEquals(t, f.CSV, tc.expected)
+		})
+	}
+
+	t.Run("key not in output", func(t *testing.T) {
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.CSV = filepath.Join(dir, "out.csv")
+		f.Concurrency = 1
+		f.Where = `.level != "error"`
+
+		cfg := flatjsonl.Config{IncludeKeys: []string{".req.id", ".msg"}}
+
+		proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		assertFileEquals(t, f.CSV, `.req.id,.msg
+a1,ok
+e5,connection refused
+`)
+	})
+
+	t.Run("renumbered sequence", func(t *testing.T) {
+		fn := filepath.Join(dir, "many.log")
+		buf := bytes.NewBuffer(nil)
+
+		for i := 0; i <= 100000; i++ {
+			buf.WriteString(`{"a":` + strconv.Itoa(i) + "}\n")
+		}
+
+		require.NoError(t, os.WriteFile(fn, buf.Bytes(), 0o600))
+
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.CSV = filepath.Join(dir, "out.csv")
+		f.AddSequence = true
+		f.Where = `.a > 0`
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		b, err := os.ReadFile(f.CSV)
+		require.NoError(t, err)
+		assert.True(t, bytes.HasSuffix(b, []byte("\n99999,99999\n100000,100000\n")), string(b[len(b)-50:]))
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.CSV = filepath.Join(dir, "out.csv")
+		f.Where = `lvl == "error"`
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.EqualError(t, proc.Process(), "where: unknown column lvl")
+	})
+
+	_, err := flatjsonl.NewProcessor(flatjsonl.Flags{Where: `.level == `}, flatjsonl.Config{})
+	require.EqualError(t, err, "where: unexpected end of expression")
+}
+
+func TestNewProcessor_computedColumns(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{"time":"2024-01-01 10:00:00","duration":0.25,"status":200,"req":{"host":"Example.COM","path":"/users?id=7"}}
+{"time":"2024-01-02 10:00:00","duration":1,"status":502,"level":"error","req":{"host":"foo.org","path":"/health"}}
+{"status":404}
+`), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.SQLite = filepath.Join(dir, "out.sqlite")
+	f.SQLTable = "out"
+	f.ReplaceKeys = true
+	f.ShowKeysInfo = true
+	f.Concurrency = 1
+
+	cfg := flatjsonl.Config{
+		ParseTime:   map[string]string{".time": "2006-01-02 15:04:05"},
+		ExcludeKeys: []string{".level"},
+		ComputedColumns: map[string]string{
+			"latency_ms": ".duration * 1000",
+			"host_lc":    "lower(host)",
+			"is_error":   ".status >= 500",
+			"kind":       `if(.status >= 500, coalesce(.level, "unknown"), "ok")`,
+			"uid":        `regex_extract(.req.path, 'id=(\d+)', 1)`,
+			"ts":         "unix(.time)",
+			"next":       "status + 1",
+		},
+	}
+
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+	require.NoError(t, err)
+
+	out := bytes.NewBuffer(nil)
+	proc.Stdout = out
+
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, `time,duration,status,host,path,host_lc,is_error,kind,latency_ms,next,ts,uid
+2024-01-01T10:00:00Z,0.25,200,Example.COM,/users?id=7,example.com,false,ok,250,201,1704103200,7
+2024-01-02T10:00:00Z,1,502,foo.org,/health,foo.org,true,error,1000,503,1704189600,
+,,404,,,,false,ok,,405,,
+`)
+
+	assert.Contains(t, out.String(), "6: host_lc, TYPE string, COMPUTED lower(host)")
+	assert.Contains(t, out.String(), "9: latency_ms, TYPE float, COMPUTED .duration * 1000")
+	assert.Contains(t, out.String(), "10: next, TYPE int, COMPUTED status + 1")
+
+	db, err := sql.Open("sqlite", f.SQLite)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	var (
+		latency float64
+		isError bool
+	)
+
+	require.NoError(t, db.QueryRow(`SELECT latency_ms, is_error FROM out WHERE status = 502`).Scan(&latency, &isError))
+	assert.Equal(t, 1000.0, latency)
+	assert.True(t, isError)
+
+	t.Run("filter by computed column", func(t *testing.T) {
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.CSV = filepath.Join(dir, "out.csv")
+		f.Concurrency = 1
+		f.Where = "is_error == true"
+
+		cfg := flatjsonl.Config{
+			IncludeKeys:     []string{".status"},
+			ComputedColumns: map[string]string{"is_error": ".status >= 500"},
+		}
+
+		proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		assertFileEquals(t, f.CSV, `.status,is_error
+502,true
+`)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.CSV = filepath.Join(dir, "out.csv")
+		f.ReplaceKeys = true
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{ComputedColumns: map[string]string{
+			"a": "lower(hst)",
+		}}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.EqualError(t, proc.Process(), "computed column a: unknown column hst")
+
+		proc, err = flatjsonl.NewProcessor(f, flatjsonl.Config{ComputedColumns: map[string]string{
+			"status": ".status * 2",
+		}}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.EqualError(t, proc.Process(), "computed column status: column name is not unique")
+
+		proc, err = flatjsonl.NewProcessor(f, flatjsonl.Config{ComputedColumns: map[string]string{
+			"a": "1",
+			"b": "a + 1",
+		}}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.EqualError(t, proc.Process(), "computed column b: can not refer to computed column a")
+
+		_, err = flatjsonl.NewProcessor(f, flatjsonl.Config{ComputedColumns: map[string]string{
+			"a": "lower(",
+		}})
+		require.EqualError(t, err, "computed columns: a: unexpected end of expression")
+	})
+}
+
+func TestNewProcessor_columnTypes(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{"id":"1","amount":"12.345","at":"2024-01-02 03:04:05","ok":"true","meta":"x"}
+{"id":2,"amount":7,"at":1704164645,"ok":0,"meta":5}
+{"id":"three","amount":"1e2","at":"bad","ok":"maybe","meta":null}
+`), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.PGDump = filepath.Join(dir, "out.sql")
+	f.SQLite = filepath.Join(dir, "out.sqlite")
+	f.Parquet = filepath.Join(dir, "out.parquet")
+	f.SQLTable = "out"
+	f.ReplaceKeys = true
+	f.ShowKeysInfo = true
+	f.Concurrency = 1
+
+	cfg := flatjsonl.Config{
+		ColumnTypes: map[string]string{
+			".id":    "int",
+			"amount": "decimal(10,2)",
+			"cents":  "decimal(12,0)",
+		},
+		ColumnTypesRegex: map[string]string{
+			"^\\.at$":   "timestamp",
+			"^\\.ok$":   "bool",
+			"^\\.meta$": "json",
+		},
+		ComputedColumns: map[string]string{"cents": "amount * 100"},
+	}
+
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+	require.NoError(t, err)
+
+	out := bytes.NewBuffer(nil)
+	proc.Stdout = out
+
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, `id,amount,at,ok,meta,cents
+1,12.35,2024-01-02T03:04:05Z,true,"""x""",1235
+2,7,2024-01-02T03:04:05Z,false,5,700
+,100,,,,10000
+`)
+
+	assert.Contains(t, out.String(), "2: .amount, REPLACED WITH amount, TYPE decimal(10,2), TYPED")
+
+	dump, err := os.ReadFile(f.PGDump)
+	require.NoError(t, err)
+	assert.Contains(t, string(dump), `"id" INT8,`)
+	assert.Contains(t, string(dump), `"amount" NUMERIC(10,2),`)
+	assert.Contains(t, string(dump), `"at" TIMESTAMP,`)
+	assert.Contains(t, string(dump), `"meta" JSON,`)
+
+	db, err := sql.Open("sqlite", f.SQLite)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	var (
+		id     int
+		amount float64
+	)
+
+	require.NoError(t, db.QueryRow(`SELECT id, amount FROM out WHERE cents = 1235`).Scan(&id, &amount))
+	assert.Equal(t, 1, id)
+	assert.Equal(t, 12.35, amount)
+
+	assert.Equal(t, []map[string]string{
+		{"id": "1", "amount": "1235", "at": "1704164645000000", "ok": "true", "meta": `"x"`, "cents": "1235"},
+		{"id": "2", "amount": "700", "at": "1704164645000000", "ok": "false", "meta": "5", "cents": "700"},
+		{"amount": "10000", "cents": "10000"},
+	}, readParquetRows(t, f.Parquet))
+
+	t.Run("raw", func(t *testing.T) {
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.CSV = filepath.Join(dir, "raw.csv")
+		f.Concurrency = 1
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{
+			IncludeKeys:        []string{".id"},
+			ColumnTypes:        map[string]string{".id": "int"},
+			ColumnTypesOnError: "raw",
+		}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		assertFileEquals(t, f.CSV, `.id
+1
+2
+three
+`)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.CSV = filepath.Join(dir, "reject.csv")
+		f.Rejects = filepath.Join(dir, "rejects.tsv")
+		f.Concurrency = 1
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{
+			IncludeKeys:        []string{".id", ".ok"},
+			ColumnTypes:        map[string]string{".id": "int", ".ok": "bool"},
+			ColumnTypesOnError: "REJECT",
+		}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		assertFileEquals(t, f.CSV, `.id,.ok
+1,true
+2,false
+`)
+		assertFileEquals(t, f.Rejects, fn+"\t3\tcolumn type mismatch: .id: \"three\" is not a number\t"+
+			`{"id":"three","amount":"1e2","at":"bad","ok":"maybe","meta":null}`+"\n")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := flatjsonl.NewProcessor(f, flatjsonl.Config{ColumnTypes: map[string]string{".id": "varchar"}})
+		require.EqualError(t, err, `column types: .id: unknown column type "varchar"`)
+
+		_, err = flatjsonl.NewProcessor(f, flatjsonl.Config{
+			ColumnTypes:        map[string]string{".id": "int"},
+			ColumnTypesOnError: "skip",
+		})
+		require.EqualError(t, err, `column types: unknown conversion error policy "skip"`)
+	})
+}
+
+func TestNewProcessor_exactNumbers(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{"id":9007199254740993,"big":18446744073709551615,"amount":0.1}
+{"id":9007199254740992,"big":123456789012345678901234567890,"amount":0.2}
+{"id":-9223372036854775808,"big":1,"amount":0.3}
+`), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.PGDump = filepath.Join(dir, "out.sql")
+	f.SQLite = filepath.Join(dir, "out.sqlite")
+	f.Parquet = filepath.Join(dir, "out.parquet")
+	f.SQLTable = "out"
+	f.ReplaceKeys = true
+	f.ShowKeysInfo = true
+	f.Concurrency = 1
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+
+	out := bytes.NewBuffer(nil)
+	proc.Stdout = out
+
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, `id,big,amount
+9007199254740993,18446744073709551615,0.1
+9007199254740992,123456789012345678901234567890,0.2
+-9223372036854775808,1,0.3
+`)
+
+	assert.Contains(t, out.String(), "1: .id, REPLACED WITH id, TYPE int\n2: .big, REPLACED WITH big, TYPE decimal\n")
+
+	dump, err := os.ReadFile(f.PGDump)
+	require.NoError(t, err)
+	assert.Contains(t, string(dump), `"id" INT8,`)
+	assert.Contains(t, string(dump), `"big" NUMERIC,`)
+
+	db, err := sql.Open("sqlite", f.SQLite)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	var (
+		id  int64
+		big string
+	)
+
+	require.NoError(t, db.QueryRow(`SELECT id, big FROM out WHERE id = 9007199254740993`).Scan(&id, &big))
+	assert.Equal(t, int64(9007199254740993), id)
+	assert.Equal(t, "18446744073709551615", big)
+
+	assert.Equal(t, []map[string]string{
+		{"id": "9007199254740993", "big": "\x00\x00\x00\x00\x00\x00\x00\x00\xff\xff\xff\xff\xff\xff\xff\xff", "amount": "0.1"},
+		{"id": "9007199254740992", "big": "\x00\x00\x00\x01\x8e\xe9\x0f\xf6\xc3\x73\xe0\xee\x4e\x3f\x0a\xd2", "amount": "0.2"},
+		{"id": "-9223372036854775808", "big": "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01", "amount": "0.3"},
+	}, readParquetRows(t, f.Parquet))
+
+	t.Run("filter", func(t *testing.T) {
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.CSV = filepath.Join(dir, "filtered.csv")
+		f.Concurrency = 1
+		f.Where = ".id == 9007199254740993"
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{IncludeKeys: []string{".id"}}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		assertFileEquals(t, f.CSV, `.id
+9007199254740993
+`)
+	})
+
+	t.Run("beyond decimal precision", func(t *testing.T) {
+		fn := filepath.Join(dir, "huge.log")
+
+		require.NoError(t, os.WriteFile(fn, []byte(`{"big":12345678901234567890123456789012345678901234567890}
+{"big":1}
+`), 0o600))
+
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.PGDump = filepath.Join(dir, "huge.sql")
+		f.Parquet = filepath.Join(dir, "huge.parquet")
+		f.SQLTable = "out"
+		f.SQLMaxCols = 100
+		f.Concurrency = 1
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		dump, err := os.ReadFile(f.PGDump)
+		require.NoError(t, err)
+		assert.Contains(t, string(dump), `".big" VARCHAR`)
+
+		assert.Equal(t, []map[string]string{
+			{".big": "12345678901234567890123456789012345678901234567890"},
+			{".big": "1"},
+		}, readParquetRows(t, f.Parquet))
+	})
+}
+
+func TestNewProcessor_fractionalDecimals(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{"amount":12.34,"mixed":18446744073709551615,"price":"0.1"}
+{"amount":5,"mixed":12.5,"price":"18446744073709551615"}
+`), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.PGDump = filepath.Join(dir, "out.sql")
+	f.Parquet = filepath.Join(dir, "out.parquet")
+	f.SQLTable = "out"
+	f.SQLMaxCols = 100
+	f.InferTypes = true
+	f.Concurrency = 1
+
+	cfg := flatjsonl.Config{ColumnTypes: map[string]string{".amount": "decimal(12,2)"}}
+
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, `.amount,.mixed,.price
+12.34,18446744073709551615,0.1
+5,12.5,18446744073709551615
+`)
+
+	dump, err := os.ReadFile(f.PGDump)
+	require.NoError(t, err)
+	assert.Contains(t, string(dump), `".amount" NUMERIC(12,2),`)
+	assert.Contains(t, string(dump), `".mixed" NUMERIC,`)
+	assert.Contains(t, string(dump), `".price" NUMERIC`)
+
+	r := parquet.NewReader(openParquetFile(t, f.Parquet))
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	for name, exp := range map[string]string{".amount": "DECIMAL(12,2)", ".mixed": "DECIMAL(38,1)", ".price": "DECIMAL(38,1)"} {
+		col, ok := r.Schema().Lookup(name)
+		require.True(t, ok, name)
+		assert.Equal(t, exp, col.Node.Type().LogicalType().String(), name)
+	}
+
+	rows := readParquetRows(t, f.Parquet)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "1234", rows[0][".amount"])
+	assert.Equal(t, "500", rows[1][".amount"])
+	// 125 is unscaled 12.5 with scale 1.
+	assert.Equal(t, "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7d", rows[1][".mixed"])
+}
+
+func TestNewProcessor_inferTypes(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{"status":"200","ok":"true","ts":"2024-05-01T10:00:00Z","at":"1714557600123","id":"0b6e8d1a-3c4f-4a5e-9f7b-2d1c0e9a8b7c","dur":"0.5","zip":"01234","v":"1"}
+{"status":404,"ok":false,"ts":"2024-05-01T12:00:00+02:00","at":"1714557601000","id":"7f0c2e3a-1b2c-4d5e-8f9a-0b1c2d3e4f5a","dur":"2","zip":"12345","v":"x"}
+{"status":"","ok":"false","dur":"1e1","zip":"54321"}
+`), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.PGDump = filepath.Join(dir, "out.sql")
+	f.Parquet = filepath.Join(dir, "out.parquet")
+	f.SQLTable = "out"
+	f.SQLMaxCols = 100
+	f.ReplaceKeys = true
+	f.ShowKeysInfo = true
+	f.InferTypes = true
+	f.InferEpoch = true
+	f.SaveSchema = filepath.Join(dir, "schema.json")
+	f.Concurrency = 1
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+
+	out := bytes.NewBuffer(nil)
+	proc.Stdout = out
+
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, `status,ok,ts,at,id,dur,zip,v
+200,true,2024-05-01T10:00:00Z,2024-05-01T10:00:00Z,0b6e8d1a-3c4f-4a5e-9f7b-2d1c0e9a8b7c,0.5,01234,1
+404,false,2024-05-01T12:00:00+02:00,2024-05-01T10:00:01Z,7f0c2e3a-1b2c-4d5e-8f9a-0b1c2d3e4f5a,2,12345,x
+,false,,,,1e1,54321,
+`)
+
+	assert.Contains(t, out.String(), "1: .status, REPLACED WITH status, TYPE int, INFERRED\n")
+	assert.Contains(t, out.String(), "4: .at, REPLACED WITH at, TYPE timestamp, INFERRED\n")
+	assert.Contains(t, out.String(), "7: .zip, REPLACED WITH zip, TYPE string\n")
+	assert.Contains(t, out.String(), "8: .v, REPLACED WITH v, TYPE string\n")
+
+	dump, err := os.ReadFile(f.PGDump)
+	require.NoError(t, err)
+	assert.Contains(t, string(dump), `"status" INT8,`)
+	assert.Contains(t, string(dump), `"ok" BOOL,`)
+	assert.Contains(t, string(dump), `"ts" TIMESTAMP,`)
+	assert.Contains(t, string(dump), `"id" UUID,`)
+	assert.Contains(t, string(dump), `"dur" FLOAT8,`)
+	assert.Contains(t, string(dump), `"zip" VARCHAR,`)
+
+	rows := readParquetRows(t, f.Parquet)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "200", rows[0]["status"])
+	assert.Equal(t, "1714557600000000", rows[0]["ts"])
+	assert.Equal(t, "1714557600123000", rows[0]["at"])
+	assert.Equal(t, "\x0b\x6e\x8d\x1a\x3c\x4f\x4a\x5e\x9f\x7b\x2d\x1c\x0e\x9a\x8b\x7c", rows[0]["id"])
+	assert.Equal(t, "10", rows[2]["dur"])
+
+	t.Run("schema", func(t *testing.T) {
+		f := f
+		f.LoadSchema = f.SaveSchema
+		f.SaveSchema = ""
+		f.PGDump = ""
+		f.Parquet = ""
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+
+		out := bytes.NewBuffer(nil)
+		proc.Stdout = out
+
+		require.NoError(t, proc.Process())
+
+		assert.Contains(t, out.String(), "4: .at, REPLACED WITH at, TYPE timestamp, INFERRED\n")
+		assert.Contains(t, out.String(), "5: .id, REPLACED WITH id, TYPE uuid, INFERRED\n")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		f.InferTypes = false
+		f.SaveSchema = ""
+		f.PGDump = ""
+		f.Parquet = ""
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+
+		out := bytes.NewBuffer(nil)
+		proc.Stdout = out
+
+		require.NoError(t, proc.Process())
+
+		assert.Contains(t, out.String(), "1: .status, REPLACED WITH status, TYPE string\n")
+		assert.Contains(t, out.String(), "4: .at, REPLACED WITH at, TYPE string\n")
+	})
+
+	t.Run("epoch-like id", func(t *testing.T) {
+		fn := filepath.Join(dir, "ids.log")
+
+		require.NoError(t, os.WriteFile(fn, []byte(`{"id":"1234567890"}
+{"id":"1234567891"}
+`), 0o600))
+
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.CSV = filepath.Join(dir, "ids.csv")
+		f.InferTypes = true
+		f.ShowKeysInfo = true
+		f.Concurrency = 1
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+
+		out := bytes.NewBuffer(nil)
+		proc.Stdout = out
+
+		require.NoError(t, proc.Process())
+
+		assert.Contains(t, out.String(), "1: .id, TYPE int, INFERRED\n")
+		assertFileEquals(t, f.CSV, `.id
+1234567890
+1234567891
+`)
+	})
+
+	t.Run("mismatch after scanned lines", func(t *testing.T) {
+		fn := filepath.Join(dir, "partial.log")
+
+		require.NoError(t, os.WriteFile(fn, []byte(`{"code":"200"}
+{"code":"n/a"}
+`), 0o600))
+
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.CSV = filepath.Join(dir, "partial.csv")
+		f.PGDump = filepath.Join(dir, "partial.sql")
+		f.Parquet = filepath.Join(dir, "partial.parquet")
+		f.SQLTable = "out"
+		f.SQLMaxCols = 100
+		f.InferTypes = true
+		f.MaxLinesKeys = 1
+		f.Concurrency = 1
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		// Text output keeps value as is, typed outputs have null.
+		assertFileEquals(t, f.CSV, `.code
+200
+n/a
+`)
+
+		dump, err := os.ReadFile(f.PGDump)
+		require.NoError(t, err)
+		assert.Contains(t, string(dump), `".code" INT8`)
+		assert.Contains(t, string(dump), "\n1,200\n2,\n")
+
+		assert.Equal(t, []map[string]string{{".code": "200"}, {}}, readParquetRows(t, f.Parquet))
+
+		f.PGDump = ""
+		f.Parquet = ""
+
+		proc, err = flatjsonl.NewProcessor(f, flatjsonl.Config{ColumnTypesOnError: "NULL"}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		assertFileEquals(t, f.CSV, `.code
+200
+
+`)
+	})
+}
+
+func TestNewProcessor_rejects(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{"a":1}
+{"a":2
+plain text	line
+{"a":3}
+`), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.Rejects = filepath.Join(dir, "rejects.tsv")
+	f.Concurrency = 1
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.Rejects, fn+"\t2\tmalformed JSON: cannot parse JSON: cannot parse object: "+
+		"unexpected end of object; unparsed tail: \"\"\t{\"a\":2\n"+
+		fn+"\t3\tno JSON found\tplain text\tline\n")
+
+	f.MaxErrorRate = 0.4
+
+	proc, err = flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.EqualError(t, proc.Process(), "error rate exceeded: 2 of 4 lines rejected, max rate 0.4")
+
+	f.MaxErrorRate = 0.5
+
+	proc, err = flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+}
+
+func TestNewProcessor_longLines(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{"a":1}
+{"a":"0123456789012345678901234567890123456789"}
+{"a":3}
+`), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = fn
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.Rejects = filepath.Join(dir, "rejects.tsv")
+	f.BufSize = 20
+	f.Concurrency = 1
+
+	proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.EqualError(t, proc.Process(), "failed to read: bufio.Scanner: token too long: "+
+		"line is longer than 20 bytes, use -long-lines or larger -buf-size")
+
+	f.LongLines = flatjsonl.LongLinesSkip
+
+	proc, err = flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, ".a\n1\n3\n")
+	assertFileEquals(t, f.Rejects, fn+"\t2\tline too long: 48 bytes\t{\"a\":\"01234567890123\n")
+
+	f.LongLines = flatjsonl.LongLinesGrow
+	f.BufSizeMax = 100
+
+	proc, err = flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, ".a\n1\n0123456789012345678901234567890123456789\n3\n")
+	assertFileEquals(t, f.Rejects, "")
+
+	_, err = flatjsonl.NewProcessor(flatjsonl.Flags{LongLines: "wrap"}, flatjsonl.Config{})
+	require.EqualError(t, err, `unexpected long lines mode "wrap", fail, skip, truncate or grow expected`)
+}
+
+func TestNewProcessor_lineage(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("plain and gzip", func(t *testing.T) {
+		data := "{\"a\":1,\"tags\":[\"x\",\"y\"]}\r\n{\"a\":2}\n\n{\"a\":3,\"tags\":[\"z\"]}"
+
+		fn := filepath.Join(dir, "app.log")
+		require.NoError(t, os.WriteFile(fn, []byte(data), 0o600))
+
+		gz := bytes.NewBuffer(nil)
+		w := gzip.NewWriter(gz)
+		_, err := w.Write([]byte(data))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.NoError(t, os.WriteFile(fn+".gz", gz.Bytes(), 0o600))
+
+		for _, in := range []string{fn, fn + ".gz"} {
+			f := flatjsonl.Flags{}
+			f.Input = in
+			f.CSV = filepath.Join(dir, "out.csv")
+			f.AddFile = true
+			f.AddLine = true
+			f.AddOffset = true
+			f.Concurrency = 1
+
+			proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{Transpose: map[string]string{".tags": "tags"}}, flagInputs(t, f)...)
+			require.NoError(t, err)
+			require.NoError(t, proc.Process())
+
+			assertFileEquals(t, f.CSV, `._file,._line,._offset,.a
+`+in+`,1,0,1
+`+in+`,2,26,2
+`+in+`,3,34,
+`+in+`,4,35,3
+`)
+
+			assertFileEquals(t, filepath.Join(dir, "out_tags.csv"), `._sequence,._index,._file,._line,._offset,._value
+1,0,`+in+`,1,0,x
+1,1,`+in+`,1,0,y
+4,0,`+in+`,4,35,z
+`)
+		}
+	})
+
+	t.Run("split ranges", func(t *testing.T) {
+		fn := filepath.Join(dir, "large.log")
+
+		var (
+			data     bytes.Buffer
+			expected strings.Builder
+		)
+
+		expected.WriteString("._line,._offset,.id\n")
+
+		for i := 1; i <= 20000; i++ {
+			expected.WriteString(fmt.Sprintf("%d,%d,%d\n", i, data.Len(), i))
+			data.WriteString(fmt.Sprintf(`{"id":%d}`+"\n", i))
+		}
+
+		require.NoError(t, os.WriteFile(fn, data.Bytes(), 0o600))
+
+		f := flatjsonl.Flags{}
+		f.Input = fn
+		f.CSV = filepath.Join(dir, "large.csv")
+		f.AddLine = true
+		f.AddOffset = true
+		f.SplitRanges = 4
+		f.Concurrency = 2
+
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+
+		assertFileEquals(t, f.CSV, expected.String())
+	})
+}
+
+func TestNewProcessor_checkpoint(t *testing.T) {
+	dir := t.TempDir()
+
+	var a, b strings.Builder
+
+	for i := 1; i <= 10; i++ {
+		w := &a
+		if i > 5 {
+			w = &b
+		}
+
+		_, _ = fmt.Fprintf(w, `{"id":%d,"tags":["t%d"]}`+"\n", i, i)
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.jsonl"), []byte(a.String()), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.jsonl"), []byte(b.String()), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = filepath.Join(dir, "a.jsonl") + "," + filepath.Join(dir, "b.jsonl")
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.SQLite = filepath.Join(dir, "out.sqlite")
+	f.SQLTable = "out"
+	f.SQLMaxCols = 100
+	f.AddSequence = true
+	f.Concurrency = 1
+	f.Checkpoint = filepath.Join(dir, "checkpoint.json")
+
+	cfg := flatjsonl.Config{Transpose: map[string]string{".tags": "tags"}}
+
+	process := func() error {
+		proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+		require.NoError(t, err)
+
+		return proc.Process()
+	}
+
+	require.NoError(t, process())
+
+	out, err := os.ReadFile(f.CSV)
+	require.NoError(t, err)
+
+	tags, err := os.ReadFile(filepath.Join(dir, "out_tags.csv"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "._sequence,.id\n1,1\n2,2\n3,3\n4,4\n5,5\n6,6\n7,7\n8,8\n9,9\n10,10\n", string(out))
+
+	var cp map[string]any
+
+	b2, err := os.ReadFile(f.Checkpoint)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b2, &cp))
+
+	assert.Equal(t, true, cp["completed"])
+	assert.Equal(t, 10.0, cp["sequence"])
+
+	// Simulate interruption after 7 lines with uncommitted tail of output.
+	committedSize := func(data []byte, rows int) float64 {
+		lines := strings.SplitAfter(string(data), "\n")
+
+		return float64(len(strings.Join(lines[:rows+1], "")))
+	}
+
+	delete(cp, "completed")
+	cp["sequence"] = 7
+	cp["lastRow"] = 7
+	cp["outputs"] = map[string]any{
+		f.CSV:                              committedSize(out, 7),
+		filepath.Join(dir, "out_tags.csv"): committedSize(tags, 7),
+	}
+
+	inputs := cp["inputs"].([]any)       //nolint:errcheck
+	second := inputs[1].(map[string]any) //nolint:errcheck
+	second["committed"] = 2
+	delete(second, "read")
+
+	b2, err = json.Marshal(cp)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(f.Checkpoint, b2, 0o600))
+
+	for _, fn := range []string{f.CSV, filepath.Join(dir, "out_tags.csv")} {
+		fa, err := os.OpenFile(fn, os.O_APPEND|os.O_WRONLY, 0)
+		require.NoError(t, err)
+
+		_, err = fa.WriteString("8,partial")
+		require.NoError(t, err)
+		require.NoError(t, fa.Close())
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		f.Resume = true
+
+		require.NoError(t, process())
+
+		assertFileEquals(t, f.CSV, string(out))
+		assertFileEquals(t, filepath.Join(dir, "out_tags.csv"), string(tags))
+
+		db, err := sql.Open("sqlite", f.SQLite)
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, db.Close())
+		}()
+
+		var cnt, maxSeq int
+
+		require.NoError(t, db.QueryRow(`SELECT count(*), max(_seq_id) FROM out`).Scan(&cnt, &maxSeq))
+		assert.Equal(t, 10, cnt)
+		assert.Equal(t, 10, maxSeq)
+
+		require.NoError(t, db.QueryRow(`SELECT count(*) FROM out_tags`).Scan(&cnt))
+		assert.Equal(t, 10, cnt)
+	})
+
+	t.Run("completed", func(t *testing.T) {
+		f.Resume = true
+
+		require.NoError(t, process())
+		assertFileEquals(t, f.CSV, string(out))
+	})
+
+	t.Run("changed input", func(t *testing.T) {
+		f.Resume = true
+
+		cp["completed"] = false
+		b2, err = json.Marshal(cp)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(f.Checkpoint, b2, 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "b.jsonl"), []byte(b.String()+"{}\n"), 0o600))
+
+		assert.EqualError(t, process(), "input "+filepath.Join(dir, "b.jsonl")+" has changed since checkpoint")
+	})
+
+	t.Run("compressed output", func(t *testing.T) {
+		f.CSV = filepath.Join(dir, "out.csv.gz")
+
+		_, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+		assert.EqualError(t, err, "checkpoint is not supported for compressed output "+f.CSV)
+	})
+}
+
+func TestNewProcessor_state(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(log, []byte(`{"id":1,"tags":["t1"]}`+"\n"+`{"id":2,"tags":["t2"]}`+"\n"), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = log
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.SQLite = filepath.Join(dir, "out.sqlite")
+	f.SQLTable = "out"
+	f.SQLMaxCols = 100
+	f.AddSequence = true
+	f.AddLine = true
+	f.Concurrency = 1
+	f.State = filepath.Join(dir, "state.json")
+	f.Append = true
+
+	cfg := flatjsonl.Config{Transpose: map[string]string{".tags": "tags"}}
+
+	process := func() {
+		proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+	}
+
+	count := func() (cnt, maxSeq int) {
+		db, err := sql.Open("sqlite", f.SQLite)
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, db.Close())
+		}()
+
+		require.NoError(t, db.QueryRow(`SELECT count(*), max(_seq_id) FROM out`).Scan(&cnt, &maxSeq))
+
+		return cnt, maxSeq
+	}
+
+	appendLog := func(s string) {
+		fa, err := os.OpenFile(log, os.O_APPEND|os.O_WRONLY, 0)
+		require.NoError(t, err)
+
+		_, err = fa.WriteString(s)
+		require.NoError(t, err)
+		require.NoError(t, fa.Close())
+	}
+
+	process()
+
+	assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n")
+
+	// New line and incomplete line that is still being written, new key is ignored as schema is reused.
+	appendLog(`{"id":3,"new":1,"tags":["t3"]}` + "\n" + `{"id":4,`)
+
+	process()
+
+	assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n3,3,3\n")
+	assertFileEquals(t, filepath.Join(dir, "out_tags.csv"),
+		"._sequence,._index,._line,._value\n1,0,1,t1\n2,0,2,t2\n3,0,3,t3\n")
+
+	cnt, maxSeq := count()
+	assert.Equal(t, 3, cnt)
+	assert.Equal(t, 3, maxSeq)
+
+	t.Run("completed line", func(t *testing.T) {
+		appendLog(`"tags":["t4"]}` + "\n")
+
+		process()
+
+		assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n3,3,3\n4,4,4\n")
+
+		cnt, maxSeq := count()
+		assert.Equal(t, 4, cnt)
+		assert.Equal(t, 4, maxSeq)
+	})
+
+	t.Run("no new data", func(t *testing.T) {
+		process()
+
+		assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n3,3,3\n4,4,4\n")
+	})
+
+	t.Run("last line without new line", func(t *testing.T) {
+		appendLog(`{"id":5}`)
+
+		process()
+
+		assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n3,3,3\n4,4,4\n")
+
+		// File is not changed since previous run, so the last line is complete.
+		process()
+
+		assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n3,3,3\n4,4,4\n5,5,5\n")
+
+		// New line that terminates ingested line does not make an empty row.
+		appendLog("\n" + `{"id":6}` + "\n")
+
+		process()
+
+		assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n3,3,3\n4,4,4\n5,5,5\n6,6,6\n")
+	})
+
+	t.Run("truncated input", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(log, []byte(`{"id":7}`+"\n"), 0o600))
+
+		process()
+
+		assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n3,3,3\n4,4,4\n5,5,5\n6,6,6\n7,1,7\n")
+	})
+
+	t.Run("append without state", func(t *testing.T) {
+		f.State = ""
+
+		_, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+		assert.EqualError(t, err, "append requires -state to continue row numbers")
+	})
+}
+
+func TestNewProcessor_stateCompressed(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "app.log.gz")
+
+	// Compressed log grows with new gzip members.
+	appendLog := func(s string) {
+		fa, err := os.OpenFile(log, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
+		require.NoError(t, err)
+
+		gw := gzip.NewWriter(fa)
+		_, err = gw.Write([]byte(s))
+		require.NoError(t, err)
+		require.NoError(t, gw.Close())
+		require.NoError(t, fa.Close())
+	}
+
+	f := flatjsonl.Flags{}
+	f.Input = log
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.AddSequence = true
+	f.AddLine = true
+	f.Concurrency = 1
+	f.State = filepath.Join(dir, "state.json")
+	f.Append = true
+
+	process := func() {
+		proc, err := flatjsonl.NewProcessor(f, flatjsonl.Config{}, flagInputs(t, f)...)
+		require.NoError(t, err)
+		require.NoError(t, proc.Process())
+	}
+
+	appendLog(`{"id":1}` + "\n" + `{"id":2}` + "\n")
+	process()
+
+	assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n")
+
+	process()
+
+	assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n")
+
+	// Grown file is read again, but ingested lines are skipped.
+	appendLog(`{"id":3}` + "\n")
+	process()
+
+	assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n3,3,3\n")
+
+	appendLog(`{"id":4}` + "\n")
+	process()
+
+	assertFileEquals(t, f.CSV, "._sequence,._line,.id\n1,1,1\n2,2,2\n3,3,3\n4,4,4\n")
+}
+
+func TestNewProcessor_explode(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "orders.jsonl")
+
+	many := make([]string, 12)
+	for i := range many {
+		many[i] = fmt.Sprintf(`{"sku":"m%d"}`, i)
+	}
+
+	require.NoError(t, os.WriteFile(input, []byte(
+		`{"id":1,"items":[{"sku":"x","qty":2},{"sku":"y","qty":1.5}],"tags":["t1","t2"]}`+"\n"+
+			`{"id":2,"items":[]}`+"\n"+
+			`{"id":3,"items":["s",{"sku":"z","qty":3}],"tags":["t3"]}`+"\n"+
+			`{"id":4,"items":[`+strings.Join(many, ",")+`]}`+"\n",
+	), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = input
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.SQLite = filepath.Join(dir, "out.sqlite")
+	f.SQLTable = "out"
+	f.SQLMaxCols = 100
+	f.Parquet = filepath.Join(dir, "out.parquet")
+	f.AddSequence = true
+	f.ChildrenLimitArray = 10
+	f.ChildrenLimitObject = 100
+	f.Concurrency = 1
+
+	cfg := flatjsonl.Config{
+		Explode:   ".items",
+		Transpose: map[string]string{".tags": "tags"},
+	}
+
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	expected := "._sequence,.id,.items._index,.items,.items.sku,.items.qty\n" +
+		"1,1,0,,x,2\n" +
+		"2,1,1,,y,1.5\n" +
+		"3,2,,,,\n" +
+		"4,3,0,s,,\n" +
+		"5,3,1,,z,3\n"
+
+	for i := range many {
+		expected += fmt.Sprintf("%d,4,%d,,m%d,\n", i+6, i, i)
+	}
+
+	assertFileEquals(t, f.CSV, expected)
+
+	// Transposed rows are not repeated for exploded rows and refer to the first row of line.
+	assertFileEquals(t, filepath.Join(dir, "out_tags.csv"), "._sequence,._index,._value\n1,0,t1\n1,1,t2\n4,0,t3\n")
+
+	db, err := sql.Open("sqlite", f.SQLite)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	var cnt, maxSeq, qty int
+
+	require.NoError(t, db.QueryRow(`SELECT count(*), max(_seq_id) FROM out`).Scan(&cnt, &maxSeq))
+	assert.Equal(t, 17, cnt)
+	assert.Equal(t, 17, maxSeq)
+
+	require.NoError(t, db.QueryRow(`SELECT "._sequence" FROM out WHERE ".items.sku" = 'z'`).Scan(&qty))
+	assert.Equal(t, 5, qty)
+
+	rows := readParquetRows(t, f.Parquet)
+	require.Len(t, rows, 17)
+	assert.Equal(t, "1", rows[1][".items._index"])
+	assert.Equal(t, "y", rows[1][".items.sku"])
+	assert.Equal(t, "1.5", rows[1][".items.qty"])
+	assert.Equal(t, "m11", rows[16][".items.sku"])
+}
+
+func TestNewProcessor_arrays(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "events.jsonl")
+
+	require.NoError(t, os.WriteFile(input, []byte(
+		`{"id":1,"tags":["a","b,c","d|\"e\""],"nums":[1,2,3],"ev":[{"t":"x"},{"t":"y"}]}`+"\n"+
+			`{"id":2,"tags":[],"nums":[1.5,null],"ev":[]}`+"\n"+
+			`{"id":3,"nums":[4]}`+"\n",
+	), 0o600))
+
+	f := flatjsonl.Flags{}
+	f.Input = input
+	f.CSV = filepath.Join(dir, "out.csv")
+	f.Parquet = filepath.Join(dir, "out.parquet")
+	f.PGDump = filepath.Join(dir, "out.sql")
+	f.SQLTable = "out"
+	f.SQLMaxCols = 100
+	f.Concurrency = 1
+
+	cfg := flatjsonl.Config{
+		Arrays: map[string]string{
+			".tags": "JOIN,LEN",
+			".nums": "LIST,FIRST,LAST",
+			".ev":   "len,first,last",
+		},
+		ArrayDelimiter: "|",
+	}
+
+	proc, err := flatjsonl.NewProcessor(f, cfg, flagInputs(t, f)...)
+	require.NoError(t, err)
+	require.NoError(t, proc.Process())
+
+	assertFileEquals(t, f.CSV, `.id,.tags,.tags._len,.nums,.nums._first,.nums._last,.ev._len,.ev._first.t,.ev._last.t
+1,"a|b,c|""d|""""e""""""",3,"[1,2,3]",1,3,2,x,y
+2,,0,"[1.5,null]",1.5,,0,,
+3,,,[4],4,4,,,
+`)
+
+	dump, err := os.ReadFile(f.PGDump)
+	require.NoError(t, err)
+	assert.Contains(t, string(dump), `".nums" FLOAT8[],`)
+	assert.Contains(t, string(dump), `2,2,,0,"{1.5,NULL}",1.5,,0,,`)
+
+	r := parquet.NewReader(openParquetFile(t, f.Parquet))
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	numsCol := -1
+
+	for i, c := range r.Schema().Columns() {
+		if c[0] == ".nums" {
+			numsCol = i
+		}
+	}
+
+	require.NotEqual(t, -1, numsCol)
+
+	var nums []string
+
+	buf := make([]parquet.Row, 1)
+
+	for {
+		n, err := r.ReadRows(buf)
+		if n > 0 {
+			var elements []string
+
+			buf[0].Range(func(columnIndex int, values []parquet.Value) bool {
+				if columnIndex == numsCol {
+					for _, v := range values {
+						elements = append(elements, parquetValueString(v))
+					}
+				}
+
+				return true
+			})
+
+			nums = append(nums, strings.Join(elements, ","))
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"1,2,3", "1.5,", "4"}, nums)
+
+	t.Run("invalid strategy", func(t *testing.T) {
+		_, err := flatjsonl.NewProcessor(f, flatjsonl.Config{Arrays: map[string]string{".tags": "JOIN,LIST"}})
+		assert.EqualError(t, err, "arrays: array strategies JOIN and LIST can not be used together for .tags")
+	})
+}
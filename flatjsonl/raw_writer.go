@@ -14,19 +14,26 @@ type RawWriter struct {
 
 	transposed map[string]*RawWriter
 
+	// om defines how existing files are continued.
+	om outputMode
+
 	*fileWriter
 	b *baseWriter
 }
 
 // NewRawWriter creates an instance of RawWriter.
 func NewRawWriter(fn string, delimiter string) (*RawWriter, error) {
+	return newRawWriter(fn, delimiter, outputMode{})
+}
+
+func newRawWriter(fn string, delimiter string, om outputMode) (*RawWriter, error) {
 	var err error
 
-	c := &RawWriter{fn: fn}
+	c := &RawWriter{fn: fn, om: om}
 
 	c.delim = []byte(delimiter)
 
-	c.fileWriter, err = newFileWriter(fn)
+	c.fileWriter, err = outputFileWriter(fn, om)
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +58,7 @@ func (c *RawWriter) SetupKeys(keys []flKey) (err error) {
 			fn = c.fn
 		}
 
-		ctw, err := NewRawWriter(fn, string(c.delim))
+		ctw, err := newRawWriter(fn, string(c.delim), c.om)
 		if err != nil {
 			return fmt.Errorf("failed to init transposed RAW writer for %s: %w", dst, err)
 		}
@@ -114,6 +121,42 @@ func (c *RawWriter) writeRow(row []string) error {
 	return nil
 }
 
+// Flush writes buffered rows to file.
+func (c *RawWriter) Flush() error {
+	if err := c.w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush RAW: %w", err)
+	}
+
+	if err := c.flush(); err != nil {
+		return fmt.Errorf("failed to flush RAW file: %w", err)
+	}
+
+	for _, tw := range c.transposed {
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *RawWriter) positions(pos map[string]int64) error {
+	p, err := filePosition(c.fn, c.f)
+	if err != nil {
+		return err
+	}
+
+	pos[c.fn] = p
+
+	for _, tw := range c.transposed {
+		if err := tw.positions(pos); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Close flushes rows and closes file.
 func (c *RawWriter) Close() error {
 	if err := c.w.Flush(); err != nil {
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"regexp"
 	"runtime"
@@ -18,14 +19,15 @@ import (
 
 	"github.com/bool64/ctxd"
 	"github.com/bool64/progress"
-	"github.com/klauspost/compress/zstd"
-	gzip "github.com/klauspost/pgzip"
 	"github.com/vearutop/fastjson"
 )
 
 const errEmptyFile = ctxd.SentinelError("empty file")
 
-// Input can be either a file name or a reader.
+// Input can be either a file name or a reader, file name of a reader is used as a label.
+//
+// Compression of input is detected by magic bytes, unless reader explicitly declares
+// one of gzip, zst, bzip2, xz, lz4.
 type Input struct {
 	FileName string
 	Reader   interface {
@@ -34,6 +36,9 @@ type Input struct {
 		Reset()
 		Compression() string
 	}
+
+	// skipLines is a number of first lines that were ingested in previous run.
+	skipLines int64
 }
 
 // Reader scans lines and decodes JSON in them.
@@ -52,6 +57,44 @@ type Reader struct {
 	MatchPrefix    *regexp.Regexp
 	ExtractStrings bool
 
+	// MatchPrefixes are alternative prefix patterns, they are tried in order if MatchPrefix does not match.
+	MatchPrefixes []*regexp.Regexp
+
+	// AddFile enables ._file column with input file name or archive member name.
+	AddFile bool
+
+	// AddLine enables ._line column with line number in file or archive member.
+	AddLine bool
+
+	// AddOffset enables ._offset column with byte offset of line in uncompressed file or archive member.
+	AddOffset bool
+
+	// ArchiveMembers is a list of glob patterns to filter tar or zip archive members.
+	ArchiveMembers []string
+
+	// InputMode defines how records are delimited: lines (default), stream or array.
+	InputMode string
+	// ArrayPath is a path of object keys to array with records in array input mode.
+	ArrayPath []string
+
+	// Format defines line format: json (default) or logfmt.
+	Format string
+	// LogfmtDetectTypes enables detection of numbers and booleans in unquoted logfmt values.
+	LogfmtDetectTypes bool
+
+	// LongLines defines handling of lines longer than buffer: fail (default), skip, truncate or grow up to BufMax.
+	LongLines string
+	BufMax    int
+
+	// sample selects lines to process, nil to process all lines.
+	sample *sampler
+
+	// rejects receives lines that could not be processed, nil to only count them.
+	rejects *rejectsWriter
+
+	// resumeSeq is the last line that was written before checkpoint, lines up to it are skipped.
+	resumeSeq int64
+
 	singleKeyFlat []byte
 	singleKeyPath []string
 
@@ -60,17 +103,65 @@ type Reader struct {
 
 type readSession struct {
 	pr      *progress.Progress
-	scanner *bufio.Scanner
+	scanner lineScanner
 	fj      *os.File
+	dr      io.Reader
 	r       io.Reader
 
+	// fileName is a name of input file or archive member that is being read.
+	fileName string
+	// fileLine is a number of the last scanned line of file or archive member.
+	fileLine int64
+	// lineBase and offsetBase are the number of lines and bytes in file before the range that is read.
+	lineBase   int64
+	offsetBase int64
+
+	// skipLines is a number of first lines to skip, scanned is a number of scanned lines including skipped.
+	skipLines int64
+	scanned   int64
+
+	// nextMember switches scanner to the next archive member, it returns io.EOF when there are no more members.
+	// It is nil for regular inputs.
+	nextMember func() error
+	archive    *archive
+
 	setupWalker  func(w *FastWalker)
 	lineStarted  func(seq int64) error
 	lineFinished func(seq int64) error
+	lineSkipped  func(seq int64) error
+	// lineWalked is called after values of line are walked, line is rejected if it returns an error.
+	lineWalked func(seq int64) error
+
+	// buf is a scanner buffer, it is not shared with other sessions of a group.
+	buf []byte
+
+	// group aggregates progress of concurrently read inputs, it is nil for sequential reading.
+	group *inputGroup
+
+	// sequence is a counter of sequence numbers, it can be shared by sessions.
+	// lines is a number of sequence numbers taken by session, seqLimit is the last expected sequence number (0 for unlimited).
+	sequence *int64
+	lines    int64
+	seqLimit int64
+
+	// window limits lines that are read ahead of written rows in ordered output, it is nil for unordered output.
+	window *readWindow
+
+	// spool decodes compressed input in background, it is set for ordered inputs that are read concurrently.
+	spool *spool
 }
 
 func (rs *readSession) Close() {
-	rs.pr.Stop()
+	if rs.group == nil {
+		rs.pr.Stop()
+	}
+
+	// Spool reads from file and decompressor, so it is closed first.
+	if rs.spool != nil {
+		if err := rs.spool.Close(); err != nil {
+			println("failed to close spool:", err.Error())
+		}
+	}
 
 	if rs.fj != nil {
 		if err := rs.fj.Close(); err != nil {
@@ -78,15 +169,32 @@ func (rs *readSession) Close() {
 		}
 	}
 
-	if c, ok := rs.r.(io.Closer); ok {
+	switch c := rs.dr.(type) {
+	case io.Closer:
 		if err := c.Close(); err != nil {
 			println("failed to close reader:", err.Error())
 		}
+	case interface{ Close() }:
+		c.Close()
+	}
+
+	if rs.archive != nil {
+		if err := rs.archive.closeMember(); err != nil {
+			println("failed to close archive member:", err.Error())
+		}
 	}
 }
 
-func (rd *Reader) session(in Input, task string) (sess *readSession, err error) {
-	sess = &readSession{}
+func (rd *Reader) session(in Input, task string, g *inputGroup) (sess *readSession, err error) {
+	sess = &readSession{
+		group:    g,
+		buf:      rd.Buf,
+		sequence: new(int64),
+	}
+
+	if g != nil && len(rd.Buf) > 0 {
+		sess.buf = make([]byte, len(rd.Buf))
+	}
 
 	sess.pr = rd.Progress
 	if sess.pr == nil {
@@ -98,10 +206,12 @@ func (rd *Reader) session(in Input, task string) (sess *readSession, err error)
 		cmp string
 	)
 
-	if in.FileName != "" {
-		fj, err := os.Open(in.FileName)
+	if in.Reader == nil {
+		var fj *os.File
+
+		fj, err = os.Open(in.FileName)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open file %s: %w", in, err)
+			return nil, fmt.Errorf("failed to open file %s: %w", in.FileName, err)
 		}
 
 		defer func() {
@@ -113,63 +223,163 @@ func (rd *Reader) session(in Input, task string) (sess *readSession, err error)
 		}()
 
 		r = fj
-
-		switch {
-		case strings.HasSuffix(in.FileName, ".gz"):
-			cmp = "gzip"
-		case strings.HasSuffix(in.FileName, ".zst"):
-			cmp = "zst"
-		}
+		sess.fj = fj
 	} else {
 		r = in.Reader
 		in.Reader.Reset()
 		cmp = in.Reader.Compression()
+
+		if rr, ok := in.Reader.(*RangeReader); ok {
+			sess.offsetBase = rr.start
+		}
 	}
 
 	cr := progress.NewCountingReader(r)
-	lines := cr
-	sess.r = cr
+	br := bufio.NewReaderSize(cr, 64*1024)
+	currentBytes := cr.Bytes
+	currentLines := cr.Lines
 
-	switch cmp {
-	case "gzip":
-		cr.SetLines(nil)
+	if cmp == "" {
+		cmp = sniffCompression(br)
+	}
 
-		if r, err = gzip.NewReader(sess.r); err != nil {
-			return nil, fmt.Errorf("failed to init gzip reader: %w", err)
+	var zipLines *int64
+
+	switch {
+	case cmp == CompressionNone:
+	case cmp == "" && isZip(br):
+		ar, ra, err := rd.zipArchive(in, sess.fj)
+		if err != nil {
+			return nil, err
 		}
 
-		lines = progress.NewCountingReader(r)
-		sess.r = lines
-	case "zst":
+		// Zip members are read with random access, so counters of stream are not used.
+		zipLines = new(int64)
+		sess.archive = ar
+		currentBytes = ra.Bytes
+		currentLines = func() int64 { return atomic.LoadInt64(zipLines) }
+		task += " [zip]"
+	case cmp != "":
 		cr.SetLines(nil)
 
-		if r, err = zstd.NewReader(sess.r); err != nil {
-			return nil, fmt.Errorf("failed to init zstd reader: %w", err)
+		if r, err = decompress(br, cmp); err != nil {
+			return nil, err
 		}
 
-		lines = progress.NewCountingReader(r)
-		sess.r = lines
-	}
+		sess.dr = r
+		lines := progress.NewCountingReader(r)
+		currentLines = lines.Lines
+		r = lines
+		task += " [" + cmp + "]"
 
-	sess.pr.Start(func(t *progress.Task) {
-		t.Task = task
-		t.TotalBytes = func() int64 {
-			return rd.totalBytes
+		if g.spooled() {
+			sess.spool = newSpool(lines, rd.Processor.f.SpillDir, rd.Processor.f.SpillZstd).start()
+			r = sess.spool
 		}
-		t.CurrentBytes = cr.Bytes
-		t.CurrentLines = lines.Lines
-		t.Continue = true
-	})
 
-	sess.scanner = bufio.NewScanner(sess.r)
+		br = bufio.NewReaderSize(r, 64*1024)
+	}
+
+	sess.r = br
 
-	if len(rd.Buf) != 0 {
-		sess.scanner.Buffer(rd.Buf, len(rd.Buf))
+	if sess.archive == nil && cmp != CompressionNone && isTar(br) {
+		sess.archive = newTarArchive(in.FileName, br, rd.ArchiveMembers)
+		task += " [tar]"
 	}
 
+	if g != nil {
+		g.add(currentBytes, currentLines)
+	} else {
+		sess.pr.Start(func(t *progress.Task) {
+			t.Task = task
+			t.TotalBytes = func() int64 {
+				// Size of streamed input may be unknown.
+				if c := currentBytes(); c > rd.totalBytes {
+					return c
+				}
+
+				return rd.totalBytes
+			}
+			t.CurrentBytes = currentBytes
+			t.CurrentLines = currentLines
+			t.Continue = true
+		})
+	}
+
+	if sess.archive != nil {
+		sess.nextMember = func() error {
+			name, r, err := sess.archive.next()
+			if err != nil {
+				return err
+			}
+
+			if zipLines != nil {
+				lr := progress.NewCountingReader(r)
+				lr.SetLines(zipLines)
+				r = lr
+			}
+
+			sess.fileName = name
+			sess.fileLine = 0
+			sess.scanner = rd.newScanner(r, sess.buf)
+
+			return nil
+		}
+
+		return sess, nil
+	}
+
+	sess.fileName = in.FileName
+	sess.scanner = rd.newScanner(sess.r, sess.buf)
+
 	return sess, nil
 }
 
+func (rd *Reader) newScanner(r io.Reader, buf []byte) lineScanner {
+	if rd.InputMode == InputModeStream || rd.InputMode == InputModeArray {
+		return newJSONScanner(r, rd.InputMode, rd.ArrayPath, len(buf))
+	}
+
+	scanner := newLongLineScanner(r, buf, rd.LongLines, rd.BufMax)
+
+	if rd.Format == FormatCRI {
+		return newCRIScanner(scanner, len(buf))
+	}
+
+	return scanner
+}
+
+func (rd *Reader) zipArchive(in Input, fj *os.File) (*archive, *countingReaderAt, error) {
+	var (
+		ra   io.ReaderAt
+		size int64
+	)
+
+	if fj != nil {
+		fi, err := fj.Stat()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ra = fj
+		size = fi.Size()
+	} else {
+		r, ok := in.Reader.(io.ReaderAt)
+		if !ok {
+			return nil, nil, errors.New("zip archive requires random access input")
+		}
+
+		ra = r
+		size = in.Reader.Size()
+	}
+
+	cra := &countingReaderAt{r: ra}
+
+	ar, err := newZipArchive(in.FileName, cra, size, rd.ArchiveMembers)
+
+	return ar, cra, err
+}
+
 type syncWorker struct {
 	i        int
 	p        *fastjson.Parser
@@ -178,6 +388,10 @@ type syncWorker struct {
 	flatPath []byte
 	walker   *FastWalker
 	line     []byte
+	payload  []byte
+	fileName string
+	lineNum  int64
+	offset   int64
 }
 
 // Read reads single file with JSON lines.
@@ -211,79 +425,134 @@ func (rd *Reader) Read(sess *readSession) error {
 		doLineErr error
 	)
 
-	if len(rd.Processor.includeKeys) == 1 && !rd.Processor.f.ExtractStrings {
-		for _, i := range rd.Processor.includeKeys {
-			kk := rd.Processor.keys[i]
-			path := make([]string, 0, len(kk.path))
+	var n int64
 
-			for _, s := range kk.path {
-				if s[0] == '[' && s[len(s)-1] == ']' {
-					s = s[1 : len(s)-1]
+members:
+	for {
+		if sess.nextMember != nil {
+			if err := sess.nextMember(); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
 				}
 
-				path = append(path, s)
-			}
-
-			rd.singleKeyPath = path
-			rd.singleKeyFlat = []byte("." + strings.Join(kk.path, "."))
-		}
-	}
+				atomic.AddInt64(&stop, 1)
 
-	var n int64
+				mu.Lock()
+				doLineErr = fmt.Errorf("failed to read archive member: %w", err)
+				mu.Unlock()
 
-	for sess.scanner.Scan() {
-		// Apply at most one throttle penalty per line. The memory watcher may keep
-		// reasserting throttle while heap stays above the limit, and looping until
-		// it clears can livelock the reader completely.
-		if atomic.SwapInt64(&rd.Processor.throttle, 0) != 0 {
-			runtime.GC()
-			time.Sleep(110 * time.Millisecond)
+				break
+			}
 		}
 
-		if err := sess.scanner.Err(); err != nil {
-			return fmt.Errorf("scan failed: %w", err)
-		}
+		for sess.scanner.Scan() {
+			// Apply at most one throttle penalty per line. The memory watcher may keep
+			// reasserting throttle while heap stays above the limit, and looping until
+			// it clears can livelock the reader completely.
+			if atomic.SwapInt64(&rd.Processor.throttle, 0) != 0 {
+				runtime.GC()
+				time.Sleep(110 * time.Millisecond)
+			}
 
-		line := sess.scanner.Bytes()
-		n := atomic.AddInt64(&n, 1)
+			if err := sess.scanner.Err(); err != nil {
+				return fmt.Errorf("scan failed: %w", err)
+			}
 
-		if rd.OffsetLines > 0 && n <= rd.OffsetLines {
-			continue
-		}
+			line := sess.scanner.Bytes()
+			n := atomic.AddInt64(&n, 1)
+			sess.fileLine++
 
-		seq := atomic.AddInt64(&rd.Sequence, 1)
+			if n <= sess.skipLines {
+				continue
+			}
 
-		worker := <-semaphore
-		worker.line = append(worker.line[:0], line...)
-		worker.used++
+			if rd.OffsetLines > 0 && n <= rd.OffsetLines {
+				continue
+			}
 
-		if worker.used >= 100 {
-			worker.used = 0
-			worker.p = &fastjson.Parser{AllowUnexpectedTail: true}
-		}
+			if ll, ok := sess.scanner.(longLiner); ok {
+				if size, skip := ll.longLine(); size > 0 {
+					atomic.AddInt64(&rd.Processor.longLines, 1)
 
-		atomic.AddInt64(&rd.Processor.inProgress, 1)
+					if skip {
+						atomic.AddInt64(&rd.Processor.linesRead, 1)
+						rd.rejectLine(sess.fileName, sess.lineBase+sess.fileLine, rejectLineTooLong, fmt.Errorf("%d bytes", size), line)
 
-		go func() {
-			defer func() {
-				atomic.AddInt64(&rd.Processor.inProgress, -1)
-				semaphore <- worker
-			}()
+						continue
+					}
+				}
+			}
 
-			if err := rd.doLine(worker, seq, n, sess); err != nil {
+			seq := atomic.AddInt64(sess.sequence, 1)
+			sess.lines++
+
+			if sess.seqLimit > 0 && seq > sess.seqLimit {
 				atomic.AddInt64(&stop, 1)
 
 				mu.Lock()
-				doLineErr = err
+				doLineErr = errors.New("input has more lines than during keys scan")
 				mu.Unlock()
+
+				break members
 			}
-		}()
 
-		if atomic.LoadInt64(&stop) != 0 {
-			break
+			if seq <= rd.resumeSeq {
+				// Line was committed to output before interruption.
+				if rd.MaxLines > 0 && rd.MaxLines+rd.OffsetLines <= n {
+					break members
+				}
+
+				continue
+			}
+
+			if !sess.window.wait(seq) {
+				break members
+			}
+
+			worker := <-semaphore
+			worker.line = append(worker.line[:0], line...)
+			worker.fileName = sess.fileName
+			worker.lineNum = sess.lineBase + sess.fileLine
+			worker.offset = sess.offsetBase
+
+			if o, ok := sess.scanner.(offsetter); ok {
+				worker.offset += o.lineOffset()
+			}
+
+			worker.used++
+
+			if worker.used >= 100 {
+				worker.used = 0
+				worker.p = &fastjson.Parser{AllowUnexpectedTail: true}
+			}
+
+			atomic.AddInt64(&rd.Processor.inProgress, 1)
+
+			go func() {
+				defer func() {
+					atomic.AddInt64(&rd.Processor.inProgress, -1)
+					semaphore <- worker
+				}()
+
+				if err := rd.doLine(worker, seq, n, sess); err != nil {
+					atomic.AddInt64(&stop, 1)
+
+					mu.Lock()
+					doLineErr = err
+					mu.Unlock()
+				}
+			}()
+
+			if atomic.LoadInt64(&stop) != 0 || sess.group.failed() {
+				break members
+			}
+
+			if rd.MaxLines > 0 && rd.MaxLines+rd.OffsetLines <= n {
+				break members
+			}
 		}
 
-		if rd.MaxLines > 0 && rd.MaxLines+rd.OffsetLines <= n {
+		if sess.nextMember == nil || sess.scanner.Err() != nil {
 			break
 		}
 	}
@@ -293,43 +562,216 @@ func (rd *Reader) Read(sess *readSession) error {
 		<-semaphore
 	}
 
+	sess.scanned = atomic.LoadInt64(&n)
+
 	if doLineErr != nil {
 		return doLineErr
 	}
 
+	if sess.scanner == nil {
+		return nil
+	}
+
 	return sess.scanner.Err()
 }
 
-func (rd *Reader) doLine(w *syncWorker, seq, n int64, sess *readSession) error {
+// prepareSingleKey enables fast path of a single included key.
+func (rd *Reader) prepareSingleKey() {
+	if len(rd.Processor.includeKeys) != 1 || rd.Processor.f.ExtractStrings || rd.Processor.f.SampleKey != "" ||
+		rd.Processor.where != nil || rd.Processor.computed != nil {
+		return
+	}
+
+	for _, i := range rd.Processor.includeKeys {
+		kk := rd.Processor.keys[i]
+		path := make([]string, 0, len(kk.path))
+
+		for _, s := range kk.path {
+			if s[0] == '[' && s[len(s)-1] == ']' {
+				s = s[1 : len(s)-1]
+			}
+
+			path = append(path, s)
+		}
+
+		rd.singleKeyPath = path
+		rd.singleKeyFlat = []byte("." + strings.Join(kk.path, "."))
+	}
+}
+
+// readWindow blocks reading of lines that are too far ahead of written rows.
+type readWindow struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// expected is a sequence number of the next row to write.
+	expected *int64
+	size     int64
+
+	// next is the least expected sequence number that unblocks a waiting reader.
+	next    int64
+	aborted bool
+}
+
+func newReadWindow(expected *int64, size int64) *readWindow {
+	w := &readWindow{
+		expected: expected,
+		size:     size,
+		next:     math.MaxInt64,
+	}
+	w.cond = sync.NewCond(&w.mu)
+
+	return w
+}
+
+// wait blocks while line seq is too far ahead of written rows, it returns false if window is aborted.
+// Nil window does not block.
+func (w *readWindow) wait(seq int64) bool {
+	if w == nil {
+		return true
+	}
+
+	need := seq - w.size
+	if atomic.LoadInt64(w.expected) >= need {
+		return true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for !w.aborted {
+		if atomic.LoadInt64(&w.next) > need {
+			atomic.StoreInt64(&w.next, need)
+		}
+
+		if atomic.LoadInt64(w.expected) >= need {
+			return true
+		}
+
+		w.cond.Wait()
+	}
+
+	return false
+}
+
+// advanced wakes up readers that can continue after expected sequence number is incremented.
+func (w *readWindow) advanced() {
+	if w == nil || atomic.LoadInt64(w.expected) < atomic.LoadInt64(&w.next) {
+		return
+	}
+
+	w.mu.Lock()
+	atomic.StoreInt64(&w.next, math.MaxInt64)
+	w.cond.Broadcast()
+	w.mu.Unlock()
+}
+
+// abort unblocks waiting readers when rows can not be written anymore.
+func (w *readWindow) abort() {
+	w.mu.Lock()
+	w.aborted = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+}
+
+func (rd *Reader) doLine(w *syncWorker, seq, n int64, sess *readSession) (err error) {
+	started, finishing := false, false
+
 	defer func() {
 		if r := recover(); r != nil {
 			println("panic on line:", string(w.line))
 			println(fmt.Sprintf("%v", r))
 			println(string(debug.Stack()))
+
+			rd.reject(w, rejectPanic, fmt.Errorf("%v", r))
+
+			// Line is finished to keep order of written rows.
+			if started && !finishing && sess.lineFinished != nil {
+				if ferr := sess.lineFinished(seq); ferr != nil {
+					err = fmt.Errorf("failure in line finished callback, line %d: %w", n, ferr)
+				}
+			}
 		}
 	}()
 
+	if !rd.sample.keepSeq(seq) {
+		if sess.lineSkipped != nil {
+			if err := sess.lineSkipped(seq); err != nil {
+				return fmt.Errorf("failure in line skipped callback, line %d: %w", n, err)
+			}
+		}
+
+		return nil
+	}
+
+	atomic.AddInt64(&rd.Processor.linesRead, 1)
+
 	if sess.lineStarted != nil {
 		if err := sess.lineStarted(seq); err != nil {
 			return fmt.Errorf("failure in line started callback, line %d: %w", n, err)
 		}
+
+		started = true
 	}
 
 	if rd.AddSequence {
-		seqf := float64(seq)
-		w.walker.FnNumber(seq, []byte("._sequence"), 0, []string{"_sequence"}, seqf, []byte(Format(seqf)))
+		w.walker.FnNumber(seq, []byte("._sequence"), 0, []string{"_sequence"}, float64(seq), strconv.AppendInt(nil, seq, 10))
+	}
+
+	if rd.AddFile && w.fileName != "" {
+		w.walker.FnString(seq, []byte("._file"), 0, []string{"_file"}, []byte(w.fileName))
+	}
+
+	if rd.AddLine {
+		linef := float64(w.lineNum)
+		w.walker.FnNumber(seq, []byte("._line"), 0, []string{"_line"}, linef, strconv.AppendInt(nil, w.lineNum, 10))
+	}
+
+	if rd.AddOffset {
+		offsetf := float64(w.offset)
+		w.walker.FnNumber(seq, []byte("._offset"), 0, []string{"_offset"}, offsetf, strconv.AppendInt(nil, w.offset, 10))
+	}
+
+	if rd.Format == FormatLogfmt {
+		rd.walkLogfmt(w, seq, w.line)
+	} else {
+		rd.walkJSON(w, seq)
 	}
 
+	if sess.lineWalked != nil {
+		if err := sess.lineWalked(seq); err != nil {
+			rd.reject(w, rejectColumnType, err)
+		}
+	}
+
+	if sess.lineFinished != nil {
+		finishing = true
+
+		if err := sess.lineFinished(seq); err != nil {
+			return fmt.Errorf("failure in line finished callback, line %d: %w", n, err)
+		}
+	}
+
+	return rd.Processor.checkErrorRate(false)
+}
+
+func (rd *Reader) walkJSON(w *syncWorker, seq int64) {
 	line := w.line
-	if len(line) < 2 || line[0] != '{' {
+
+	switch {
+	case rd.Format == FormatDocker:
+		if line = rd.dockerLine(w, seq); line == nil {
+			return
+		}
+	case rd.isEnvelope():
+		if line = rd.envelopeLine(seq, line, w.walker.FnString); line == nil {
+			return
+		}
+	case len(line) < 2 || line[0] != '{':
 		if line = rd.prefixedLine(seq, line, w.walker.FnString); line == nil {
-			if sess.lineFinished != nil {
-				if err := sess.lineFinished(seq); err != nil {
-					return fmt.Errorf("failure in line finished callback, line %d: %w", n, err)
-				}
-			}
+			rd.reject(w, rejectNoJSON, nil)
 
-			return nil
+			return
 		}
 	}
 
@@ -342,10 +784,14 @@ func (rd *Reader) doLine(w *syncWorker, seq, n int64, sess *readSession) error {
 		path = w.path[:0]
 	}
 
+	// Parser allows unexpected tail, so it is captured instead of being dropped.
+	rd.walkTail(seq, line, w.walker.FnString)
+
 	pv, err := p.ParseBytes(line)
 	if err != nil {
+		rd.reject(w, rejectMalformedJSON, err)
+
 		if rd.OnError != nil {
-			atomic.AddInt64(&rd.Processor.errors, 1)
 			rd.OnError(fmt.Errorf("malformed JSON at line %d: %w: %s", seq, err, string(line)))
 		}
 	} else {
@@ -355,14 +801,6 @@ func (rd *Reader) doLine(w *syncWorker, seq, n int64, sess *readSession) error {
 			w.walker.WalkFastJSON(seq, flatPath, 0, path, pv)
 		}
 	}
-
-	if sess.lineFinished != nil {
-		if err := sess.lineFinished(seq); err != nil {
-			return fmt.Errorf("failure in line finished callback, line %d: %w", n, err)
-		}
-	}
-
-	return nil
 }
 
 func (rd *Reader) prefixedLine(seq int64, line []byte, walkFn func(seq int64, flatPath []byte, pl int, path []string, value []byte) []extractor) []byte {
@@ -372,8 +810,6 @@ func (rd *Reader) prefixedLine(seq int64, line []byte, walkFn func(seq int64, fl
 		// If prefix matching is enabled, it may be ok to not have any JSON in line.
 		// All data would be parsed only from prefix (which may also describe whole line).
 		if rd.MatchPrefix == nil {
-			atomic.AddInt64(&rd.Processor.errors, 1)
-
 			if rd.OnError != nil {
 				rd.OnError(fmt.Errorf("could not find JSON in line %s", string(line)))
 			}
@@ -389,13 +825,7 @@ func (rd *Reader) prefixedLine(seq int64, line []byte, walkFn func(seq int64, fl
 			pref = line[:pos]
 		}
 
-		sm := rd.MatchPrefix.FindAllSubmatch(pref, -1)
-
-		for _, m := range sm {
-			for j := 1; j < len(m); j++ {
-				walkFn(seq, []byte("._prefix.["+strconv.Itoa(j-1)+"]"), 0, []string{"_prefix", "[" + strconv.Itoa(j-1) + "]"}, m[j])
-			}
-		}
+		rd.walkPrefix(seq, pref, walkFn)
 	}
 
 	if pos == -1 {
@@ -407,6 +837,96 @@ func (rd *Reader) prefixedLine(seq int64, line []byte, walkFn func(seq int64, fl
 	return line
 }
 
+// walkPrefix passes captured groups of the first matching prefix regular expression to walkFn,
+// it returns end position of the last match.
+//
+// Named groups are available as ._prefix.<name>, unnamed groups as ._prefix.[<index>].
+func (rd *Reader) walkPrefix(seq int64, pref []byte, walkFn func(seq int64, flatPath []byte, pl int, path []string, value []byte) []extractor) int {
+	re := rd.MatchPrefix
+	sm := re.FindAllSubmatchIndex(pref, -1)
+
+	for i := 0; len(sm) == 0 && i < len(rd.MatchPrefixes); i++ {
+		re = rd.MatchPrefixes[i]
+		sm = re.FindAllSubmatchIndex(pref, -1)
+	}
+
+	if len(sm) == 0 {
+		return 0
+	}
+
+	names := re.SubexpNames()
+	end := 0
+
+	for _, m := range sm {
+		end = m[1]
+
+		for j := 1; j < len(m)/2; j++ {
+			var v []byte
+
+			if m[2*j] >= 0 {
+				v = pref[m[2*j]:m[2*j+1]]
+			}
+
+			name := names[j]
+			if name == "" {
+				name = "[" + strconv.Itoa(j-1) + "]"
+			}
+
+			walkFn(seq, []byte("._prefix."+name), 0, []string{"_prefix", name}, v)
+		}
+	}
+
+	return end
+}
+
+// walkTail passes non-JSON tail that follows JSON value to walkFn as ._tail.
+func (rd *Reader) walkTail(seq int64, line []byte, walkFn func(seq int64, flatPath []byte, pl int, path []string, value []byte) []extractor) {
+	n := jsonValueLen(line)
+	if n <= 0 {
+		return
+	}
+
+	tail := bytes.TrimSpace(line[n:])
+	if len(tail) == 0 {
+		return
+	}
+
+	walkFn(seq, []byte("._tail"), 0, []string{"_tail"}, tail)
+}
+
+// jsonValueLen returns length of JSON object or array at the beginning of b, or -1 if value is incomplete.
+func jsonValueLen(b []byte) int {
+	depth := 0
+	inString := false
+	escaped := false
+
+	for i, c := range b {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+
+			if depth <= 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return -1
+}
+
 // LoopReader repeats bytes buffer until the limit is hit.
 type LoopReader struct {
 	BytesLimit int
@@ -0,0 +1,133 @@
+package flatjsonl
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Reasons of rejected lines.
+const (
+	rejectMalformedJSON     = "malformed JSON"
+	rejectNoJSON            = "no JSON found"
+	rejectMalformedEnvelope = "malformed envelope"
+	rejectPanic             = "panic"
+	rejectLineTooLong       = "line too long"
+	rejectColumnType        = "column type mismatch"
+)
+
+// minErrorRateLines is a number of lines to read before error rate is checked, to avoid aborting on first bad lines.
+const minErrorRateLines = 1000
+
+// rejectsWriter writes lines that could not be processed to a file.
+//
+// Every line of file has tab-separated file name, line number, reason and the rejected line verbatim
+// (lines that are too long are truncated to buffer size), so original lines can be restored with `cut -f 4-`.
+type rejectsWriter struct {
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	err error
+}
+
+func newRejectsWriter(fn string) (*rejectsWriter, error) {
+	f, err := os.Create(fn) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rejects file: %w", err)
+	}
+
+	return &rejectsWriter{
+		f: f,
+		w: bufio.NewWriterSize(f, 64*1024),
+	}, nil
+}
+
+var rejectReasonReplacer = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+func (r *rejectsWriter) write(fileName string, line int64, reason string, text []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	if fileName == "" {
+		fileName = "-"
+	}
+
+	b := make([]byte, 0, len(fileName)+len(reason)+len(text)+24)
+	b = append(b, rejectReasonReplacer.Replace(fileName)...)
+	b = append(b, '\t')
+	b = strconv.AppendInt(b, line, 10)
+	b = append(b, '\t')
+	b = append(b, rejectReasonReplacer.Replace(reason)...)
+	b = append(b, '\t')
+	b = append(b, text...)
+	b = append(b, '\n')
+
+	if _, err := r.w.Write(b); err != nil {
+		r.err = fmt.Errorf("failed to write rejects file: %w", err)
+	}
+}
+
+// Close flushes and closes file, it returns the first write error.
+func (r *rejectsWriter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to write rejects file: %w", err)
+	}
+
+	if err := r.f.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+
+	return r.err
+}
+
+// reject counts a line that could not be processed and passes it to rejects file.
+func (rd *Reader) reject(w *syncWorker, reason string, err error) {
+	rd.rejectLine(w.fileName, w.lineNum, reason, err, w.line)
+}
+
+func (rd *Reader) rejectLine(fileName string, lineNum int64, reason string, err error, line []byte) {
+	atomic.AddInt64(&rd.Processor.errors, 1)
+
+	if rd.rejects == nil {
+		return
+	}
+
+	if err != nil {
+		reason += ": " + err.Error()
+	}
+
+	rd.rejects.write(fileName, lineNum, reason, line)
+}
+
+// checkErrorRate fails if share of rejected lines exceeds -max-error-rate.
+//
+// Rate is not checked before minErrorRateLines are read, unless final is true.
+func (p *Processor) checkErrorRate(final bool) error {
+	if p.f.MaxErrorRate <= 0 {
+		return nil
+	}
+
+	lines := atomic.LoadInt64(&p.linesRead)
+	if lines == 0 || (!final && lines < minErrorRateLines) {
+		return nil
+	}
+
+	errs := atomic.LoadInt64(&p.errors)
+
+	if float64(errs)/float64(lines) > p.f.MaxErrorRate {
+		return fmt.Errorf("error rate exceeded: %d of %d lines rejected, max rate %v", errs, lines, p.f.MaxErrorRate)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package flatjsonl
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// sampler keeps lines with probability of rate, decision is made by a hash of seed and line sequence or key value.
+type sampler struct {
+	seed      uint64
+	threshold uint64
+}
+
+// newSampler creates a sampler, it returns nil if rate does not reject lines.
+func newSampler(rate float64, seed uint64) *sampler {
+	if rate <= 0 || rate >= 1 {
+		return nil
+	}
+
+	return &sampler{
+		seed:      seed,
+		threshold: uint64(rate * math.MaxUint64),
+	}
+}
+
+// keepSeq decides if line with sequence number is sampled.
+func (s *sampler) keepSeq(seq int64) bool {
+	if s == nil {
+		return true
+	}
+
+	var b [16]byte
+
+	binary.LittleEndian.PutUint64(b[:8], s.seed)
+	binary.LittleEndian.PutUint64(b[8:], uint64(seq))
+
+	return xxhash.Sum64(b[:]) < s.threshold
+}
+
+// keepValue decides if line with key value is sampled, lines with the same value are all kept or skipped.
+func (s *sampler) keepValue(v string) bool {
+	if s == nil {
+		return true
+	}
+
+	d := xxhash.New()
+
+	var b [8]byte
+
+	binary.LittleEndian.PutUint64(b[:], s.seed)
+
+	_, _ = d.Write(b[:])
+	_, _ = d.WriteString(v)
+
+	return d.Sum64() < s.threshold
+}
+
+// sampleSeed returns a seed for line sampling, random seed is used if it is not configured.
+func sampleSeed(seed int64) uint64 {
+	if seed == 0 {
+		return rand.Uint64() //nolint:gosec // Sampling does not need secure random.
+	}
+
+	return uint64(seed)
+}
+
+// reservoir keeps a uniform random sample of a fixed number of rows (algorithm R).
+type reservoir struct {
+	mu   sync.Mutex
+	size int
+	seen int64
+	rnd  *rand.Rand
+	seqs []int64
+	rows [][]Value
+}
+
+func newReservoir(size int, seed uint64) *reservoir {
+	return &reservoir{
+		size: size,
+		rnd:  rand.New(rand.NewPCG(seed, seed)), //nolint:gosec // Sampling does not need secure random.
+	}
+}
+
+// offer adds a copy of row to the sample if it is selected.
+func (r *reservoir) offer(seq int64, values []Value) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seen++
+
+	if len(r.rows) < r.size {
+		r.seqs = append(r.seqs, seq)
+		r.rows = append(r.rows, append([]Value(nil), values...))
+
+		return
+	}
+
+	j := r.rnd.Int64N(r.seen)
+	if j >= int64(r.size) {
+		return
+	}
+
+	r.seqs[j] = seq
+	r.rows[j] = append(r.rows[j][:0], values...)
+}
+
+// flush passes sampled rows ordered by sequence.
+func (r *reservoir) flush(receive func(seq int64, values []Value) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	idx := make([]int, len(r.rows))
+	for i := range idx {
+		idx[i] = i
+	}
+
+	sort.Slice(idx, func(i, j int) bool {
+		return r.seqs[idx[i]] < r.seqs[idx[j]]
+	})
+
+	for _, i := range idx {
+		if err := receive(r.seqs[i], r.rows[i]); err != nil {
+			return err
+		}
+	}
+
+	r.rows = nil
+	r.seqs = nil
+
+	return nil
+}
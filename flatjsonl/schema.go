@@ -0,0 +1,233 @@
+package flatjsonl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/swaggest/assertjson"
+)
+
+// schema is a persisted result of keys scanning.
+type schema struct {
+	// Keys are ordered as they were discovered.
+	Keys []schemaKey `json:"keys"`
+
+	// HighCardinality lists parent keys that are kept as JSON because of too many children.
+	HighCardinality []string `json:"highCardinality,omitempty"`
+}
+
+type schemaKey struct {
+	Original         string   `json:"original"`
+	Path             []string `json:"path"`
+	Replaced         string   `json:"replaced,omitempty"`
+	Type             Type     `json:"type"`
+	Types            []Type   `json:"types,omitempty"`
+	ElemType         Type     `json:"elemType,omitempty"`
+	IsZero           bool     `json:"isZero,omitempty"`
+	Kinds            []string `json:"kinds,omitempty"`
+	Digits           int      `json:"digits,omitempty"`
+	Fraction         int      `json:"fraction,omitempty"`
+	Listed           bool     `json:"listed,omitempty"`
+	TransposeDst     string   `json:"transposeDst,omitempty"`
+	TransposeKey     any      `json:"transposeKey,omitempty"`
+	TransposeTrimmed string   `json:"transposeTrimmed,omitempty"`
+}
+
+// saveSchema writes scanned keys to a file.
+func (p *Processor) saveSchema(fn string) error {
+	b, err := assertjson.MarshalIndentCompact(p.schema(), "", " ", 120)
+	if err != nil {
+		return fmt.Errorf("marshal schema: %w", err)
+	}
+
+	if err := os.WriteFile(fn, b, 0o600); err != nil {
+		return fmt.Errorf("write schema: %w", err)
+	}
+
+	return nil
+}
+
+// schema returns scanned keys.
+func (p *Processor) schema() schema {
+	replaced := make(map[string]string, len(p.keys))
+
+	for _, k := range p.keys {
+		if k.transposeDst == "" {
+			replaced[k.canonical] = k.replaced
+		}
+	}
+
+	listed := make(map[string]bool, len(p.flKeysList))
+	for _, k := range p.flKeysList {
+		listed[k] = true
+	}
+
+	var s schema
+
+	var rest []schemaKey
+
+	byOriginal := make(map[string]schemaKey, p.flKeys.Size())
+
+	p.flKeys.Range(func(_ uint64, k flKey) bool {
+		sk := schemaKey{
+			Original:         k.original,
+			Path:             k.path,
+			Type:             k.t,
+			Types:            k.tt,
+			ElemType:         k.elemType,
+			IsZero:           k.isZero,
+			Kinds:            k.kinds.names(),
+			Digits:           k.digits,
+			Fraction:         k.fraction,
+			Listed:           listed[k.original],
+			TransposeDst:     k.transposeDst,
+			TransposeTrimmed: k.transposeTrimmed,
+		}
+
+		switch {
+		case k.transposeDst != "" && k.transposeKey.t == TypeInt:
+			sk.TransposeKey = k.transposeKey.i
+		case k.transposeDst != "":
+			sk.TransposeKey = k.transposeKey.s
+		default:
+			if r, ok := replaced[k.canonical]; ok && r != k.original {
+				sk.Replaced = r
+			}
+		}
+
+		if sk.Listed {
+			byOriginal[k.original] = sk
+		} else {
+			rest = append(rest, sk)
+		}
+
+		return true
+	})
+
+	for _, k := range p.flKeysList {
+		if sk, ok := byOriginal[k]; ok {
+			s.Keys = append(s.Keys, sk)
+		}
+	}
+
+	sort.Slice(rest, func(i, j int) bool {
+		return rest[i].Original < rest[j].Original
+	})
+
+	s.Keys = append(s.Keys, rest...)
+
+	p.parentHighCardinality.Range(func(key uint64, _ bool) bool {
+		if k, ok := p.flKeys.Load(key); ok {
+			s.HighCardinality = append(s.HighCardinality, k.original)
+		}
+
+		return true
+	})
+
+	sort.Strings(s.HighCardinality)
+
+	return s
+}
+
+// loadSchema seeds keys from a file instead of scanning.
+//
+// Replaced names of keys are reused unless overridden by replaceKeys in config.
+func (p *Processor) loadSchema(fn string) error {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return fmt.Errorf("read schema: %w", err)
+	}
+
+	var s schema
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("decode schema %s: %w", fn, err)
+	}
+
+	return p.useSchema(s, fn)
+}
+
+// useSchema seeds keys from schema, source is used in error messages.
+func (p *Processor) useSchema(s schema, source string) error {
+	h := newHasher()
+
+	replaceKeys := make(map[string]string, len(p.cfg.ReplaceKeys))
+	configured := make(map[string]bool, len(p.cfg.ReplaceKeys))
+
+	for k, r := range p.cfg.ReplaceKeys {
+		replaceKeys[k] = r
+		configured[p.ck(k)] = true
+	}
+
+	for _, sk := range s.Keys {
+		if sk.Original == "" {
+			return fmt.Errorf("decode schema %s: empty key", source)
+		}
+
+		kinds, err := parseValueKinds(sk.Kinds)
+		if err != nil {
+			return fmt.Errorf("decode schema %s: %s: %w", source, sk.Original, err)
+		}
+
+		k := flKey{
+			path:             sk.Path,
+			isZero:           sk.IsZero,
+			kinds:            kinds,
+			digits:           sk.Digits,
+			fraction:         sk.Fraction,
+			t:                sk.Type,
+			tt:               sk.Types,
+			elemType:         sk.ElemType,
+			original:         sk.Original,
+			canonical:        p.ck(sk.Original),
+			transposeDst:     sk.TransposeDst,
+			transposeTrimmed: sk.TransposeTrimmed,
+		}
+
+		if len(k.path) > 1 {
+			k.parent = h.hashBytes([]byte(KeyFromPath(k.path[:len(k.path)-1])))
+		}
+
+		switch tk := sk.TransposeKey.(type) {
+		case float64:
+			k.transposeKey = intOrString{t: TypeInt, i: int(tk)}
+		case string:
+			k.transposeKey = intOrString{t: TypeString, s: tk}
+		}
+
+		p.explodeKey(&k)
+
+		for r, x := range p.extractRegex {
+			if r.MatchString(k.original) {
+				k.extractors = append(k.extractors, x...)
+
+				break
+			}
+		}
+
+		if sk.Listed {
+			p.flKeysList = append(p.flKeysList, k.original)
+
+			if _, ok := p.canonicalKeys[k.canonical]; !ok {
+				p.canonicalKeys[k.canonical] = k
+			}
+		}
+
+		if sk.Replaced != "" && !configured[k.canonical] {
+			replaceKeys[k.original] = sk.Replaced
+		}
+
+		p.flKeys.Store(h.hashBytes([]byte(k.original)), k)
+	}
+
+	for _, hc := range s.HighCardinality {
+		p.parentHighCardinality.Store(h.hashBytes([]byte(hc)), true)
+		p.cfg.KeepJSON = append(p.cfg.KeepJSON, hc)
+	}
+
+	p.cfg.ReplaceKeys = replaceKeys
+
+	return nil
+}
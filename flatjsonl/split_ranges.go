@@ -0,0 +1,218 @@
+package flatjsonl
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// minRangeSize is a minimal size of byte range to split plain file.
+const minRangeSize = 64 * 1024
+
+// RangeReader reads a part of plain file between offsets that are aligned to line ends.
+type RangeReader struct {
+	f     *os.File
+	start int64
+	end   int64
+	sr    *io.SectionReader
+
+	// lines is a number of lines in file before start, if it is known without preceding ranges.
+	lines int64
+}
+
+// newRangeReader opens file to read bytes between start and end.
+func newRangeReader(fn string, start, end, lines int64) (*RangeReader, error) {
+	f, err := os.Open(fn) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", fn, err)
+	}
+
+	return &RangeReader{f: f, start: start, end: end, lines: lines}, nil
+}
+
+// Compression implements Input, ranges are only made for uncompressed files.
+func (r *RangeReader) Compression() string {
+	return CompressionNone
+}
+
+// Size implements Input.
+func (r *RangeReader) Size() int64 {
+	return r.end - r.start
+}
+
+// Reset implements Input.
+func (r *RangeReader) Reset() {
+	r.sr = io.NewSectionReader(r.f, r.start, r.end-r.start)
+}
+
+// Read implements io.Reader.
+func (r *RangeReader) Read(p []byte) (int, error) {
+	return r.sr.Read(p)
+}
+
+// Close closes file.
+func (r *RangeReader) Close() error {
+	return r.f.Close()
+}
+
+// splitInputs replaces plain files with byte ranges to scan them concurrently.
+//
+// Files are not split if lines can span range boundaries (stream and array input modes, partial CRI lines),
+// or if lines are limited or skipped, as those limits apply to every input.
+// With -add-line, files are only split if keys are scanned, as line counts of ranges are needed to number lines.
+func (p *Processor) splitInputs() error {
+	if p.f.SplitRanges < 2 || (p.f.InputMode != "" && p.f.InputMode != InputModeLines) || p.f.Format == FormatCRI ||
+		p.f.MaxLines > 0 || p.f.OffsetLines > 0 || p.f.MaxLinesKeys > 0 || (p.f.AddLine && !p.scansKeys()) {
+		return nil
+	}
+
+	inputs := make([]Input, 0, len(p.inputs))
+
+	for _, in := range p.inputs {
+		if in.Reader != nil {
+			inputs = append(inputs, in)
+
+			continue
+		}
+
+		ranges, err := splitRanges(in.FileName, p.f.SplitRanges)
+		if err != nil {
+			return err
+		}
+
+		if len(ranges) == 0 {
+			inputs = append(inputs, in)
+
+			continue
+		}
+
+		for _, r := range ranges {
+			inputs = append(inputs, Input{FileName: in.FileName, Reader: r})
+		}
+	}
+
+	p.inputs = inputs
+
+	return nil
+}
+
+// lineBase returns the number of lines in preceding ranges of the same file,
+// so that lines of a range are numbered from the beginning of file.
+func (p *Processor) lineBase(i int) int64 {
+	in := p.inputs[i]
+
+	rr, ok := in.Reader.(*RangeReader)
+	if !ok {
+		return 0
+	}
+
+	base := rr.lines
+
+	if len(p.inputLines) != len(p.inputs) {
+		return base
+	}
+
+	for j := i - 1; j >= 0; j-- {
+		if _, ok := p.inputs[j].Reader.(*RangeReader); !ok || p.inputs[j].FileName != in.FileName {
+			break
+		}
+
+		base += p.inputLines[j]
+	}
+
+	return base
+}
+
+// splitRanges returns up to n readers of file parts, or nil if file is not plain or is too small.
+func splitRanges(fn string, n int) (ranges []*RangeReader, err error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", fn, err)
+	}
+
+	defer func() {
+		if clErr := f.Close(); clErr != nil && err == nil {
+			err = clErr
+		}
+	}()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := fi.Size()
+
+	if !fi.Mode().IsRegular() || size < 2*minRangeSize {
+		return nil, nil
+	}
+
+	if int64(n) > size/minRangeSize {
+		n = int(size / minRangeSize)
+	}
+
+	br := bufio.NewReaderSize(f, 1024)
+	if sniffCompression(br) != "" || isZip(br) || isTar(br) {
+		return nil, nil
+	}
+
+	bounds := []int64{0}
+
+	for i := 1; i < n; i++ {
+		b, err := lineEnd(f, size*int64(i)/int64(n))
+		if err != nil {
+			return nil, fmt.Errorf("failed to split %s: %w", fn, err)
+		}
+
+		if b > bounds[len(bounds)-1] && b < size {
+			bounds = append(bounds, b)
+		}
+	}
+
+	if len(bounds) < 2 {
+		return nil, nil
+	}
+
+	bounds = append(bounds, size)
+
+	for i := 1; i < len(bounds); i++ {
+		r, err := newRangeReader(fn, bounds[i-1], bounds[i], 0)
+		if err != nil {
+			for _, r := range ranges {
+				_ = r.Close()
+			}
+
+			return nil, err
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+// lineEnd returns offset after the first new line at or after offset, or file size if there is no new line.
+func lineEnd(f *os.File, offset int64) (int64, error) {
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := f.ReadAt(buf, offset)
+
+		if i := bytes.IndexByte(buf[:n], '\n'); i != -1 {
+			return offset + int64(i) + 1, nil
+		}
+
+		offset += int64(n)
+
+		if errors.Is(err, io.EOF) {
+			return offset, nil
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package flatjsonl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// spoolChunkSize is a size of decoded data that is stored in spool at once.
+	spoolChunkSize = 1 << 20
+	// spoolMemory is a max size of decoded data of an input that is kept in memory.
+	spoolMemory = 8 << 20
+)
+
+var errSpoolClosed = errors.New("spool closed")
+
+// spool decodes compressed input in background, so that several inputs are decoded at once while their lines
+// are processed in order of inputs.
+//
+// Decoded data is kept in memory up to spoolMemory, the rest is written to a temporary file in spill directory.
+type spool struct {
+	src      io.Reader
+	dir      string
+	compress bool
+	// memory is a max size of decoded data kept in memory.
+	memory int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	chunks []spoolChunk
+	mem    int
+	err    error // err is io.EOF when source is fully decoded.
+	closed bool
+	done   chan struct{}
+
+	// Spill file is written by decoding goroutine and read with ReadAt by consumer.
+	file *os.File
+	size int64
+	enc  *zstd.Encoder
+	dec  *zstd.Decoder
+
+	cur []byte
+	buf []byte
+	out []byte
+}
+
+// spoolChunk is a chunk of decoded data, it is stored in memory or at offset of spill file.
+type spoolChunk struct {
+	data   []byte
+	inFile bool
+	off    int64
+	size   int
+}
+
+// newSpool creates a spool of src.
+func newSpool(src io.Reader, dir string, compress bool) *spool {
+	s := &spool{
+		src:      src,
+		dir:      dir,
+		compress: compress,
+		memory:   spoolMemory,
+		done:     make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+
+	return s
+}
+
+// start starts decoding of source in background.
+func (s *spool) start() *spool {
+	go s.decode()
+
+	return s
+}
+
+func (s *spool) decode() {
+	defer close(s.done)
+
+	for {
+		buf := make([]byte, spoolChunkSize)
+
+		var (
+			n   int
+			err error
+		)
+
+		for n < len(buf) && err == nil {
+			var m int
+
+			m, err = s.src.Read(buf[n:])
+			n += m
+		}
+
+		if n > 0 {
+			if perr := s.put(buf[:n]); perr != nil {
+				err = perr
+			}
+		}
+
+		if err != nil {
+			s.mu.Lock()
+			s.err = err
+			s.cond.Broadcast()
+			s.mu.Unlock()
+
+			return
+		}
+	}
+}
+
+// put adds a chunk of decoded data to memory or spill file.
+func (s *spool) put(data []byte) error {
+	s.mu.Lock()
+	closed := s.closed
+	inMemory := s.mem+len(data) <= s.memory
+
+	if inMemory {
+		s.mem += len(data)
+	}
+	s.mu.Unlock()
+
+	if closed {
+		return errSpoolClosed
+	}
+
+	c := spoolChunk{data: data}
+
+	if !inMemory {
+		var err error
+
+		if c, err = s.store(data); err != nil {
+			return fmt.Errorf("failed to write spool file: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.chunks = append(s.chunks, c)
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *spool) store(data []byte) (spoolChunk, error) {
+	if s.file == nil {
+		f, err := os.CreateTemp(s.dir, "flatjsonl-*.spool")
+		if err != nil {
+			return spoolChunk{}, err
+		}
+
+		s.file = f
+
+		if s.compress {
+			if s.enc, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest)); err != nil {
+				return spoolChunk{}, err
+			}
+		}
+	}
+
+	if s.enc != nil {
+		data = s.enc.EncodeAll(data, nil)
+	}
+
+	if _, err := s.file.Write(data); err != nil {
+		return spoolChunk{}, err
+	}
+
+	c := spoolChunk{inFile: true, off: s.size, size: len(data)}
+	s.size += int64(len(data))
+
+	return c, nil
+}
+
+// Read implements io.Reader, it blocks until data is decoded.
+func (s *spool) Read(p []byte) (int, error) {
+	if len(s.cur) == 0 {
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, s.cur)
+	s.cur = s.cur[n:]
+
+	return n, nil
+}
+
+func (s *spool) next() error {
+	s.mu.Lock()
+
+	for len(s.chunks) == 0 && s.err == nil {
+		s.cond.Wait()
+	}
+
+	if len(s.chunks) == 0 {
+		err := s.err
+		s.mu.Unlock()
+
+		return err
+	}
+
+	c := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	s.mem -= len(c.data)
+	s.mu.Unlock()
+
+	if !c.inFile {
+		s.cur = c.data
+
+		return nil
+	}
+
+	if cap(s.buf) < c.size {
+		s.buf = make([]byte, c.size)
+	}
+
+	s.buf = s.buf[:c.size]
+
+	if _, err := s.file.ReadAt(s.buf, c.off); err != nil {
+		return fmt.Errorf("failed to read spool file: %w", err)
+	}
+
+	if !s.compress {
+		s.cur = s.buf
+
+		return nil
+	}
+
+	if s.dec == nil {
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			return err
+		}
+
+		s.dec = dec
+	}
+
+	out, err := s.dec.DecodeAll(s.buf, s.out[:0])
+	if err != nil {
+		return fmt.Errorf("failed to decode spool file: %w", err)
+	}
+
+	s.out = out
+	s.cur = out
+
+	return nil
+}
+
+// Close stops decoding and removes spill file, source can be closed after that.
+func (s *spool) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.chunks = nil
+	s.mu.Unlock()
+
+	<-s.done
+
+	if s.dec != nil {
+		s.dec.Close()
+	}
+
+	var errs []error
+
+	if s.enc != nil {
+		errs = append(errs, s.enc.Close())
+	}
+
+	if s.file != nil {
+		errs = append(errs, s.file.Close(), os.Remove(s.file.Name()))
+	}
+
+	return errors.Join(errs...)
+}
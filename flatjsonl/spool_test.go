@@ -0,0 +1,48 @@
+package flatjsonl
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool(t *testing.T) {
+	var data []byte
+
+	for i := 0; len(data) < 3*spoolChunkSize; i++ {
+		data = append(data, `{"i":`+strconv.Itoa(i)+"}\n"...)
+	}
+
+	for _, compress := range []bool{false, true} {
+		t.Run("zstd "+strconv.FormatBool(compress), func(t *testing.T) {
+			dir := t.TempDir()
+
+			s := newSpool(bytes.NewReader(data), dir, compress)
+			s.memory = spoolChunkSize
+			s.start()
+
+			<-s.done
+
+			// Only the first chunk is kept in memory.
+			files, err := filepath.Glob(filepath.Join(dir, "*.spool"))
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+			assert.Equal(t, spoolChunkSize, s.mem)
+
+			res, err := io.ReadAll(s)
+			require.NoError(t, err)
+			assert.Equal(t, data, res)
+
+			require.NoError(t, s.Close())
+
+			files, err = filepath.Glob(filepath.Join(dir, "*.spool"))
+			require.NoError(t, err)
+			assert.Empty(t, files)
+		})
+	}
+}
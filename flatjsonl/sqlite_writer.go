@@ -23,6 +23,10 @@ type SQLiteWriter struct {
 
 	transposed map[string]*baseWriter
 	b          *baseWriter
+
+	// resume is true to continue output of interrupted run after lastRow, tables are not created then.
+	resume  bool
+	lastRow int64
 }
 
 // NewSQLiteWriter creates an instance of SQLiteWriter.
@@ -55,6 +59,8 @@ func NewSQLiteWriter(fn string, tableName string, p *Processor) (*SQLiteWriter,
 		maxCols:   p.f.SQLMaxCols - 1, // -1 for _seq_id.
 	}
 
+	_, c.lastRow, c.resume = p.cp.resumeOutput()
+
 	return c, nil
 }
 
@@ -217,8 +223,7 @@ _seq_id integer,
 		if i > 0 && i%c.maxCols == 0 {
 			createTable = createTable[:len(createTable)-2] + "\n)"
 
-			_, err := c.db.Exec(createTable)
-			if err != nil {
+			if err := c.execCreate(tableName, createTable); err != nil {
 				return fmt.Errorf("failed to create SQLite table with %d keys: %w", len(keys), err)
 			}
 
@@ -245,6 +250,11 @@ _seq_id integer,
 			tp = " INTEGER"
 		case TypeFloat:
 			tp = " REAL"
+		case TypeDecimal:
+			// Numbers with more digits are kept as text, as NUMERIC affinity would make them REAL.
+			if k.precision > 0 && k.precision <= 15 {
+				tp = " NUMERIC"
+			}
 		}
 
 		createTable += sqluct.QuoteRequiredBackticks(k.replaced) + tp + `,` + "\n"
@@ -252,14 +262,40 @@ _seq_id integer,
 
 	createTable = createTable[:len(createTable)-2] + "\n)"
 
-	_, err := c.db.Exec(createTable)
-	if err != nil {
+	if err := c.execCreate(tableName, createTable); err != nil {
 		return fmt.Errorf("failed to create SQLite table with %d keys: %w", len(keys), err)
 	}
 
 	return nil
 }
 
+// execCreate creates table, or deletes rows that were written after checkpoint if output is resumed.
+// Existing table is kept in append mode.
+func (c *SQLiteWriter) execCreate(tableName, createTable string) error {
+	if c.resume {
+		_, err := c.db.Exec(`DELETE FROM "` + tableName + `" WHERE _seq_id > ` + strconv.FormatInt(c.lastRow, 10))
+
+		return err
+	}
+
+	if c.p.f.Append {
+		createTable = strings.Replace(createTable, "CREATE TABLE ", "CREATE TABLE IF NOT EXISTS ", 1)
+	}
+
+	_, err := c.db.Exec(createTable)
+
+	return err
+}
+
+// Flush commits outstanding transaction.
+func (c *SQLiteWriter) Flush() error {
+	if c.tx == nil {
+		return nil
+	}
+
+	return c.commitTx()
+}
+
 // Close commits outstanding transaction and closes database instance.
 func (c *SQLiteWriter) Close() error {
 	if c.tx != nil {
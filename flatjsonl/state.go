@@ -0,0 +1,347 @@
+package flatjsonl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ingestState is a progress of incremental processing that is kept in -state file between runs.
+type ingestState struct {
+	// Files are ingested parts of input files by path.
+	Files map[string]ingestedFile `json:"files"`
+
+	// Schema is reused to append rows to existing tables.
+	Schema *schema `json:"schema,omitempty"`
+
+	// Sequence is the last line number and LastRow is the last row number in output, they are continued in next run.
+	Sequence int64 `json:"sequence"`
+	LastRow  int64 `json:"lastRow"`
+}
+
+// ingestedFile is a fingerprint and ingested part of input file.
+type ingestedFile struct {
+	Inode uint64 `json:"inode,omitempty"`
+	Size  int64  `json:"size"`
+
+	// Offset is a number of ingested bytes, Lines is a number of ingested lines.
+	Offset int64 `json:"offset"`
+	Lines  int64 `json:"lines,omitempty"`
+}
+
+func checkState(f Flags) error {
+	if f.State == "" {
+		if f.Append {
+			return errors.New("append requires -state to continue row numbers")
+		}
+
+		return nil
+	}
+
+	switch {
+	case f.Follow:
+		return errors.New("state is not supported in follow mode")
+	case f.MaxLines > 0 || f.OffsetLines > 0:
+		return errors.New("state is not supported with -max-lines or -offset-lines")
+	case f.Append && (f.Parquet != "" || f.DuckDB != "" || (f.SQLite != "" && f.SQLiteCLI)):
+		return errors.New("append is supported for CSV, RAW, SQLite and PG dump outputs")
+	}
+
+	return nil
+}
+
+// stateTracker replaces inputs with their new content and saves ingested parts of inputs,
+// methods are safe to call on nil instance.
+type stateTracker struct {
+	fn   string
+	prev ingestState
+
+	mu   sync.Mutex
+	next ingestState
+
+	// writing is true during output pass of reading.
+	writing bool
+}
+
+// prepareState loads state and replaces inputs with parts that were not ingested yet.
+func (p *Processor) prepareState() error {
+	st := &stateTracker{fn: p.f.State}
+
+	b, err := os.ReadFile(st.fn)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("read state: %w", err)
+	default:
+		if err := json.Unmarshal(b, &st.prev); err != nil {
+			return fmt.Errorf("decode state %s: %w", st.fn, err)
+		}
+	}
+
+	st.next.Files = make(map[string]ingestedFile, len(p.inputs))
+	st.next.Sequence = st.prev.Sequence
+	st.next.LastRow = st.prev.LastRow
+
+	inputs := make([]Input, 0, len(p.inputs))
+
+	for _, in := range p.inputs {
+		if in.Reader != nil || in.FileName == "" {
+			return errors.New("state requires input files, readers and STDIN are not supported")
+		}
+
+		in, err := st.newContent(in.FileName)
+		if err != nil {
+			return err
+		}
+
+		if in != nil {
+			inputs = append(inputs, *in)
+		}
+	}
+
+	p.inputs = inputs
+	p.st = st
+
+	return nil
+}
+
+// newContent returns input with content of file that was not ingested, or nil if there is no new content.
+//
+// Plain files are continued from ingested offset up to the last complete line, as the last line can be still written.
+// The last line without new line is also ingested if size of file is not changed since previous run.
+// Compressed files and archives are read again if they are changed, lines that were ingested before are skipped.
+// Replaced (by inode) or truncated files are read from the beginning.
+func (st *stateTracker) newContent(fn string) (*Input, error) {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", fn, err)
+	}
+
+	cur := ingestedFile{Inode: fileInode(fi), Size: fi.Size()}
+
+	prev, ok := st.prev.Files[fn]
+	if ok && (prev.Inode != cur.Inode || prev.Size > cur.Size || prev.Offset > cur.Size) {
+		prev = ingestedFile{}
+	}
+
+	plain, err := isPlainFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	if !plain {
+		cur.Offset = cur.Size
+
+		if prev.Offset == cur.Size && prev.Size == cur.Size {
+			cur.Lines = prev.Lines
+			st.next.Files[fn] = cur
+
+			return nil, nil
+		}
+
+		// Lines are counted again during reading.
+		st.next.Files[fn] = cur
+
+		return &Input{FileName: fn, skipLines: prev.Lines}, nil
+	}
+
+	start := prev.Offset
+
+	if start > 0 && start < cur.Size {
+		// New line after last line that was ingested without it does not start a new line.
+		if start, err = skipLineEnd(fn, start); err != nil {
+			return nil, err
+		}
+	}
+
+	end, err := lastLineEnd(fn, start, cur.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && prev.Size == cur.Size {
+		// File is not written since previous run, so the last line is complete even without new line.
+		end = cur.Size
+	}
+
+	cur.Offset = end
+	cur.Lines = prev.Lines
+	st.next.Files[fn] = cur
+
+	if end <= start {
+		return nil, nil
+	}
+
+	if start == 0 && end == cur.Size {
+		return &Input{FileName: fn}, nil
+	}
+
+	r, err := newRangeReader(fn, start, end, prev.Lines)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Input{FileName: fn, Reader: r}, nil
+}
+
+// reusedSchema returns schema of previous run if it is used instead of keys scanning to append rows.
+func (st *stateTracker) reusedSchema(p *Processor) *schema {
+	if st == nil || !p.f.Append {
+		return nil
+	}
+
+	return st.prev.Schema
+}
+
+// startWriting enables counting of ingested lines in output pass.
+func (st *stateTracker) startWriting() {
+	if st == nil {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.writing = true
+}
+
+// inputRead adds a number of lines that were read from input.
+func (st *stateTracker) inputRead(in Input, lines int64) {
+	if st == nil {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if !st.writing {
+		return
+	}
+
+	f := st.next.Files[in.FileName]
+	f.Lines += lines
+	st.next.Files[in.FileName] = f
+}
+
+// written records the last line and row in output.
+func (st *stateTracker) written(seq, lastRow int64) {
+	if st == nil {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.next.Sequence = seq
+	st.next.LastRow = lastRow
+}
+
+// save writes state of ingested inputs after successful processing.
+func (st *stateTracker) save(p *Processor) error {
+	if st == nil {
+		return nil
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	switch {
+	case st.reusedSchema(p) != nil:
+		st.next.Schema = st.prev.Schema
+	case p.scansKeys() && len(p.includeKeys) > 0:
+		s := p.schema()
+		st.next.Schema = &s
+	default:
+		st.next.Schema = st.prev.Schema
+	}
+
+	b, err := json.MarshalIndent(st.next, "", " ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+
+	if err := writeFileSync(st.fn, b); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+
+	return nil
+}
+
+// isPlainFile checks if file is not compressed and is not an archive.
+func isPlainFile(fn string) (bool, error) {
+	f, err := os.Open(fn) //nolint:gosec
+	if err != nil {
+		return false, fmt.Errorf("failed to open file %s: %w", fn, err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	br := bufio.NewReaderSize(f, 1024)
+
+	return sniffCompression(br) == "" && !isZip(br) && !isTar(br), nil
+}
+
+// skipLineEnd returns offset after new line at offset, if the line before offset has no new line.
+func skipLineEnd(fn string, offset int64) (int64, error) {
+	f, err := os.Open(fn) //nolint:gosec
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file %s: %w", fn, err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	buf := make([]byte, 2)
+
+	if _, err := f.ReadAt(buf, offset-1); err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", fn, err)
+	}
+
+	if buf[0] != '\n' && buf[1] == '\n' {
+		return offset + 1, nil
+	}
+
+	return offset, nil
+}
+
+// lastLineEnd returns offset after the last new line between start and end, or start if there is no new line.
+func lastLineEnd(fn string, start, end int64) (int64, error) {
+	f, err := os.Open(fn) //nolint:gosec
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file %s: %w", fn, err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	buf := make([]byte, 32*1024)
+
+	for end > start {
+		size := int64(len(buf))
+		if end-start < size {
+			size = end - start
+		}
+
+		n, err := f.ReadAt(buf[:size], end-size)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", fn, err)
+		}
+
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i != -1 {
+			return end - size + int64(i) + 1, nil
+		}
+
+		end -= size
+	}
+
+	return start, nil
+}
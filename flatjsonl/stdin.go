@@ -0,0 +1,201 @@
+package flatjsonl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// StdinFileName is a special input name to read data from STDIN.
+const StdinFileName = "-"
+
+// StdinReader reads input from a stream that can not be rewound, for example STDIN.
+//
+// If spill is enabled, stream is copied into a temporary file during the first read,
+// so that subsequent passes can iterate the same data again.
+type StdinReader struct {
+	src io.Reader
+
+	spill     bool
+	spillDir  string
+	spillZstd bool
+
+	started bool
+	replay  bool
+	size    int64
+	err     error
+
+	r    io.Reader
+	file *os.File
+	enc  *zstd.Encoder
+	dec  *zstd.Decoder
+}
+
+// NewStdinReader creates an instance of StdinReader.
+func NewStdinReader(src io.Reader) *StdinReader {
+	return &StdinReader{src: src}
+}
+
+// EnableSpill enables copying of data into temporary file for repeated reads.
+// It has no effect once reading has started.
+func (s *StdinReader) EnableSpill(dir string, compress bool) {
+	if s.started {
+		return
+	}
+
+	s.spill = true
+	s.spillDir = dir
+	s.spillZstd = compress
+}
+
+// Compression implements Input.
+func (s *StdinReader) Compression() string {
+	return ""
+}
+
+// Size implements Input, it returns the number of bytes read from stream so far.
+func (s *StdinReader) Size() int64 {
+	return s.size
+}
+
+// Reset prepares reader for the next pass.
+func (s *StdinReader) Reset() {
+	if !s.started || s.err != nil {
+		return
+	}
+
+	if !s.spill {
+		s.err = errors.New("stdin can not be read more than once without spilling to a file")
+
+		return
+	}
+
+	if err := s.rewind(); err != nil {
+		s.err = fmt.Errorf("rewind stdin spill file: %w", err)
+	}
+}
+
+// Read implements io.Reader.
+func (s *StdinReader) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	if !s.started {
+		if err := s.start(); err != nil {
+			s.err = err
+
+			return 0, err
+		}
+	}
+
+	n, err := s.r.Read(p)
+
+	if !s.replay {
+		s.size += int64(n)
+	}
+
+	return n, err
+}
+
+func (s *StdinReader) start() error {
+	s.started = true
+	s.r = s.src
+
+	if !s.spill {
+		return nil
+	}
+
+	f, err := os.CreateTemp(s.spillDir, "flatjsonl-*.spill")
+	if err != nil {
+		return fmt.Errorf("failed to create stdin spill file: %w", err)
+	}
+
+	s.file = f
+
+	var w io.Writer = f
+
+	if s.spillZstd {
+		s.enc, err = zstd.NewWriter(f, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithLowerEncoderMem(true))
+		if err != nil {
+			return fmt.Errorf("failed to init zstd writer for stdin spill file: %w", err)
+		}
+
+		w = s.enc
+	}
+
+	s.r = io.TeeReader(s.src, w)
+
+	return nil
+}
+
+func (s *StdinReader) rewind() error {
+	if !s.replay {
+		// First pass could have stopped early (for example with max lines limit),
+		// the rest of the stream is still needed for the next pass.
+		n, err := io.Copy(io.Discard, s.r)
+		if err != nil {
+			return err
+		}
+
+		s.size += n
+
+		if s.enc != nil {
+			if err := s.enc.Close(); err != nil {
+				return err
+			}
+		}
+
+		s.replay = true
+	}
+
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if !s.spillZstd {
+		s.r = s.file
+
+		return nil
+	}
+
+	if s.dec == nil {
+		dec, err := zstd.NewReader(s.file)
+		if err != nil {
+			return err
+		}
+
+		s.dec = dec
+	} else if err := s.dec.Reset(s.file); err != nil {
+		return err
+	}
+
+	s.r = s.dec
+
+	return nil
+}
+
+// Close removes temporary spill file.
+func (s *StdinReader) Close() error {
+	if s.dec != nil {
+		s.dec.Close()
+	}
+
+	if s.file == nil {
+		return nil
+	}
+
+	return errors.Join(s.file.Close(), os.Remove(s.file.Name()))
+}
+
+func stdinIsPipe() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice == 0
+}
@@ -1,5 +1,12 @@
 package flatjsonl
 
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strconv"
+)
+
 // Type is a scalar type.
 type Type string
 
@@ -13,9 +20,69 @@ const (
 	TypeBool   = Type("bool")
 	TypeNull   = Type("null")
 	TypeJSON   = Type("json")
-	TypeAbsent = Type("")
+	TypeList   = Type("list")
+
+	// TypeTimestamp, TypeDecimal and TypeUUID are set by columnTypes config or inferred from values.
+	TypeTimestamp = Type("timestamp")
+	TypeDecimal   = Type("decimal")
+	TypeUUID      = Type("uuid")
+	TypeAbsent    = Type("")
 )
 
+// numberType returns type of JSON number literal, integers that do not fit int64 are decimals,
+// integers that do not fit decimal precision are strings.
+func numberType(n float64, raw []byte) Type {
+	if _, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+		return TypeInt
+	} else if errors.Is(err, strconv.ErrRange) {
+		if !fitsDecimal(raw) {
+			return TypeString
+		}
+
+		return TypeDecimal
+	}
+
+	if float64(int(n)) == n {
+		return TypeInt
+	}
+
+	return TypeFloat
+}
+
+// fitsDecimal checks if integer literal has no more digits than the widest decimal column.
+func fitsDecimal(raw []byte) bool {
+	if len(raw) > 0 && raw[0] == '-' {
+		raw = raw[1:]
+	}
+
+	return len(raw) <= defaultDecimalPrecision
+}
+
+// numberDigits returns number of digits in integer and fraction parts of JSON number literal with exponent applied.
+func numberDigits(raw []byte) (digits, fraction int) {
+	if i := bytes.IndexAny(raw, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(string(raw[i+1:]))
+		if err != nil {
+			// Exponent is too big for any decimal.
+			return math.MaxInt16, math.MaxInt16
+		}
+
+		digits, fraction = numberDigits(raw[:i])
+
+		return max(digits+exp, 0), max(fraction-exp, 0)
+	}
+
+	if len(raw) > 0 && raw[0] == '-' {
+		raw = raw[1:]
+	}
+
+	if i := bytes.IndexByte(raw, '.'); i >= 0 {
+		return i, len(raw) - i - 1
+	}
+
+	return len(raw), 0
+}
+
 // Update merges original type with updated.
 func (t Type) Update(u Type) Type {
 	// Undefined type is replaced by update.
@@ -41,6 +108,11 @@ func (t Type) Update(u Type) Type {
 		return TypeJSON
 	}
 
+	// List and non-list make JSON.
+	if (u == TypeList || t == TypeList) && u != TypeNull {
+		return TypeJSON
+	}
+
 	// String replaces any type.
 	if u == TypeString || t == TypeString {
 		return TypeString
@@ -50,6 +122,16 @@ func (t Type) Update(u Type) Type {
 		return t
 	}
 
+	// Decimal keeps numbers exact.
+	if (t == TypeDecimal && (u == TypeInt || u == TypeFloat)) || (u == TypeDecimal && (t == TypeInt || t == TypeFloat)) {
+		return TypeDecimal
+	}
+
+	// Timestamp or UUID and other type make unconstrained type: string.
+	if t == TypeTimestamp || u == TypeTimestamp || t == TypeUUID || u == TypeUUID {
+		return TypeString
+	}
+
 	// Bool and non-bool make unconstrained type: string.
 	if (t == TypeBool && u != TypeBool) || (t != TypeBool && u == TypeBool) {
 		return TypeString
@@ -1,6 +1,10 @@
 package flatjsonl
 
-import "testing"
+import (
+	"math"
+	"strconv"
+	"testing"
+)
 
 func TestTypeUpdate_JSONDominatesScalar(t *testing.T) {
 	cases := []struct {
@@ -26,3 +30,56 @@ func TestTypeUpdate_JSONDominatesScalar(t *testing.T) {
 		})
 	}
 }
+
+func TestNumberType(t *testing.T) {
+	for raw, exp := range map[string]Type{
+		"42":                    TypeInt,
+		"-9223372036854775808":  TypeInt,
+		"9223372036854775808":   TypeDecimal,
+		"18446744073709551615":  TypeDecimal,
+		"-92233720368547758090": TypeDecimal,
+		"-99999999999999999999999999999999999999": TypeDecimal,
+		"123456789012345678901234567890123456789": TypeString,
+		"1.0":  TypeInt,
+		"1e3":  TypeInt,
+		"1.5":  TypeFloat,
+		"1e30": TypeFloat,
+	} {
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := numberType(n, []byte(raw)); got != exp {
+			t.Errorf("expected %s for %s, got %s", exp, raw, got)
+		}
+	}
+}
+
+func TestNumberDigits(t *testing.T) {
+	for raw, exp := range map[string][2]int{
+		"12.34":                  [2]int{2, 2},
+		"-0.5":                   [2]int{1, 1},
+		"18446744073709551615":   [2]int{20, 0},
+		"1.5e3":                  [2]int{4, 0},
+		"1.5e-7":                 [2]int{0, 8},
+		"1e99999999999999999999": [2]int{math.MaxInt16, math.MaxInt16},
+	} {
+		digits, fraction := numberDigits([]byte(raw))
+		if digits != exp[0] || fraction != exp[1] {
+			t.Errorf("expected %v for %s, got %d, %d", exp, raw, digits, fraction)
+		}
+	}
+}
+
+func TestTypeUpdate_decimal(t *testing.T) {
+	for _, u := range []Type{TypeInt, TypeFloat} {
+		if got := TypeDecimal.Update(u); got != TypeDecimal {
+			t.Errorf("expected %s + %s to be %s, got %s", TypeDecimal, u, TypeDecimal, got)
+		}
+
+		if got := u.Update(TypeDecimal); got != TypeDecimal {
+			t.Errorf("expected %s + %s to be %s, got %s", u, TypeDecimal, TypeDecimal, got)
+		}
+	}
+}
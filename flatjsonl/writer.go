@@ -8,6 +8,8 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bool64/progress"
 	"github.com/klauspost/compress/zstd"
@@ -24,10 +26,26 @@ type WriteReceiver interface {
 	Close() error
 }
 
+// Flusher is implemented by receivers that can persist received rows before Close.
+type Flusher interface {
+	Flush() error
+}
+
+// positioner is implemented by receivers that can continue output of interrupted run from checkpoint.
+type positioner interface {
+	// positions adds sizes of output files with flushed rows.
+	positions(pos map[string]int64) error
+}
+
 // Writer dispatches rows to multiple receivers.
 type Writer struct {
+	mu        sync.Mutex
 	receivers []WriteReceiver
 	Progress  *progress.Progress
+
+	// concurrent is set if rows are received by several goroutines in unordered mode,
+	// or Flush is called while rows are received in follow mode.
+	concurrent bool
 }
 
 // Value encapsulates value of an allowed Type.
@@ -38,6 +56,32 @@ type Value struct {
 	Number    float64
 	RawNumber string
 	Bool      bool
+
+	// Int is an exact value of integer number if IsInt is true,
+	// integers that do not fit int64 are only exact in RawNumber.
+	Int   int64
+	IsInt bool
+
+	// List has elements of TypeList value.
+	List []Value
+
+	// Time is a parsed value of TypeTimestamp, String has formatted time.
+	Time time.Time
+
+	// Unconverted is set for value that failed to convert to column type and is kept as is.
+	Unconverted bool
+}
+
+// newNumberValue creates number value from JSON literal, integer that fits int64 is kept exactly.
+func newNumberValue(n float64, raw string) Value {
+	v := Value{Type: TypeFloat, Number: n, RawNumber: raw}
+
+	if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		v.Int = i
+		v.IsInt = true
+	}
+
+	return v
 }
 
 // Format formats Value as string.
@@ -50,9 +94,17 @@ func (v Value) Format() string {
 			return v.RawNumber
 		}
 
+		if v.IsInt {
+			return strconv.FormatInt(v.Int, 10)
+		}
+
 		return strconv.FormatFloat(v.Number, 'g', 5, 64)
 	case TypeBool:
 		return strconv.FormatBool(v.Bool)
+	case TypeJSON, TypeTimestamp:
+		return v.String
+	case TypeList:
+		return formatList(v.List)
 	case TypeNull:
 		return "NULL"
 	case TypeAbsent:
@@ -81,6 +133,11 @@ func (w *Writer) SetupKeys(keys []flKey) error {
 
 // ReceiveRow passes row to all receivers.
 func (w *Writer) ReceiveRow(seq int64, values []Value) error {
+	if w.concurrent {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+	}
+
 	var errs []string
 
 	for _, r := range w.receivers {
@@ -96,6 +153,50 @@ func (w *Writer) ReceiveRow(seq int64, values []Value) error {
 	return nil
 }
 
+// Flush persists received rows in receivers that implement Flusher.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var errs []string
+
+	for _, r := range w.receivers {
+		if f, ok := r.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// commit flushes receivers and returns sizes of output files.
+func (w *Writer) commit() (map[string]int64, error) {
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pos := make(map[string]int64)
+
+	for _, r := range w.receivers {
+		if c, ok := r.(positioner); ok {
+			if err := c.positions(pos); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return pos, nil
+}
+
 // Add adds another row receiver.
 func (w *Writer) Add(r WriteReceiver) {
 	w.receivers = append(w.receivers, r)
@@ -112,6 +213,9 @@ func (w *Writer) HasReceivers() bool {
 
 // Close tries to close all receivers and returns combined error in case of failures.
 func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	var errs []string
 
 	for _, r := range w.receivers {
@@ -132,6 +236,14 @@ type idxKey struct {
 	k   flKey
 }
 
+type lineageIdx struct {
+	src int
+	dst int
+}
+
+// lineageKeys are copied from parent line to rows of transposed tables.
+var lineageKeys = []string{"._file", "._line", "._offset"}
+
 type baseWriter struct {
 	p *Processor
 
@@ -148,6 +260,15 @@ type baseWriter struct {
 	// transposedMapping maps original key index to reduced set of trimmed keys.
 	transposedMapping map[int]int
 
+	// lineage maps indexes of ._file, ._line and ._offset keys to columns of transposed rows.
+	lineage []lineageIdx
+
+	// listFormat formats values of TypeList, JSON array is used if nil.
+	listFormat func(values []Value) string
+
+	// typedColumns is set for outputs with typed schema, unconverted values are written as null there.
+	typedColumns bool
+
 	extName string
 }
 
@@ -206,6 +327,19 @@ func (b *baseWriter) initFilteredKeys() {
 	for o, t := range b.transposedMapping {
 		k := b.filteredKeys[t]
 		k.UpdateType(b.keys[o].t)
+
+		if src := b.keys[o]; src.typed {
+			k.typed = true
+			k.precision = max(k.precision, src.precision)
+			k.scale = max(k.scale, src.scale)
+		} else if src.t == TypeDecimal {
+			k.scale = max(k.scale, src.scale)
+		}
+
+		if b.keys[o].inferred {
+			k.inferred = true
+		}
+
 		b.filteredKeys[t] = k
 	}
 }
@@ -223,6 +357,8 @@ func (b *baseWriter) transposedWriter(dst string, keys []flKey) *baseWriter {
 	tw = &baseWriter{}
 
 	tw.isTransposed = true
+	tw.listFormat = b.listFormat
+	tw.typedColumns = b.typedColumns
 	tw.keys = keys
 	tw.trimmedKeys = map[string]idxKey{
 		"._sequence": {idx: 0, k: flKey{
@@ -234,6 +370,18 @@ func (b *baseWriter) transposedWriter(dst string, keys []flKey) *baseWriter {
 			replaced: b.p.prepareKey("._index"),
 		}},
 	}
+
+	for _, lk := range lineageKeys {
+		for i, k := range keys {
+			if k.original == lk && k.transposeDst == "" {
+				idx := len(tw.trimmedKeys)
+				tw.trimmedKeys[lk] = idxKey{idx: idx, k: k}
+				tw.lineage = append(tw.lineage, lineageIdx{src: i, dst: idx})
+
+				break
+			}
+		}
+	}
 	tw.transposedMapping = map[int]int{}
 
 	b.transposed[dst] = tw
@@ -260,10 +408,15 @@ func (b *baseWriter) receiveRow(seq int64, values []Value) (transposedRows [][]s
 	transposedRowsIdx := map[string][]string{}
 
 	for _, i := range b.keyIndexes {
-		v := values[i]
+		v := b.value(values[i])
 
 		var f string
-		if v.Type != TypeNull && v.Type != TypeAbsent {
+
+		switch {
+		case v.Type == TypeNull || v.Type == TypeAbsent:
+		case v.Type == TypeList && b.listFormat != nil:
+			f = b.listFormat(v.List)
+		default:
 			f = v.Format()
 		}
 
@@ -281,6 +434,13 @@ func (b *baseWriter) receiveRow(seq int64, values []Value) (transposedRows [][]s
 				row = make([]string, len(b.trimmedKeys))
 				row[0] = strconv.Itoa(int(seq)) // Add sequence.
 				row[1] = transposeKey           // Add array idx/object property.
+
+				for _, l := range b.lineage {
+					if lv := values[l.src]; lv.Type != TypeNull && lv.Type != TypeAbsent {
+						row[l.dst] = lv.Format()
+					}
+				}
+
 				transposedRowsIdx[transposeKey] = row
 				transposedRows = append(transposedRows, row)
 			}
@@ -306,7 +466,7 @@ func (b *baseWriter) receiveTransposedRowValues(seq int64, values []Value) (tran
 	transposedRowsIdx := map[string][]Value{}
 
 	for _, i := range b.keyIndexes {
-		v := values[i]
+		v := b.value(values[i])
 
 		if v.Type == TypeAbsent {
 			continue
@@ -324,6 +484,11 @@ func (b *baseWriter) receiveTransposedRowValues(seq int64, values []Value) (tran
 				Number: float64(seq),
 			} // Add sequence.
 			row[1] = k.transposeKey.Value() // Add array idx/object property.
+
+			for _, l := range b.lineage {
+				row[l.dst] = values[l.src]
+			}
+
 			transposedRowsIdx[transposeKey] = row
 			transposedRows = append(transposedRows, row)
 		}
@@ -342,12 +507,21 @@ func (b *baseWriter) receiveRowValues(values []Value) (row []Value) {
 	row = make([]Value, len(b.keyIndexes))
 
 	for j, i := range b.keyIndexes {
-		row[j] = values[i]
+		row[j] = b.value(values[i])
 	}
 
 	return row
 }
 
+// value returns value to write, unconverted value is null in outputs with typed columns.
+func (b *baseWriter) value(v Value) Value {
+	if v.Unconverted && b.typedColumns {
+		return Value{Type: TypeNull}
+	}
+
+	return v
+}
+
 type nopWriter struct{}
 
 func (nopWriter) Write(p []byte) (n int, err error) {
@@ -362,11 +536,27 @@ type fileWriter struct {
 	f  io.WriteCloser
 	fn string
 
+	// appended is true if file had content before writing, so headers should not be written.
+	appended bool
+
 	uncompressed *progress.CountingWriter
 	compressed   *progress.CountingWriter
 }
 
+// outputMode defines how existing output files are continued.
+type outputMode struct {
+	// resume has sizes of files to continue output of interrupted run, content after size is discarded.
+	resume map[string]int64
+	// append adds rows to existing files, compressed files get another compressed stream.
+	append bool
+}
+
 func newFileWriter(fn string) (*fileWriter, error) {
+	return outputFileWriter(fn, outputMode{})
+}
+
+// outputFileWriter creates file, or continues existing file in resume or append mode.
+func outputFileWriter(fn string, om outputMode) (*fileWriter, error) {
 	var err error
 
 	c := &fileWriter{}
@@ -375,9 +565,9 @@ func newFileWriter(fn string) (*fileWriter, error) {
 	if fn == NopFile {
 		c.f = nopWriter{}
 	} else {
-		c.f, err = os.Create(fn)
+		c.f, err = c.open(fn, om)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create file %s: %w", fn, err)
+			return nil, err
 		}
 
 		switch {
@@ -399,6 +589,104 @@ func newFileWriter(fn string) (*fileWriter, error) {
 	return c, nil
 }
 
+func (c *fileWriter) open(fn string, om outputMode) (*os.File, error) {
+	switch {
+	case om.resume != nil:
+		size, ok := om.resume[fn]
+		if !ok {
+			return nil, fmt.Errorf("no position of %s in checkpoint", fn)
+		}
+
+		c.appended = true
+
+		return openOutputFile(fn, size)
+	case om.append:
+		return appendOutputFile(fn, &c.appended)
+	default:
+		f, err := os.Create(fn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create file %s: %w", fn, err)
+		}
+
+		return f, nil
+	}
+}
+
+// appendOutputFile opens or creates file for appending, appended is set to true if file is not empty.
+func appendOutputFile(fn string, appended *bool) (*os.File, error) {
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o666) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", fn, err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+
+		return nil, fmt.Errorf("failed to stat file %s: %w", fn, err)
+	}
+
+	*appended = fi.Size() > 0
+
+	return f, nil
+}
+
+// openOutputFile opens file for writing after size, data beyond size is discarded.
+func openOutputFile(fn string, size int64) (*os.File, error) {
+	f, err := os.OpenFile(fn, os.O_WRONLY, 0) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", fn, err)
+	}
+
+	fi, err := f.Stat()
+	if err == nil && fi.Size() < size {
+		err = fmt.Errorf("file %s is shorter than committed size %d", fn, size)
+	}
+
+	if err == nil {
+		err = f.Truncate(size)
+	}
+
+	if err == nil {
+		_, err = f.Seek(size, io.SeekStart)
+	}
+
+	if err != nil {
+		_ = f.Close()
+
+		return nil, fmt.Errorf("failed to resume file %s: %w", fn, err)
+	}
+
+	return f, nil
+}
+
+// filePosition syncs file and returns its size.
+func filePosition(fn string, w io.Writer) (int64, error) {
+	if fn == NopFile {
+		return 0, nil
+	}
+
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0, fmt.Errorf("cannot commit compressed output %s", fn)
+	}
+
+	if err := f.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to sync file %s: %w", fn, err)
+	}
+
+	return f.Seek(0, io.SeekCurrent)
+}
+
+// flush writes buffered data of compressor to file.
+func (c *fileWriter) flush() error {
+	if f, ok := c.f.(Flusher); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
 // Metrics return available metrics.
 func (c *fileWriter) Metrics() []progress.Metric {
 	var res []progress.Metric